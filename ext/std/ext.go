@@ -32,7 +32,10 @@ var Ext = compiler.Extension{
 		"microtime":     vm.NewBuiltInFunction(microtime, vm.Arg{Name: "as_float", Type: vm.BoolType, Default: vm.Bool(false)}),
 		"var_dump":      vm.NewBuiltInFunction(varDump, vm.Arg{Name: "value"}, vm.Arg{Name: "values", Variadic: true}),
 
-		"count": vm.NewBuiltInFunction(count, vm.Arg{Name: "value", Type: vm.ArrayType}),
+		"count": vm.NewBuiltInFunction(count, vm.Arg{Name: "value"}),
+
+		"func_num_args": vm.NewBuiltInFunction(vm.FuncNumArgs),
+		"func_get_args": vm.NewBuiltInFunction(vm.FuncGetArgs),
 	},
 	Constants: map[string]vm.Value{
 		"PATHINFO_DIRNAME":   PathinfoDirname,
@@ -23,5 +23,5 @@ func varDump(ctx vm.Context, args ...vm.Value) vm.Value {
 		fmt.Fprintln(ctx.Output(), arg.DebugInfo(ctx))
 	}
 
-	return nil
+	return vm.Null{}
 }
@@ -1,13 +1,16 @@
 package std
 
-import "php-vm/internal/vm"
+import (
+	"fmt"
+	"php-vm/internal/vm"
+)
 
 func count(ctx vm.Context, args ...vm.Value) vm.Value {
-	switch args[0].(type) {
+	switch v := args[0].(type) {
 	case vm.Countable:
-		return args[0].(vm.Countable).Count(ctx)
+		return v.Count(ctx)
 	default:
-		ctx.Throw(vm.NewThrowable("invalid type", vm.EError))
-		return nil
+		ctx.Throw(vm.NewTypeError(fmt.Sprintf("count(): Argument #1 ($value) must be of type Countable|array, %s given", vm.DebugType(v))))
+		return vm.Int(0)
 	}
 }
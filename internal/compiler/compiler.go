@@ -1,27 +1,42 @@
 package compiler
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/VKCOM/php-parser/pkg/ast"
 	"github.com/VKCOM/php-parser/pkg/conf"
+	"github.com/VKCOM/php-parser/pkg/errors"
 	"github.com/VKCOM/php-parser/pkg/parser"
 	"github.com/VKCOM/php-parser/pkg/version"
 	"github.com/VKCOM/php-parser/pkg/visitor"
 	"github.com/VKCOM/php-parser/pkg/visitor/nsresolver"
+	"math"
 	"php-vm/internal/compiler/internal"
 	"php-vm/internal/vm"
 	"slices"
 	"strconv"
 	"strings"
-	"unsafe"
 )
 
+// builtInTypeAsserts are type declarations of values
 var builtInTypeAsserts = map[string]vm.Type{
 	"int":    vm.IntType,
 	"float":  vm.FloatType,
 	"bool":   vm.BoolType,
+	"string": vm.StringType,
 	"array":  vm.ArrayType,
 	"object": vm.ObjectType,
+	"null":   vm.NullType,
+}
+
+// typeFeatures are built-in types, which are not available in every version of PHP
+var typeFeatures = map[string]feature{"mixed": mixedType, "static": staticReturnType, "never": neverType}
+
+// builtInTypes are type declarations, which do not refer to a class or a type of values
+var builtInTypes = map[string]vm.DeclFlag{
+	"mixed": vm.MixedDecl, "callable": vm.CallableDecl, "iterable": vm.IterableDecl, "void": vm.VoidDecl,
+	"never": vm.NeverDecl, "static": vm.StaticDecl, "false": vm.FalseDecl, "true": vm.TrueDecl,
 }
 
 var posixReplacer = strings.NewReplacer("\\a", "\a", "\\b", "\b", "\\n", "\n", "\\r", "\r", "\\t", "\t", "\\v", "\v", "\\f", "\f")
@@ -32,6 +47,18 @@ const (
 	VariableAliasType = "variable"
 )
 
+// traitUse is a `use` statement inside a class with names of traits resolved
+type traitUse struct {
+	traits   []string
+	excluded map[string]bool // trait::method excluded by insteadof rules
+	aliases  []traitAlias
+}
+
+type traitAlias struct {
+	trait, method, alias string
+	visibility           *vm.Visibility
+}
+
 type Compiler struct {
 	visitor.Null
 
@@ -42,28 +69,54 @@ type Compiler struct {
 	context        internal.Context
 	ctx            *vm.GlobalContext
 	arrayWriteMode map[ast.Vertex]bool
+
+	class        *vm.Class // class being compiled
+	classes      []*vm.Class
+	parents      map[*vm.Class]string
+	interfaces   map[*vm.Class][]string
+	uses         map[*vm.Class][]traitUse
+	initializers []func()                         // initializers of class members, which are evaluated after classes are linked
+	constants    map[*vm.ClassConstant]ast.Vertex // class constants, which are not evaluated yet
+	stackDepth   int                              // values kept on the stack by enclosing statements, e.g. foreach iterators
+	lists        int                              // nesting depth of destructuring assignments
+	temps        int                              // hidden variables in use by enclosing expressions
+	statics      []vm.Value                       // initial values of static variables declared in functions
+	strict       bool                             // script declares strict_types=1
+	version      *version.Version                 // version of PHP targeted by compiled scripts
+	chained      map[ast.Vertex]bool              // objects and arrays accessed by enclosing member access
+	nullsafe     []int                            // jumps short-circuiting member access chains on null
+	signatures   map[string][]internal.Arg        // parameters of functions declared at the top level of the script
+	anonymous    int                              // anonymous classes declared by the script
 }
 
 func (c *Compiler) Root(n *ast.Root) {
-	for _, stmt := range n.Stmts {
+	c.hoist(n.Stmts)
+
+	for i, stmt := range n.Stmts {
 		stmt.Accept(c)
 
-		if _, ok := stmt.(*ast.StmtReturn); ok {
-			break
+		if endsWithReturn(n.Stmts[:i+1]) {
+			return
 		}
 	}
 
-	switch binary.NativeEndian.Uint64((*c.context.Bytecode())[len(*c.context.Bytecode())-8:]) {
-	case uint64(vm.OpReturn), uint64(vm.OpReturnValue):
-	default:
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturn))
-	}
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturn))
 }
 
 func (c *Compiler) Parameter(n *ast.Parameter) {
 	name := c.context.Resolve(n.Var, VariableAliasType)
-	_type := c.context.Resolve(n.Type, "")
-	c.context.Arg(name, _type, n.DefaultValue, n.AmpersandTkn != nil)
+	decl := c.typeDecl(n.Type)
+
+	if _, ok := n.DefaultValue.(*ast.ExprNew); ok {
+		c.require(newInInitializers)
+	}
+
+	// Parameter with default value null is implicitly nullable
+	if def, ok := n.DefaultValue.(*ast.ExprConstFetch); ok && decl != nil && strings.EqualFold(c.className(def.Const), "null") {
+		decl.Types |= vm.NullType
+	}
+
+	c.context.Arg(internal.Arg{Name: name, Decl: decl, Default: n.DefaultValue, IsRef: n.AmpersandTkn != nil, Variadic: n.VariadicTkn != nil})
 }
 
 func (c *Compiler) Argument(n *ast.Argument) {
@@ -71,24 +124,311 @@ func (c *Compiler) Argument(n *ast.Argument) {
 }
 
 func (c *Compiler) StmtClass(n *ast.StmtClass) {
-	c.context.Resolve(n.Name, "class")
-	n.Extends.Accept(c)
+	name := c.className(n.Name)
+
+	// anonymous class is named after its position as in PHP, the part after NUL byte is not shown in type errors
+	if n.Name == nil {
+		name = fmt.Sprintf("class@anonymous\x00:%d$%x", n.Position.StartLine, c.anonymous)
+		c.anonymous++
+	}
+
+	class := vm.NewClass(vm.String(name))
+	class.Abstract = hasModifier(n.Modifiers, "abstract")
 
-	for _, modifiers := range n.Modifiers {
-		modifiers.Accept(c)
+	// Parser accepts readonly classes of PHP 8.2 in syntax of 8.1, they are rejected until 8.2 can be targeted
+	if class.Readonly = hasModifier(n.Modifiers, "readonly"); class.Readonly {
+		c.require(readonlyClasses)
 	}
 
-	for _, impl := range n.Implements {
-		impl.Accept(c)
+	if n.Extends != nil {
+		c.parents[class] = c.className(n.Extends)
 	}
 
-	for _, arg := range n.Args {
-		arg.Accept(c)
+	for _, iface := range n.Implements {
+		c.interfaces[class] = append(c.interfaces[class], c.className(iface))
 	}
 
-	for _, stmt := range n.Stmts {
+	c.classBody(class, n.Stmts)
+}
+
+func (c *Compiler) StmtInterface(n *ast.StmtInterface) {
+	class := vm.NewClass(vm.String(c.className(n.Name)))
+	class.Interface = true
+
+	for _, iface := range n.Extends {
+		c.interfaces[class] = append(c.interfaces[class], c.className(iface))
+	}
+
+	c.classBody(class, n.Stmts)
+}
+
+func (c *Compiler) StmtTrait(n *ast.StmtTrait) {
+	class := vm.NewClass(vm.String(c.className(n.Name)))
+	class.Trait = true
+	c.classBody(class, n.Stmts)
+}
+
+func (c *Compiler) StmtTraitUse(n *ast.StmtTraitUse) {
+	use := traitUse{excluded: make(map[string]bool)}
+
+	for _, trait := range n.Traits {
+		use.traits = append(use.traits, c.className(trait))
+	}
+
+	for _, adaptation := range n.Adaptations {
+		switch a := adaptation.(type) {
+		case *ast.StmtTraitUsePrecedence:
+			method := strings.ToLower(identifier(a.Method))
+
+			for _, trait := range a.Insteadof {
+				use.excluded[strings.ToLower(c.className(trait))+"::"+method] = true
+			}
+		case *ast.StmtTraitUseAlias:
+			alias := traitAlias{method: identifier(a.Method), alias: identifier(a.Alias)}
+
+			if a.Trait != nil {
+				alias.trait = c.className(a.Trait)
+			}
+
+			if a.Modifier != nil {
+				visibility, _ := modifiers([]ast.Vertex{a.Modifier})
+				alias.visibility = &visibility
+			}
+
+			use.aliases = append(use.aliases, alias)
+		}
+	}
+
+	c.uses[c.class] = append(c.uses[c.class], use)
+}
+
+func (c *Compiler) StmtEnum(n *ast.StmtEnum) {
+	c.require(enumerations)
+	var backing vm.Type
+
+	if n.Type != nil {
+		switch t := strings.ToLower(c.className(n.Type)); t {
+		case "int":
+			backing = vm.IntType
+		case "string":
+			backing = vm.StringType
+		default:
+			panic(fmt.Sprintf("Enum backing type must be int or string, %s given", t))
+		}
+	}
+
+	class := vm.NewEnumClass(vm.String(c.className(n.Name)), backing)
+
+	if backing == 0 {
+		c.interfaces[class] = append(c.interfaces[class], "UnitEnum")
+	} else {
+		c.interfaces[class] = append(c.interfaces[class], "BackedEnum")
+	}
+
+	for _, iface := range n.Implements {
+		c.interfaces[class] = append(c.interfaces[class], c.className(iface))
+	}
+
+	c.classBody(class, n.Stmts)
+}
+
+func (c *Compiler) EnumCase(n *ast.EnumCase) {
+	class := c.class
+	name := identifier(n.Name)
+
+	if !class.Enum {
+		panic("Case can only be used in enums")
+	}
+
+	e := class.AddCase(c.ctx, vm.String(name), nil)
+
+	switch {
+	case class.Backing == 0 && n.Expr != nil:
+		panic(fmt.Sprintf("Case %s of non-backed enum %s must not have a value", name, string(class.Name)))
+	case class.Backing != 0 && n.Expr == nil:
+		panic(fmt.Sprintf("Case %s of backed enum %s must have a value", name, string(class.Name)))
+	case n.Expr == nil:
+		return
+	}
+
+	c.initializers = append(c.initializers, func() {
+		value := c.constantExpr(class, n.Expr)
+
+		if value.Type() != class.Backing {
+			backing := "int"
+
+			if class.Backing == vm.StringType {
+				backing = "string"
+			}
+
+			panic(fmt.Sprintf("Enum case type %s does not match enum backing type %s", vm.DebugType(value), backing))
+		}
+
+		for _, other := range class.Cases {
+			if other.Value == value {
+				panic(fmt.Sprintf("Duplicate value in enum %s for cases %s and %s", string(class.Name), string(other.Case), name))
+			}
+		}
+
+		e.Value = value
+	})
+}
+
+// classBody compiles members of class
+func (c *Compiler) classBody(class *vm.Class, stmts []ast.Vertex) {
+	parent := c.class
+	c.class = class
+
+	for _, stmt := range stmts {
 		stmt.Accept(c)
 	}
+
+	c.class = parent
+	c.classes = append(c.classes, class)
+}
+
+func (c *Compiler) StmtPropertyList(n *ast.StmtPropertyList) {
+	visibility, static := modifiers(n.Modifiers)
+	class := c.class
+
+	if class.Enum {
+		panic(fmt.Sprintf("Enum %s cannot include properties", string(class.Name)))
+	}
+
+	if n.Type != nil {
+		c.require(typedProperties)
+	}
+
+	if hasModifier(n.Modifiers, "readonly") {
+		c.require(readonlyProperties)
+	}
+
+	decl := c.typeDecl(n.Type)
+
+	for _, prop := range n.Props {
+		prop := prop.(*ast.StmtProperty)
+		p := c.property(strings.TrimPrefix(identifier(prop.Var), "$"), visibility, decl, hasModifier(n.Modifiers, "readonly"))
+
+		if p.Readonly && static {
+			panic(fmt.Sprintf("Static property %s::$%s cannot be readonly", string(class.Name), string(p.Name)))
+		}
+
+		if prop.Expr != nil {
+			if p.Readonly {
+				panic(fmt.Sprintf("Readonly property %s::$%s cannot have default value", string(class.Name), string(p.Name)))
+			}
+
+			if def, ok := prop.Expr.(*ast.ExprConstFetch); ok && decl != nil && decl.Types&vm.NullType == 0 && decl.Flags&vm.MixedDecl == 0 && strings.EqualFold(c.className(def.Const), "null") {
+				panic(fmt.Sprintf("Default value for property of type %s may not be null. Use the nullable type ?%s to allow null default value", decl, decl))
+			}
+
+			expr := prop.Expr
+			c.initializers = append(c.initializers, func() {
+				if v := c.constantExpr(class, expr); !p.SetDefault(c.ctx, v) {
+					panic(fmt.Sprintf("Cannot use %s as default value for property %s::$%s of type %s", vm.DebugType(v), string(class.Name), string(p.Name), decl))
+				}
+			})
+		}
+
+		if static {
+			class.AddStaticProperty(p)
+		} else {
+			class.AddProperty(p)
+		}
+	}
+}
+
+// property declares property name of current class with type decl. Typed property without default value
+// is uninitialized, properties of readonly classes are readonly
+func (c *Compiler) property(name string, visibility vm.Visibility, decl *vm.TypeDecl, readonly bool) *vm.Property {
+	p := &vm.Property{Name: vm.String(name), Visibility: visibility, Decl: decl, Readonly: readonly || c.class.Readonly}
+
+	if _, ok := c.class.Property(p.Name); ok {
+		panic(fmt.Sprintf("Cannot redeclare %s::$%s", string(c.class.Name), name))
+	}
+
+	if decl != nil && decl.Flags&(vm.CallableDecl|vm.VoidDecl|vm.NeverDecl) != 0 {
+		panic(fmt.Sprintf("Property %s::$%s cannot have type %s", string(c.class.Name), name, decl))
+	}
+
+	if p.Readonly && decl == nil {
+		panic(fmt.Sprintf("Readonly property %s::$%s must have type", string(c.class.Name), name))
+	}
+
+	if decl == nil {
+		p.Default = vm.Null{}
+	}
+
+	return p
+}
+
+func (c *Compiler) StmtClassConstList(n *ast.StmtClassConstList) {
+	visibility, _ := modifiers(n.Modifiers)
+
+	for _, constant := range n.Consts {
+		constant := constant.(*ast.StmtConstant)
+		cc := &vm.ClassConstant{Name: vm.String(identifier(constant.Name)), Visibility: visibility}
+		c.class.AddConstant(cc)
+		c.constants[cc] = constant.Expr
+	}
+}
+
+func (c *Compiler) StmtClassMethod(n *ast.StmtClassMethod) {
+	visibility, static := modifiers(n.Modifiers)
+	name := identifier(n.Name)
+
+	if _, ok := n.Stmt.(*ast.StmtNop); ok && (c.class.Interface || hasModifier(n.Modifiers, "abstract")) {
+		if slices.ContainsFunc(n.Params, func(param ast.Vertex) bool { return len(param.(*ast.Parameter).Modifiers) > 0 }) {
+			panic("Cannot declare promoted property in an abstract constructor")
+		}
+
+		c.class.AddMethod(&vm.Method{Name: vm.String(name), Visibility: visibility, Static: static, Abstract: true})
+		return
+	}
+
+	ctx := c.context.Child(string(c.class.Name) + "::" + name)
+	c.context = ctx
+	depth := c.stackDepth
+	c.stackDepth = 0
+
+	for _, param := range n.Params {
+		param.Accept(c)
+	}
+
+	ctx.Return = c.typeDecl(n.ReturnType)
+	c.defaultParams(n.Params)
+	c.assertParams(ctx)
+	c.promoteParams(ctx, name, n.Params)
+	body := len(ctx.Instructions) >> 3
+	n.Stmt.Accept(c)
+
+	if list, ok := n.Stmt.(*ast.StmtStmtList); !ok || !endsWithReturn(list.Stmts) {
+		c.implicitReturn(ctx)
+	}
+
+	if ctx.Generator {
+		generator(ctx, body)
+	}
+
+	c.context = c.context.Parent()
+	c.stackDepth = depth
+	c.class.AddMethod(&vm.Method{
+		Name:       vm.String(name),
+		Visibility: visibility,
+		Static:     static,
+		Fn: vm.CompiledFunction{
+			Name:         ctx.Name,
+			Instructions: Optimizer(ctx.Instructions),
+			Args:         len(ctx.Args),
+			Vars:         len(ctx.Variables),
+			Required:     required(ctx.Args),
+			Params:       parameters(ctx.Args),
+			Return:       ctx.Return,
+			Strict:       c.strict,
+			Handlers:     ctx.Handlers,
+			Lines:        ctx.Lines,
+		},
+	})
 }
 
 func (c *Compiler) StmtConstant(n *ast.StmtConstant) {
@@ -108,62 +448,135 @@ func (c *Compiler) StmtConstList(n *ast.StmtConstList) {
 	}
 }
 
+// StmtDeclare => declare(strict_types=1); Directive strict_types switches calls and returns of the script
+// to strict typing mode. Other directives have no effect
 func (c *Compiler) StmtDeclare(n *ast.StmtDeclare) {
-	for _, constant := range n.Consts {
-		constant.Accept(c)
+	for _, directive := range n.Consts {
+		directive := directive.(*ast.StmtConstant)
+
+		if !strings.EqualFold(identifier(directive.Name), "strict_types") {
+			continue
+		}
+
+		switch v, _ := directive.Expr.(*ast.ScalarLnumber); {
+		case v != nil && string(v.Value) == "0":
+			c.strict = false
+		case v != nil && string(v.Value) == "1":
+			c.strict = true
+		default:
+			panic("strict_types declaration must have 0 or 1 as its value")
+		}
 	}
 
 	n.Stmt.Accept(c)
 }
 
+// hoist collects parameters of functions declared by stmts, so that calls preceding declarations are compiled
+// as direct calls
+func (c *Compiler) hoist(stmts []ast.Vertex) {
+	for _, stmt := range stmts {
+		fn, ok := stmt.(*ast.StmtFunction)
+
+		if !ok {
+			continue
+		}
+
+		args := make([]internal.Arg, len(fn.Params))
+
+		for i, param := range fn.Params {
+			param := param.(*ast.Parameter)
+			args[i] = internal.Arg{Name: identifier(param.Var), Default: param.DefaultValue, IsRef: param.AmpersandTkn != nil, Variadic: param.VariadicTkn != nil}
+		}
+
+		c.signatures[identifier(fn.Name)] = args
+	}
+}
+
 func (c *Compiler) StmtFunction(n *ast.StmtFunction) {
 	ctx := c.context.Child(c.context.Resolve(n.Name, FunctionAliasType))
 	c.context = ctx
 	c.contexts = append(c.contexts, ctx)
+	depth := c.stackDepth
+	c.stackDepth = 0
 
 	for _, param := range n.Params {
 		param.Accept(c)
 	}
 
+	ctx.Return = c.typeDecl(n.ReturnType)
+	c.defaultParams(n.Params)
+	c.assertParams(ctx)
+	body := len(ctx.Instructions) >> 3
+
 	for _, stmt := range n.Stmts {
 		stmt.Accept(c)
 	}
 
-	switch binary.NativeEndian.Uint64((*c.context.Bytecode())[len(*c.context.Bytecode())-8:]) {
-	case uint64(vm.OpReturn), uint64(vm.OpReturnValue):
-	default:
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturn))
+	if !endsWithReturn(n.Stmts) {
+		c.implicitReturn(ctx)
 	}
 
-	if n.ReturnType != nil {
-		n.ReturnType.Accept(c)
+	if ctx.Generator {
+		generator(ctx, body)
 	}
 
 	c.context = c.context.Parent()
+	c.stackDepth = depth
 }
 
 func (c *Compiler) StmtIf(n *ast.StmtIf) {
-	n.Cond.Accept(c)
+	branches := append([]ast.Vertex{n}, n.ElseIf...)
+	var exits []int
 
-	pos := len(*c.context.Bytecode())
-	n.Stmt.Accept(c)
+	for i, branch := range branches {
+		var cond, stmt ast.Vertex
 
-	goTo := (len(*c.context.Bytecode()) + 16) >> 3
+		switch b := branch.(type) {
+		case *ast.StmtIf:
+			cond, stmt = b.Cond, b.Stmt
+		case *ast.StmtElseIf:
+			cond, stmt = b.Cond, b.Stmt
+		}
 
-	end := make([]byte, len((*c.context.Bytecode())[pos:]))
-	copy(end, (*c.context.Bytecode())[pos:])
+		cond.Accept(c)
+		next := c.emitJump(vm.OpJumpFalse)
+		stmt.Accept(c)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64((*c.context.Bytecode())[:pos], uint64(vm.OpJumpFalse))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(goTo))
-	*c.context.Bytecode() = append(*c.context.Bytecode(), end...)
-}
+		if i < len(branches)-1 || n.Else != nil {
+			exits = append(exits, c.emitJump(vm.OpJump))
+		}
 
-func (c *Compiler) StmtNop(*ast.StmtNop) {
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNoop))
+		c.patchJump(next)
+	}
+
+	if n.Else != nil {
+		n.Else.(*ast.StmtElse).Stmt.Accept(c)
+	}
+
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
 }
 
+func (c *Compiler) StmtNop(*ast.StmtNop) {}
+
 func (c *Compiler) StmtReturn(n *ast.StmtReturn) {
-	if n.Expr == nil {
+	c.line(n)
+
+	if ctx, ok := c.context.(*internal.FunctionContext); ok && ctx.Return != nil {
+		switch {
+		case ctx.Return.Flags&vm.NeverDecl != 0:
+			panic("A never-returning function must not return")
+		case ctx.Return.Flags&vm.VoidDecl != 0 && n.Expr != nil:
+			panic("A void function must not return a value")
+		case ctx.Return.Flags&vm.VoidDecl == 0 && n.Expr == nil && !ctx.Generator:
+			panic("A function with return type must return a value")
+		}
+	}
+
+	if _, ok := c.context.(*internal.FunctionContext); ok && n.Expr == nil {
+		c.returnNull()
+	} else if n.Expr == nil {
 		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturn))
 	} else {
 		n.Expr.Accept(c)
@@ -172,6 +585,55 @@ func (c *Compiler) StmtReturn(n *ast.StmtReturn) {
 	}
 }
 
+// returnNull => return null;
+func (c *Compiler) returnNull() {
+	c.null()
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturnValue))
+}
+
+// implicitReturn => return; at the end of body of function compiled in ctx. Function with return type other than void
+// throws TypeError instead
+func (c *Compiler) implicitReturn(ctx *internal.FunctionContext) {
+	decl := ctx.Return
+
+	if decl == nil || decl.Flags&vm.VoidDecl != 0 || ctx.Generator {
+		c.returnNull()
+		return
+	}
+
+	name, _, _ := strings.Cut(ctx.Name, "#")
+	msg := fmt.Sprintf("%s(): Return value must be of type %s, none returned", name, decl)
+
+	if decl.Flags&vm.NeverDecl != 0 {
+		msg = fmt.Sprintf("%s(): never-returning function must not implicitly return", name)
+	}
+
+	c.constant(&ast.Identifier{}, vm.String("TypeError"))
+	c.constant(&ast.ScalarString{}, vm.String(msg))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNew))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 1)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThrow))
+}
+
+// null => null
+func (c *Compiler) null() {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.global.NamedConstants["null"]))
+}
+
+// emitJump appends jump operator op with unknown target and returns offset of its operand to patch it later
+func (c *Compiler) emitJump(op vm.Operator) int {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(op))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 0)
+	return len(*c.context.Bytecode()) - 8
+}
+
+// patchJump sets target of jump, which operand is at offset at, to the next instruction
+func (c *Compiler) patchJump(at int) {
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[at:], uint64(len(*c.context.Bytecode()))>>3)
+}
+
 func (c *Compiler) StmtStmtList(n *ast.StmtStmtList) {
 	for _, stmt := range n.Stmts {
 		stmt.Accept(c)
@@ -179,6 +641,7 @@ func (c *Compiler) StmtStmtList(n *ast.StmtStmtList) {
 }
 
 func (c *Compiler) StmtExpression(n *ast.StmtExpression) {
+	c.line(n)
 	n.Expr.Accept(c)
 
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
@@ -187,48 +650,65 @@ func (c *Compiler) StmtExpression(n *ast.StmtExpression) {
 func (c *Compiler) StmtFor(n *ast.StmtFor) {
 	for _, expr := range n.Init {
 		expr.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
 	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
 	condPos := len(*c.context.Bytecode()) >> 3
+	exit := -1
 
-	for _, cond := range n.Cond {
+	for i, cond := range n.Cond {
 		cond.Accept(c)
+
+		if i < len(n.Cond)-1 {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+		}
 	}
 
-	pos := len(*c.context.Bytecode())
-	n.Stmt.Accept(c)
+	if len(n.Cond) > 0 {
+		exit = c.emitJump(vm.OpJumpFalse)
+	}
+
+	loop := c.loopBody(n.Stmt, 0)
+	c.patchJumps(loop.Continues)
 
-	for _, loop := range n.Loop {
-		loop.Accept(c)
+	for _, expr := range n.Loop {
+		expr.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
 	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJump))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(condPos))
 
-	goTo := (len(*c.context.Bytecode()) + 16) >> 3
-
-	end := make([]byte, len((*c.context.Bytecode())[pos:]))
-	copy(end, (*c.context.Bytecode())[pos:])
+	if exit >= 0 {
+		c.patchJump(exit)
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64((*c.context.Bytecode())[:pos], uint64(vm.OpJumpFalse))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(goTo))
-	*c.context.Bytecode() = append(*c.context.Bytecode(), end...)
+	c.patchJumps(loop.Breaks)
 }
 
+// StmtForeach compiles foreach loop. Iterator is kept on the stack until the loop exits. Value destructured by list
+// is assigned to hidden variable first:
+//
+//	foreach ($x as $k => $v) { A }
+//	=> $x, FE_INIT, next: FE_VALID, JUMP_FALSE exit, FE_VALUE $v, FE_KEY $k, A, FE_NEXT, JUMP next, exit: POP
 func (c *Compiler) StmtForeach(n *ast.StmtForeach) {
 	n.Expr.Accept(c)
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpForEachInit))
 	pos := len(*c.context.Bytecode()) >> 3
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpForEachValid))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJumpFalse))
-	iter := len(*c.context.Bytecode())
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 0)
+	exit := c.emitJump(vm.OpJumpFalse)
 
-	n.Var.Accept(c)
+	value := n.Var
+	list, destructure := n.Var.(*ast.ExprList)
+
+	if destructure {
+		value = c.listTemp()
+	}
+
+	value.Accept(c)
 
-	if n.AmpersandTkn == nil {
+	// elements are bound by reference to targets of list, if any of them is taken by reference
+	if n.AmpersandTkn == nil && (!destructure || !refList(list)) {
 		binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpForEachValue))
 	} else {
 		binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpForEachValueRef))
@@ -239,603 +719,2786 @@ func (c *Compiler) StmtForeach(n *ast.StmtForeach) {
 		binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpForEachKey))
 	}
 
-	n.Stmt.Accept(c)
+	if destructure {
+		c.destructure(list, value.(*ast.ExprVariable))
+	}
+
+	c.stackDepth++
+	loop := c.loopBody(n.Stmt, 1)
+	c.stackDepth--
+	c.patchJumps(loop.Continues)
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpForEachNext))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJump))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(pos))
+	c.patchJump(exit)
+	c.patchJumps(loop.Breaks)
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[iter:], uint64(len(*c.context.Bytecode()))>>3)
 }
 
 func (c *Compiler) StmtWhile(n *ast.StmtWhile) {
 	cond := len(*c.context.Bytecode()) >> 3
 	n.Cond.Accept(c)
-	pos := len(*c.context.Bytecode())
-	n.Stmt.Accept(c)
+	exit := c.emitJump(vm.OpJumpFalse)
+	loop := c.loopBody(n.Stmt, 0)
+	c.patchJumps(loop.Continues)
 
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJump))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(cond))
-
-	goTo := (len(*c.context.Bytecode()) + 16) >> 3
-
-	end := make([]byte, len((*c.context.Bytecode())[pos:]))
-	copy(end, (*c.context.Bytecode())[pos:])
-
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64((*c.context.Bytecode())[:pos], uint64(vm.OpJumpFalse))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(goTo))
-	*c.context.Bytecode() = append(*c.context.Bytecode(), end...)
+	c.patchJump(exit)
+	c.patchJumps(loop.Breaks)
 }
 
 func (c *Compiler) StmtDo(n *ast.StmtDo) {
 	pos := len(*c.context.Bytecode()) >> 3
-	n.Stmt.Accept(c)
+	loop := c.loopBody(n.Stmt, 0)
+	c.patchJumps(loop.Continues)
 	n.Cond.Accept(c)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJumpFalse))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJumpTrue))
 	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(pos))
+	c.patchJumps(loop.Breaks)
 }
 
-func (c *Compiler) StmtGoto(n *ast.StmtGoto) {
-	name := c.context.Resolve(n.Label, "")
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJump))
-	c.context.AddLabel(name, uint64(len(*c.context.Bytecode())))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNoop))
-}
-
-func (c *Compiler) StmtLabel(n *ast.StmtLabel) {
-	label := c.context.Resolve(n.Name, "")
+// loopBody compiles body of loop, which keeps values on the stack, and returns jumps of break and continue
+// statements, which leave it
+func (c *Compiler) loopBody(body ast.Vertex, values int) *internal.Loop {
+	loop := &internal.Loop{Values: values}
+	c.context.EnterLoop(loop)
+	body.Accept(c)
+	c.context.LeaveLoop()
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[c.context.FindLabel(label):], uint64(len(*c.context.Bytecode()))>>3)
+	return loop
 }
 
-func (c *Compiler) StmtEcho(n *ast.StmtEcho) {
-	for _, expr := range n.Exprs {
-		expr.Accept(c)
+// patchJumps sets targets of jumps to the next instruction
+func (c *Compiler) patchJumps(jumps []int) {
+	for _, jump := range jumps {
+		c.patchJump(jump)
 	}
-
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEcho))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(len(n.Exprs)))
 }
 
-func (c *Compiler) StmtUnset(n *ast.StmtUnset) {
-	panic("not implemented")
-}
+// jumpTableCases is the number of cases, from which switch over literals uses jump table
+const jumpTableCases = 5
 
-func (c *Compiler) ExprFunctionCall(n *ast.ExprFunctionCall) {
-	name := c.context.Resolve(n.Function, FunctionAliasType)
-	f := slices.IndexFunc(c.contexts, func(context *internal.FunctionContext) bool {
-		return context.Name == name
-	})
+// StmtSwitch compiles switch statement. Subject is kept on the stack until the end of the statement:
+//
+//	switch ($x) { case A: B; default: C }
+//	=> $x, DUP, A, EQUAL, JUMP_TRUE b, JUMP c, b: B, c: C, end: POP
+//
+// Switch over enough integer or string literals looks up the case in jump table before comparing it:
+//
+//	=> $x, JUMP_TABLE {A: b}, JUMP c, DUP, A, EQUAL, ...
+func (c *Compiler) StmtSwitch(n *ast.StmtSwitch) {
+	n.Cond.Accept(c)
 
-	if f >= 0 {
-		for i, arg := range c.contexts[f].Args {
-			if len(n.Args)-1 < i {
-				if arg.Default != nil {
-					arg.Default.Accept(c)
-				}
-				continue
+	var table map[vm.Value]vm.Value
+	var defaults []int
+	tableAt := len(*c.context.Bytecode()) >> 3
+
+	if c.jumpTable(n.Cases) {
+		table = make(map[vm.Value]vm.Value, len(n.Cases))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJumpTable))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 0)
+		defaults = append(defaults, c.emitJump(vm.OpJump))
+	}
+
+	matches := make([]int, len(n.Cases))
+
+	for i, stmt := range n.Cases {
+		if stmt, ok := stmt.(*ast.StmtCase); ok {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+			stmt.Cond.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEqual))
+			matches[i] = c.emitJump(vm.OpJumpTrue)
+		}
+	}
+
+	defaults = append(defaults, c.emitJump(vm.OpJump))
+	def := -1
+	loop := &internal.Loop{Values: 1, Switch: true}
+	c.context.EnterLoop(loop)
+	c.stackDepth++
+
+	for i, stmt := range n.Cases {
+		switch stmt := stmt.(type) {
+		case *ast.StmtCase:
+			if key := c.constantExpr(nil, stmt.Cond); table != nil && table[key] == nil {
+				table[key] = vm.Int(len(*c.context.Bytecode())>>3 - tableAt)
 			}
 
-			n.Args[i].Accept(c)
+			c.patchJump(matches[i])
 
-			if arg.IsRef {
-				binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+			for _, s := range stmt.Stmts {
+				s.Accept(c)
 			}
+		case *ast.StmtDefault:
+			def = len(*c.context.Bytecode()) >> 3
 
-			if arg.Type != "" {
-				if aT, ok := builtInTypeAsserts[arg.Type]; ok {
-					*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssertType))
-					*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(aT))
-				}
+			for _, s := range stmt.Stmts {
+				s.Accept(c)
 			}
 		}
 	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCall))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Function(name)))
+	c.stackDepth--
+	c.context.LeaveLoop()
+	c.patchJumps(loop.Breaks)
+
+	if def < 0 {
+		def = len(*c.context.Bytecode()) >> 3
+	}
+
+	for _, jump := range defaults {
+		binary.NativeEndian.PutUint64((*c.context.Bytecode())[jump:], uint64(def))
+	}
+
+	if table != nil {
+		binary.NativeEndian.PutUint64((*c.context.Bytecode())[tableAt<<3+8:], uint64(c.context.Literal(n, vm.NewArray(table))))
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+}
+
+// jumpTable reports if cases of switch can be looked up in jump table. Cases must be literals of the same type:
+// integers or strings. Loose comparison of such literal with an integer or a non-numeric string subject is
+// an identity check, numeric string subjects are compared with the cases one by one
+func (c *Compiler) jumpTable(cases []ast.Vertex) bool {
+	var ints, strs int
+
+	for _, stmt := range cases {
+		if stmt, ok := stmt.(*ast.StmtCase); ok {
+			switch stmt.Cond.(type) {
+			case *ast.ScalarLnumber:
+				ints++
+			case *ast.ScalarString:
+				strs++
+			default:
+				return false
+			}
+		}
+	}
+
+	return max(ints, strs) >= jumpTableCases && min(ints, strs) == 0
+}
+
+func (c *Compiler) StmtBreak(n *ast.StmtBreak) {
+	c.jumpOut(n.Expr, "break")
+}
+
+func (c *Compiler) StmtContinue(n *ast.StmtContinue) {
+	c.jumpOut(n.Expr, "continue")
+}
+
+// jumpOut compiles break and continue statements. Values kept on the stack by loops, which are left, are popped.
+// Continue, which targets switch, acts like break. Jump out of try block with finally block is JUMP_OUT
+func (c *Compiler) jumpOut(n ast.Vertex, keyword string) {
+	level := 1
+
+	if n != nil {
+		num, ok := n.(*ast.ScalarLnumber)
+
+		if !ok {
+			panic(fmt.Sprintf("'%s' operator with non-integer operand is no longer supported", keyword))
+		}
+
+		if level, _ = strconv.Atoi(string(num.Value)); level < 1 {
+			panic(fmt.Sprintf("'%s' operator accepts only positive integers", keyword))
+		}
+	}
+
+	target := c.context.Loop(level)
+
+	if target == nil {
+		if c.context.Loop(1) == nil {
+			panic(fmt.Sprintf("'%s' not in the 'loop' or 'switch' context", keyword))
+		}
+
+		panic(fmt.Sprintf("Cannot '%s' %d levels", keyword, level))
+	}
+
+	for i := 1; i < level; i++ {
+		for j := 0; j < c.context.Loop(i).Values; j++ {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+		}
+	}
+
+	op := vm.OpJump
+
+	if target.Finally > 0 {
+		op = vm.OpJumpOut
+	}
+
+	if keyword == "continue" && !target.Switch {
+		target.Continues = append(target.Continues, c.emitJump(op))
+	} else {
+		target.Breaks = append(target.Breaks, c.emitJump(op))
+	}
+}
+
+// ExprMatch compiles match expression. Subject is compared with conditions of arms strictly:
+//
+//	match ($x) { A, B => C, default => D }
+//	=> $x, DUP, A, IDENTICAL, JUMP_TRUE c, DUP, B, IDENTICAL, JUMP_TRUE c, JUMP d,
+//	   c: POP, C, JUMP end, d: POP, D, end:
+//
+// Without default arm, subject, which matches no arm, raises UnhandledMatchError instead of jump to default arm
+func (c *Compiler) ExprMatch(n *ast.ExprMatch) {
+	c.require(matchExpression)
+	n.Expr.Accept(c)
+
+	matches := make([][]int, len(n.Arms))
+	def := -1
+
+	for i, arm := range n.Arms {
+		arm := arm.(*ast.MatchArm)
+
+		if arm.DefaultTkn != nil {
+			def = i
+			continue
+		}
+
+		for _, cond := range arm.Exprs {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+			cond.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIdentical))
+			matches[i] = append(matches[i], c.emitJump(vm.OpJumpTrue))
+		}
+	}
+
+	if def < 0 {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMatchError))
+	} else {
+		matches[def] = append(matches[def], c.emitJump(vm.OpJump))
+	}
+
+	exits := make([]int, 0, len(n.Arms))
+
+	for i, arm := range n.Arms {
+		for _, jump := range matches[i] {
+			c.patchJump(jump)
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+		arm.(*ast.MatchArm).ReturnExpr.Accept(c)
+		exits = append(exits, c.emitJump(vm.OpJump))
+	}
+
+	for _, jump := range exits {
+		c.patchJump(jump)
+	}
+}
+
+// StmtTry compiles try block followed by dispatch of catch blocks and finally block:
+//
+//	try { A } catch (E $e) { B } finally { C }
+//	=> A, CONST null, JUMP finally,
+//	   catch: DUP, CONST "E", INSTANCE_OF, JUMP_TRUE b, JUMP next, b: ASSIGN $e, POP, B, CONST null, JUMP finally,
+//	   next: THROW, finally: C, END_FINALLY
+//
+// Without finally block null markers and END_FINALLY are omitted
+func (c *Compiler) StmtTry(n *ast.StmtTry) {
+	handler := vm.Handler{Start: len(*c.context.Bytecode()) >> 3, Depth: c.stackDepth}
+
+	if n.Finally != nil {
+		c.openFinally(1)
+	}
+
+	for _, stmt := range n.Stmts {
+		stmt.Accept(c)
+	}
+
+	if n.Finally != nil {
+		c.null()
+	}
+
+	exits := []int{c.emitJump(vm.OpJump)}
+
+	if len(n.Catches) > 0 {
+		handler.Catch = len(*c.context.Bytecode()) >> 3
+
+		for _, clause := range n.Catches {
+			clause := clause.(*ast.StmtCatch)
+			var matches []int
+
+			for _, t := range clause.Types {
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+				c.classRef(t)
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpInstanceOf))
+				matches = append(matches, c.emitJump(vm.OpJumpTrue))
+			}
+
+			next := c.emitJump(vm.OpJump)
+
+			for _, match := range matches {
+				c.patchJump(match)
+			}
+
+			if clause.Var != nil {
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssign))
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(clause.Var, VariableAliasType))))
+			}
+
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+
+			for _, stmt := range clause.Stmts {
+				stmt.Accept(c)
+			}
+
+			if n.Finally != nil {
+				c.null()
+			}
+
+			exits = append(exits, c.emitJump(vm.OpJump))
+			c.patchJump(next)
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThrow))
+	}
+
+	for _, exit := range exits {
+		c.patchJump(exit)
+	}
+
+	if n.Finally != nil {
+		c.openFinally(-1)
+		handler.Finally = len(*c.context.Bytecode()) >> 3
+
+		for _, stmt := range n.Finally.(*ast.StmtFinally).Stmts {
+			stmt.Accept(c)
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEndFinally))
+	}
+
+	c.context.AddHandler(handler)
+}
+
+// openFinally counts try statement with finally block in loops, which enclose it
+func (c *Compiler) openFinally(delta int) {
+	for level := 1; c.context.Loop(level) != nil; level++ {
+		c.context.Loop(level).Finally += delta
+	}
+}
+
+func (c *Compiler) StmtThrow(n *ast.StmtThrow) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThrow))
+}
+
+func (c *Compiler) ExprThrow(n *ast.ExprThrow) {
+	c.require(throwExpression)
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThrow))
+}
+
+func (c *Compiler) ExprClone(n *ast.ExprClone) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpClone))
+}
+
+func (c *Compiler) ExprYield(n *ast.ExprYield) {
+	c.generatorContext()
+
+	if n.Key != nil {
+		n.Key.Accept(c)
+	}
+
+	if n.Val != nil {
+		n.Val.Accept(c)
+	} else {
+		c.null()
+	}
+
+	argc := 1
+
+	if n.Key != nil {
+		argc = 2
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpYield))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+}
+
+func (c *Compiler) ExprYieldFrom(n *ast.ExprYieldFrom) {
+	c.generatorContext()
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpYieldFrom))
+}
+
+// generatorContext marks function being compiled as generator
+func (c *Compiler) generatorContext() {
+	ctx, ok := c.context.(*internal.FunctionContext)
+
+	if !ok {
+		panic("The \"yield\" expression can only be used inside a function")
+	}
+
+	ctx.Generator = true
+}
+
+func (c *Compiler) StmtGoto(n *ast.StmtGoto) {
+	name := c.context.Resolve(n.Label, "")
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpJump))
+	c.context.AddLabel(name, uint64(len(*c.context.Bytecode())))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNoop))
+}
+
+func (c *Compiler) StmtLabel(n *ast.StmtLabel) {
+	label := c.context.Resolve(n.Name, "")
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[c.context.FindLabel(label):], uint64(len(*c.context.Bytecode()))>>3)
+}
+
+func (c *Compiler) StmtEcho(n *ast.StmtEcho) {
+	c.line(n)
+
+	for _, expr := range n.Exprs {
+		expr.Accept(c)
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEcho))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(len(n.Exprs)))
+}
+
+// StmtGlobal binds local variables to global ones:
+//
+//	global $a => GLOBAL_REF $a, BIND $a
+func (c *Compiler) StmtGlobal(n *ast.StmtGlobal) {
+	for _, v := range n.Vars {
+		name := v.(*ast.ExprVariable).Name
+		global := c.global.Var(c.global.Resolve(name, VariableAliasType))
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpGlobalRef))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(global))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBind))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(name, VariableAliasType))))
+	}
+}
+
+func (c *Compiler) StmtStatic(n *ast.StmtStatic) {
+	for _, v := range n.Vars {
+		v.Accept(c)
+	}
+}
+
+// StmtStaticVar binds local variable to storage, which persists across calls. Initial value is a constant expression,
+// which is evaluated at compile time:
+//
+//	static $a = 0 => STATIC_REF 0, BIND $a
+func (c *Compiler) StmtStaticVar(n *ast.StmtStaticVar) {
+	static := len(c.statics)
+	c.statics = append(c.statics, vm.Null{})
+
+	if n.Expr != nil {
+		scope, expr := c.class, n.Expr
+		c.initializers = append(c.initializers, func() { c.statics[static] = c.constantExpr(scope, expr) })
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticRef))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(static))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBind))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(n.Var.(*ast.ExprVariable).Name, VariableAliasType))))
+}
+
+// StmtUnset compiles unset of variables, array elements and properties. Containers are fetched quietly,
+// so unset of missing elements does nothing:
+//
+//	unset($a, $b['x'], $c->p) => UNSET $a, LOAD_QUIET $b, CONST 'x', ARRAY_UNSET, LOAD_QUIET $c, CONST 'p', PROP_UNSET
+func (c *Compiler) StmtUnset(n *ast.StmtUnset) {
+	for _, v := range n.Vars {
+		switch v := v.(type) {
+		case *ast.ExprVariable:
+			if identifier(v) == "$this" {
+				panic("Cannot unset $this")
+			}
+
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpUnset))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(v.Name, VariableAliasType))))
+		case *ast.ExprArrayDimFetch:
+			if v.Dim == nil {
+				panic("Cannot use [] for unsetting")
+			}
+
+			c.issetOperand(v.Var)
+			v.Dim.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayUnset))
+		case *ast.ExprPropertyFetch:
+			c.issetOperand(v.Var)
+			c.propertyName(v.Prop)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyUnset))
+		case *ast.ExprStaticPropertyFetch:
+			panic("Attempt to unset static property")
+		default:
+			panic("not implemented")
+		}
+	}
+}
+
+func (c *Compiler) ExprFunctionCall(n *ast.ExprFunctionCall) {
+	if n.EllipsisTkn != nil {
+		c.require(firstClassCallables)
+
+		switch n.Function.(type) {
+		case *ast.Name, *ast.NameFullyQualified, *ast.NameRelative:
+			c.constant(n.Function, vm.String(c.context.Resolve(n.Function, FunctionAliasType)))
+		default:
+			n.Function.Accept(c)
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallable))
+		return
+	}
+
+	switch n.Function.(type) {
+	case *ast.Name, *ast.NameFullyQualified, *ast.NameRelative:
+	default:
+		n.Function.Accept(c)
+		argc := c.arguments(n.Args)
+		c.line(n)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallDynamic))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+		return
+	}
+
+	name := c.context.Resolve(n.Function, FunctionAliasType)
+	params, ok := c.signatures[name]
+
+	if f := slices.IndexFunc(c.contexts, func(context *internal.FunctionContext) bool { return context.Name == name }); f >= 0 {
+		params, ok = c.contexts[f].Args, true
+	}
+
+	// Unknown functions, calls with unpacked or named arguments and calls, which pass more arguments than
+	// parameters, are resolved at runtime by name
+	if !ok || packed(n.Args) || !fitsParams(params, len(n.Args)) {
+		c.constant(n.Function, vm.String(name))
+		argc := c.arguments(n.Args)
+		c.line(n)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallDynamic))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+		return
+	}
+
+	for i, arg := range params {
+		if arg.Variadic {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayNew))
+
+			for _, extra := range n.Args[min(i, len(n.Args)):] {
+				if !arg.IsRef || !refArgument(extra) {
+					c.ExprArrayItem(&ast.ExprArrayItem{Val: extra})
+					continue
+				}
+
+				// variables passed to variadic parameter by reference are bound to elements of packed array
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+				extra.Accept(c)
+				binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayBind))
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 0)
+			}
+
+			break
+		}
+
+		// the callee evaluates default value of argument, which is not passed, or reports it missing
+		if i >= len(n.Args) {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpSkipArg))
+			continue
+		}
+
+		n.Args[i].Accept(c)
+
+		if arg.IsRef && refArgument(n.Args[i]) {
+			binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+		}
+	}
+
+	c.line(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCall))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Function(name)))
+}
+
+// line records that following instructions are compiled from the line, where n starts
+func (c *Compiler) line(n ast.Vertex) {
+	if pos := n.GetPosition(); pos != nil {
+		c.context.AddLine(pos.StartLine)
+	}
+}
+
+// refArgument reports whether argument is a variable, which is loaded by reference for by-ref parameter
+func refArgument(arg ast.Vertex) bool {
+	if a, ok := arg.(*ast.Argument); ok {
+		arg = a.Expr
+	}
+
+	v, ok := arg.(*ast.ExprVariable)
+	return ok && identifier(v) != "$this"
+}
+
+// fitsParams reports whether argc positional arguments do not exceed params
+func fitsParams(params []internal.Arg, argc int) bool {
+	return argc <= len(params) || len(params) > 0 && params[len(params)-1].Variadic
+}
+
+// packed reports whether arguments of a call have to be packed into an array, which is spread by the callee
+func packed(args []ast.Vertex) bool {
+	return slices.ContainsFunc(args, func(v ast.Vertex) bool {
+		arg := v.(*ast.Argument)
+		return arg.Name != nil || arg.VariadicTkn != nil
+	})
+}
+
+// arguments compiles arguments of a call, which is resolved at runtime, and returns the number of values passed
+// to the call instruction. Variables are loaded by reference, as the callee is not known. The callee binds them
+// to by-ref parameters and dereferences them for the others. Unpacked and named arguments are packed into an array,
+// which is spread over parameters by the callee:
+//
+//	f(1, ...$b, c: $c)
+//	=> ARRAY_NEW, ARRAY_PUSH, 1, ASSIGN_REF, POP, $b, ARRAY_SPREAD, DUP, "c", LOAD_REF $c, ARRAY_BIND 1, PACK_ARGS
+func (c *Compiler) arguments(args []ast.Vertex) int {
+	if !packed(args) {
+		for _, arg := range args {
+			arg.Accept(c)
+
+			if refArgument(arg) {
+				binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+			}
+		}
+
+		return len(args)
+	}
+
+	unpacked, named := false, make(map[string]bool)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayNew))
+
+	for _, arg := range args {
+		arg := arg.(*ast.Argument)
+		ref := arg.VariadicTkn == nil && refArgument(arg.Expr)
+
+		if ref {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+		}
+
+		switch {
+		case arg.VariadicTkn != nil:
+			if len(named) > 0 {
+				panic("Cannot use argument unpacking after named arguments")
+			}
+
+			unpacked = true
+			arg.Expr.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArraySpread))
+			continue
+		case arg.Name != nil:
+			name := identifier(arg.Name)
+
+			if named[name] {
+				panic(fmt.Sprintf("Named parameter $%s overwrites previous argument", name))
+			}
+
+			c.require(namedArguments)
+			named[name] = true
+			c.constant(arg.Name, vm.String(name))
+
+			if !ref {
+				*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+			}
+		case len(named) > 0:
+			panic("Cannot use positional argument after named argument")
+		case unpacked:
+			panic("Cannot use positional argument after argument unpacking")
+		case !ref:
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
+		}
+
+		arg.Expr.Accept(c)
+
+		if ref {
+			var keyed uint64
+
+			if arg.Name != nil {
+				keyed = 1
+			}
+
+			binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayBind))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), keyed)
+			continue
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssignRef))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPackArgs))
+	return 1
+}
+
+func (c *Compiler) ExprClosure(n *ast.ExprClosure) {
+	ctx, depth := c.enterClosure(n.Params, n.StaticTkn != nil)
+
+	for _, use := range n.Uses {
+		ctx.Resolve(use.(*ast.ExprClosureUse).Var, VariableAliasType)
+	}
+
+	ctx.Bound = len(n.Uses)
+	ctx.Return = c.typeDecl(n.ReturnType)
+	c.defaultParams(n.Params)
+	c.assertParams(ctx)
+	body := len(ctx.Instructions) >> 3
+
+	for _, stmt := range n.Stmts {
+		stmt.Accept(c)
+	}
+
+	if !endsWithReturn(n.Stmts) {
+		c.implicitReturn(ctx)
+	}
+
+	if ctx.Generator {
+		generator(ctx, body)
+	}
+
+	c.leaveClosure(depth)
+
+	for _, use := range n.Uses {
+		use := use.(*ast.ExprClosureUse)
+
+		if use.AmpersandTkn != nil {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoadRef))
+		} else {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+		}
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(use.Var, VariableAliasType))))
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpClosure))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Function(ctx.Name)))
+}
+
+// ExprArrowFunction compiles arrow function, which captures by value every variable of enclosing scope used in its body
+func (c *Compiler) ExprArrowFunction(n *ast.ExprArrowFunction) {
+	c.require(arrowFunctions)
+	ctx, depth := c.enterClosure(n.Params, n.StaticTkn != nil)
+	ctx.Return = c.typeDecl(n.ReturnType)
+	c.defaultParams(n.Params)
+	c.assertParams(ctx)
+	body := len(ctx.Instructions) >> 3
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpReturnValue))
+
+	if ctx.Generator {
+		generator(ctx, body)
+	}
+
+	c.leaveClosure(depth)
+
+	ctx.Bound = len(ctx.Variables) - len(ctx.Args)
+
+	for _, name := range ctx.Variables[len(ctx.Args):] {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.variable(name)))
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpClosure))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Function(ctx.Name)))
+}
+
+// generator turns function compiled in ctx into generator. GENERATOR instruction is inserted at the start of body,
+// so the function is suspended right after its arguments are checked. Jumps and handlers are moved accordingly
+func generator(ctx *internal.FunctionContext, body int) {
+	ip := 0
+	ctx.Instructions = vm.Reduce(ctx.Instructions, func(prev vm.Bytecode, operator vm.Operator, operands ...int) vm.Bytecode {
+		if ip == body {
+			prev = binary.NativeEndian.AppendUint64(prev, uint64(vm.OpGenerator))
+		}
+
+		at := ip
+		ip += 1 + len(operands)
+		prev = binary.NativeEndian.AppendUint64(prev, uint64(operator))
+
+		for _, operand := range operands {
+			switch operator {
+			case vm.OpJump, vm.OpJumpTrue, vm.OpJumpFalse, vm.OpJumpOut:
+				// jumps of parameters initialization to the start of body still lead to GENERATOR
+				if operand > body || operand == body && at >= body {
+					operand++
+				}
+			}
+
+			prev = binary.NativeEndian.AppendUint64(prev, uint64(operand))
+		}
+
+		return prev
+	}, nil)
+
+	for i := range ctx.Handlers {
+		h := &ctx.Handlers[i]
+		h.Start++
+
+		if h.Catch > 0 {
+			h.Catch++
+		}
+
+		if h.Finally > 0 {
+			h.Finally++
+		}
+	}
+
+	for i := range ctx.Lines {
+		if ctx.Lines[i].Pc >= body {
+			ctx.Lines[i].Pc++
+		}
+	}
+}
+
+// enterClosure starts compilation of anonymous function with params
+func (c *Compiler) enterClosure(params []ast.Vertex, static bool) (ctx *internal.FunctionContext, depth int) {
+	ctx = c.context.Child(fmt.Sprintf("{closure}#%d", len(c.global.Functions)))
+	ctx.Static = static
+	c.global.Functions = append(c.global.Functions, ctx.Name)
+	c.contexts = append(c.contexts, ctx)
+	c.context = ctx
+	depth, c.stackDepth = c.stackDepth, 0
+
+	for _, param := range params {
+		param.Accept(c)
+	}
+
+	return ctx, depth
+}
+
+func (c *Compiler) leaveClosure(depth int) {
+	c.context = c.context.Parent()
+	c.stackDepth = depth
+}
+
+// variable returns index of variable name in current context declaring it if necessary
+func (c *Compiler) variable(name string) int {
+	return c.context.Var(c.context.Resolve(&ast.Identifier{Value: []byte(name)}, VariableAliasType))
+}
+
+func (c *Compiler) ExprVariable(n *ast.ExprVariable) {
+	if identifier(n) == "$this" {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThis))
+		return
+	}
+
+	name := c.context.Resolve(n.Name, VariableAliasType)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(name)))
+}
+
+func (c *Compiler) ExprConstFetch(n *ast.ExprConstFetch) {
+	name := c.context.Resolve(n.Const, ConstantAliasType)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Constant(name)))
+}
+
+func (c *Compiler) ExprAssign(n *ast.ExprAssign) {
+	switch v := n.Var.(type) {
+	case *ast.ExprArrayDimFetch:
+		c.arrayWriteMode[n.Var] = true
+		n.Var.Accept(c)
+		n.Expr.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssignRef))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+	case *ast.ExprPropertyFetch:
+		v.Var.Accept(c)
+		n.Expr.Accept(c)
+		c.propertyName(v.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyAssign))
+	case *ast.ExprStaticPropertyFetch:
+		c.classRef(v.Class)
+		n.Expr.Accept(c)
+		c.staticPropertyName(v.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticPropertyAssign))
+	case *ast.ExprList:
+		temp := c.listTemp()
+
+		// targets taken by reference are bound to elements of the variable itself => [&$a] = $x
+		if src, ok := n.Expr.(*ast.ExprVariable); ok && identifier(src) != "$this" && refList(v) {
+			n.Expr.Accept(c)
+			binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBind))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(temp.Name, VariableAliasType))))
+			temp.Accept(c)
+		} else {
+			c.ExprAssign(&ast.ExprAssign{Var: temp, Expr: n.Expr})
+		}
+
+		c.destructure(v, temp)
+	default:
+		n.Expr.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssign))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(n.Var, VariableAliasType))))
+	}
+}
+
+// listTemp returns hidden variable, which keeps array destructured by list at current nesting depth
+func (c *Compiler) listTemp() *ast.ExprVariable {
+	return &ast.ExprVariable{Name: &ast.Identifier{Value: []byte(fmt.Sprintf("#list%d", c.lists))}}
+}
+
+// refList reports if any target of list or its nested lists is taken by reference
+func refList(list *ast.ExprList) bool {
+	return slices.ContainsFunc(list.Items, func(v ast.Vertex) bool {
+		item, _ := v.(*ast.ExprArrayItem)
+
+		if item == nil {
+			return false
+		}
+
+		switch nested := item.Val.(type) {
+		case *ast.ExprList:
+			return refList(nested)
+		case *ast.ExprArray:
+			return refList(&ast.ExprList{Items: nested.Items})
+		}
+
+		return item.AmpersandTkn != nil
+	})
+}
+
+// destructure assigns elements of array kept in variable temp to targets of list. Targets taken by reference
+// are bound to elements of the array, then the variable temp is unset:
+//
+//	[$a, 'k' => [$b], &$c] = $x => LOAD $x, ASSIGN #list0, LOAD #list0, CONST 0, ARRAY_ACCESS_READ, ASSIGN $a, POP, ...,
+//	LOAD_REF #list0, CONST 1, ARRAY_ACCESS_WRITE, BIND $c, UNSET #list0
+func (c *Compiler) destructure(list *ast.ExprList, temp *ast.ExprVariable) {
+	if !slices.ContainsFunc(list.Items, func(item ast.Vertex) bool { return item != nil && item.(*ast.ExprArrayItem).Val != nil }) {
+		panic("Cannot use empty list")
+	}
+
+	c.lists++
+	defer func() { c.lists-- }()
+
+	var keyed, unkeyed bool
+	index := 0
+
+	for _, item := range list.Items {
+		item, _ := item.(*ast.ExprArrayItem)
+
+		if item == nil || item.Val == nil {
+			index++
+			continue
+		}
+
+		if item.EllipsisTkn != nil {
+			panic("Spread operator is not supported in assignments")
+		}
+
+		keyed, unkeyed = keyed || item.Key != nil, unkeyed || item.Key == nil
+
+		if keyed && unkeyed {
+			panic("Cannot mix keyed and unkeyed array entries in assignments")
+		}
+
+		key := item.Key
+
+		if key == nil {
+			key = &ast.ScalarLnumber{Value: []byte(strconv.Itoa(index))}
+			index++
+		}
+
+		fetch := &ast.ExprArrayDimFetch{Var: temp, Dim: key}
+
+		// nested short list is parsed as an array literal
+		if nested, ok := item.Val.(*ast.ExprArray); ok {
+			item.Val = &ast.ExprList{Position: nested.Position, Items: nested.Items}
+		}
+
+		if item.AmpersandTkn == nil {
+			c.ExprAssign(&ast.ExprAssign{Var: item.Val, Expr: fetch})
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+			continue
+		}
+
+		c.bind(item.Val, func() {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoadRef))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(temp.Name, VariableAliasType))))
+			key.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+		})
+	}
+
+	// temp bound to the array must not be written through by the next destructuring
+	if refList(list) {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpUnset))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(temp.Name, VariableAliasType))))
+	}
+}
+
+// bind binds target to reference pushed by ref:
+//
+//	&$a => ref, BIND $a
+//	&$a['k'] => LOAD_REF $a, CONST 'k', ref, ARRAY_BIND 1
+//	&$a->b => LOAD $a, ref, CONST 'b', PROP_BIND
+//	&A::$b => CONST 'A', ref, CONST 'b', STATIC_PROP_BIND
+func (c *Compiler) bind(target ast.Vertex, ref func()) {
+	switch t := target.(type) {
+	case *ast.ExprVariable:
+		if identifier(t) == "$this" {
+			panic("Cannot re-assign $this")
+		}
+
+		ref()
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBind))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(t.Name, VariableAliasType))))
+	case *ast.ExprArrayDimFetch:
+		switch v := t.Var.(type) {
+		case *ast.ExprArrayDimFetch, *ast.ExprPropertyFetch, *ast.ExprStaticPropertyFetch:
+			c.arrayWriteMode[t.Var] = true
+			t.Var.Accept(c)
+		case *ast.ExprVariable:
+			t.Var.Accept(c)
+
+			if identifier(v) != "$this" {
+				binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+			}
+		default:
+			t.Var.Accept(c)
+		}
+
+		keyed := 0
+
+		if t.Dim != nil {
+			t.Dim.Accept(c)
+			keyed = 1
+		}
+
+		ref()
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayBind))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(keyed))
+	case *ast.ExprPropertyFetch:
+		t.Var.Accept(c)
+		ref()
+		c.propertyName(t.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyBind))
+	case *ast.ExprStaticPropertyFetch:
+		c.classRef(t.Class)
+		ref()
+		c.staticPropertyName(t.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticPropertyBind))
+	default:
+		panic("Cannot assign reference to non referenceable value")
+	}
+}
+
+func (c *Compiler) ExprAssignBitwiseAnd(n *ast.ExprAssignBitwiseAnd) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignBwAnd, vm.OpBwAnd)
+}
+
+func (c *Compiler) ExprAssignBitwiseOr(n *ast.ExprAssignBitwiseOr) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignBwOr, vm.OpBwOr)
+}
+
+func (c *Compiler) ExprAssignBitwiseXor(n *ast.ExprAssignBitwiseXor) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignBwXor, vm.OpBwXor)
+}
+
+func (c *Compiler) ExprAssignCoalesce(n *ast.ExprAssignCoalesce) {
+	c.require(coalesceAssignment)
+	defer func(temps int) { c.temps = temps }(c.temps)
+	v := c.memoize(n.Var)
+	c.coalesce(v, func() { c.ExprAssign(&ast.ExprAssign{Position: n.Position, Var: v, Expr: n.Expr}) })
+}
+
+// memoize evaluates operands of variable n, which have side effects, into hidden variables once. Returned variable
+// refers to them, so it can be both tested and assigned:
+//
+//	$a[$i++]->p ??= 1 => $i++, ASSIGN #tmp0, POP, $a[#tmp0], ASSIGN #tmp1, POP, #tmp1->p ??= 1
+func (c *Compiler) memoize(n ast.Vertex) ast.Vertex {
+	switch n := n.(type) {
+	case *ast.ExprArrayDimFetch:
+		if n.Dim == nil {
+			return n
+		}
+
+		return &ast.ExprArrayDimFetch{Position: n.Position, Var: c.memoize(n.Var), Dim: c.temp(n.Dim)}
+	case *ast.ExprPropertyFetch:
+		return &ast.ExprPropertyFetch{Position: n.Position, Var: c.temp(n.Var), Prop: c.temp(n.Prop)}
+	case *ast.ExprStaticPropertyFetch:
+		return &ast.ExprStaticPropertyFetch{Position: n.Position, Class: c.temp(n.Class), Prop: n.Prop}
+	case *ast.ExprBrackets:
+		return c.memoize(n.Expr)
+	default:
+		return n
+	}
+}
+
+// temp evaluates expression n into hidden variable and returns the variable. Variables, names and literals
+// are returned as is
+func (c *Compiler) temp(n ast.Vertex) ast.Vertex {
+	switch n.(type) {
+	case *ast.ExprVariable, *ast.Identifier, *ast.Name, *ast.NameFullyQualified, *ast.NameRelative,
+		*ast.ScalarLnumber, *ast.ScalarDnumber, *ast.ScalarString, *ast.ExprConstFetch, *ast.ExprClassConstFetch:
+		return n
+	}
+
+	temp := &ast.ExprVariable{Name: &ast.Identifier{Value: []byte(fmt.Sprintf("#tmp%d", c.temps))}}
+	c.temps++
+	c.ExprAssign(&ast.ExprAssign{Var: temp, Expr: n})
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+
+	return temp
+}
+
+func (c *Compiler) ExprAssignConcat(n *ast.ExprAssignConcat) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignConcat, vm.OpConcat)
+}
+
+func (c *Compiler) ExprAssignPow(n *ast.ExprAssignPow) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignPow, vm.OpPow)
+}
+
+func (c *Compiler) ExprAssignShiftLeft(n *ast.ExprAssignShiftLeft) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignShiftLeft, vm.OpShiftLeft)
+}
+
+func (c *Compiler) ExprAssignShiftRight(n *ast.ExprAssignShiftRight) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignShiftRight, vm.OpShiftRight)
+}
+
+// ExprAssignReference => $a = &$b. Reference bound to a variable is loaded back as value of the expression.
+// Reference bound to an element or a property is kept in a hidden variable meanwhile:
+//
+//	$a = &$b->p => LOAD $b, CONST 'p', PROP_REF, BIND $a, LOAD $a
+//	$a->p = &$b['k'] => LOAD_REF $b, CONST 'k', ARRAY_ACCESS_WRITE, BIND #tmp0, LOAD $a, LOAD_REF #tmp0, CONST 'p',
+//	PROP_BIND, LOAD #tmp0, UNSET #tmp0
+func (c *Compiler) ExprAssignReference(n *ast.ExprAssignReference) {
+	if v, ok := n.Var.(*ast.ExprVariable); ok {
+		c.bind(v, func() { c.reference(n.Expr) })
+		v.Accept(c)
+		return
+	}
+
+	defer func(temps int) { c.temps = temps }(c.temps)
+	temp := c.context.Var(c.context.Resolve(&ast.Identifier{Value: []byte(fmt.Sprintf("#tmp%d", c.temps))}, VariableAliasType))
+	c.temps++
+
+	c.reference(n.Expr)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBind))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(temp))
+	c.bind(n.Var, func() {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoadRef))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(temp))
+	})
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(temp))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpUnset))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(temp))
+}
+
+// reference pushes reference to variable, array element or property n
+//
+//	&$a => LOAD_REF $a
+//	&$a['k'] => LOAD_REF $a, CONST 'k', ARRAY_ACCESS_WRITE
+//	&$a->b => LOAD $a, CONST 'b', PROP_REF
+//	&A::$b => CONST 'A', CONST 'b', STATIC_PROP_REF
+func (c *Compiler) reference(n ast.Vertex) {
+	switch n := n.(type) {
+	case *ast.ExprVariable:
+		if identifier(n) == "$this" {
+			panic("Cannot assign reference to non referenceable value")
+		}
+
+		n.Accept(c)
+		binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+	case *ast.ExprArrayDimFetch:
+		c.reference(n.Var)
+
+		if n.Dim == nil {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
+		} else {
+			n.Dim.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+		}
+	case *ast.ExprPropertyFetch:
+		n.Var.Accept(c)
+		c.propertyName(n.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyRef))
+	case *ast.ExprStaticPropertyFetch:
+		c.classRef(n.Class)
+		c.staticPropertyName(n.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticPropertyRef))
+	case *ast.ExprBrackets:
+		c.reference(n.Expr)
+	default:
+		panic("Cannot assign reference to non referenceable value")
+	}
+}
+
+func (c *Compiler) ExprArray(n *ast.ExprArray) {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayNew))
+
+	for _, item := range n.Items {
+		item.Accept(c)
+	}
+}
+
+func (c *Compiler) ExprArrayDimFetch(n *ast.ExprArrayDimFetch) {
+	if n.Dim == nil {
+		c.arrayWriteMode[n.Var] = true
+		n.Var.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
+	} else {
+		if c.arrayWriteMode[n] {
+			switch n.Var.(type) {
+			case *ast.ExprArrayDimFetch, *ast.ExprPropertyFetch, *ast.ExprStaticPropertyFetch:
+				c.arrayWriteMode[n.Var] = true
+			}
+			n.Var.Accept(c)
+			n.Dim.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+		} else {
+			defer c.chain(n, n.Var)()
+			n.Var.Accept(c)
+			n.Dim.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessRead))
+		}
+	}
+}
+
+func (c *Compiler) ExprArrayItem(n *ast.ExprArrayItem) {
+	if n.EllipsisTkn != nil {
+		c.require(arraySpread)
+		n.Val.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArraySpread))
+		return
+	}
+
+	if n.Key == nil {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
+	} else {
+		n.Key.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+	}
+	n.Val.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssignRef))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+}
+
+func (c *Compiler) ExprPostInc(n *ast.ExprPostInc) {
+	if isMember(n.Var) {
+		c.propertyCompoundAssign(n.Var, vm.OpAdd, func() { c.constant(n, vm.Int(1)) })
+		c.constant(n, vm.Int(1))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpSub))
+		return
+	}
+
+	n.Var.Accept(c)
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPostIncrement))
+}
+
+func (c *Compiler) ExprPreInc(n *ast.ExprPreInc) {
+	if isMember(n.Var) {
+		c.propertyCompoundAssign(n.Var, vm.OpAdd, func() { c.constant(n, vm.Int(1)) })
+		return
+	}
+
+	n.Var.Accept(c)
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPreIncrement))
+}
+
+func (c *Compiler) ExprPostDec(n *ast.ExprPostDec) {
+	if isMember(n.Var) {
+		c.propertyCompoundAssign(n.Var, vm.OpSub, func() { c.constant(n, vm.Int(1)) })
+		c.constant(n, vm.Int(1))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAdd))
+		return
+	}
+
+	n.Var.Accept(c)
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPostDecrement))
+}
+
+func (c *Compiler) ExprPreDec(n *ast.ExprPreDec) {
+	if isMember(n.Var) {
+		c.propertyCompoundAssign(n.Var, vm.OpSub, func() { c.constant(n, vm.Int(1)) })
+		return
+	}
+
+	n.Var.Accept(c)
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPreDecrement))
+}
+
+func (c *Compiler) ExprAssignDiv(n *ast.ExprAssignDiv) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignDiv, vm.OpDiv)
+}
+
+func (c *Compiler) ExprAssignMinus(n *ast.ExprAssignMinus) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignSub, vm.OpSub)
+}
+
+func (c *Compiler) ExprAssignMod(n *ast.ExprAssignMod) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignMod, vm.OpMod)
+}
+
+func (c *Compiler) ExprAssignMul(n *ast.ExprAssignMul) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignMul, vm.OpMul)
+}
+
+func (c *Compiler) ExprAssignPlus(n *ast.ExprAssignPlus) {
+	c.compoundAssign(n.Var, n.Expr, vm.OpAssignAdd, vm.OpAdd)
+}
+
+func (c *Compiler) ExprBinaryIdentical(n *ast.ExprBinaryIdentical) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIdentical))
+}
+
+func (c *Compiler) ExprBinaryMinus(n *ast.ExprBinaryMinus) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpSub))
+}
+
+func (c *Compiler) ExprBinaryPlus(n *ast.ExprBinaryPlus) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAdd))
+}
+
+func (c *Compiler) ExprBinaryBitwiseAnd(n *ast.ExprBinaryBitwiseAnd) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwAnd))
+}
+
+func (c *Compiler) ExprBinaryBitwiseOr(n *ast.ExprBinaryBitwiseOr) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwOr))
+}
+
+func (c *Compiler) ExprBinaryBitwiseXor(n *ast.ExprBinaryBitwiseXor) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwXor))
+}
+
+func (c *Compiler) ExprBitwiseNot(n *ast.ExprBitwiseNot) {
+	n.Expr.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwNot))
+}
+
+func (c *Compiler) ExprBinaryBooleanAnd(n *ast.ExprBinaryBooleanAnd) {
+	c.shortCircuit(n.Left, n.Right, vm.OpJumpFalse, "false")
+}
+
+func (c *Compiler) ExprBinaryBooleanOr(n *ast.ExprBinaryBooleanOr) {
+	c.shortCircuit(n.Left, n.Right, vm.OpJumpTrue, "true")
+}
+
+// shortCircuit compiles && and || operators. Right operand is not evaluated, if jump is taken on the left one,
+// in this case result is the constant named result. Otherwise, result is the right operand cast to bool
+func (c *Compiler) shortCircuit(left, right ast.Vertex, jump vm.Operator, result string) {
+	left.Accept(c)
+	skip := c.emitJump(jump)
+	right.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.BoolType))
+	exit := c.emitJump(vm.OpJump)
+	c.patchJump(skip)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.global.NamedConstants[result]))
+	c.patchJump(exit)
+}
+
+func (c *Compiler) ExprBinaryCoalesce(n *ast.ExprBinaryCoalesce) {
+	c.coalesce(n.Left, func() { n.Right.Accept(c) })
+}
+
+// coalesce compiles n ?? expr. Right operand is evaluated only if n is not set
+func (c *Compiler) coalesce(n ast.Vertex, expr func()) {
+	c.issetOperand(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIsSet))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 1)
+	exit := c.emitJump(vm.OpJumpTrue)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+	expr()
+	c.patchJump(exit)
+}
+
+func (c *Compiler) ExprBinaryConcat(n *ast.ExprBinaryConcat) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConcat))
+}
+
+func (c *Compiler) ExprBinaryDiv(n *ast.ExprBinaryDiv) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDiv))
+}
+
+func (c *Compiler) ExprBinaryEqual(n *ast.ExprBinaryEqual) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEqual))
 }
 
-func (c *Compiler) ExprVariable(n *ast.ExprVariable) {
-	name := c.context.Resolve(n.Name, VariableAliasType)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(name)))
+func (c *Compiler) ExprBinaryGreater(n *ast.ExprBinaryGreater) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpGreater))
 }
 
-func (c *Compiler) ExprConstFetch(n *ast.ExprConstFetch) {
-	name := c.context.Resolve(n.Const, ConstantAliasType)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Constant(name)))
+func (c *Compiler) ExprBinaryGreaterOrEqual(n *ast.ExprBinaryGreaterOrEqual) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpGreaterOrEqual))
+}
+
+func (c *Compiler) ExprBinaryLogicalAnd(n *ast.ExprBinaryLogicalAnd) {
+	c.shortCircuit(n.Left, n.Right, vm.OpJumpFalse, "false")
+}
+
+func (c *Compiler) ExprBinaryLogicalOr(n *ast.ExprBinaryLogicalOr) {
+	c.shortCircuit(n.Left, n.Right, vm.OpJumpTrue, "true")
+}
+
+func (c *Compiler) ExprBinaryLogicalXor(n *ast.ExprBinaryLogicalXor) {
+	n.Left.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.BoolType))
+	n.Right.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.BoolType))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNotIdentical))
+}
+
+func (c *Compiler) ExprBinaryMod(n *ast.ExprBinaryMod) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMod))
+}
+
+func (c *Compiler) ExprBinaryMul(n *ast.ExprBinaryMul) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMul))
+}
+
+func (c *Compiler) ExprBinaryNotEqual(n *ast.ExprBinaryNotEqual) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNotEqual))
+}
+
+func (c *Compiler) ExprBinaryNotIdentical(n *ast.ExprBinaryNotIdentical) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNotIdentical))
+}
+
+func (c *Compiler) ExprBinaryPow(n *ast.ExprBinaryPow) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPow))
+}
+
+func (c *Compiler) ExprBinaryShiftLeft(n *ast.ExprBinaryShiftLeft) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpShiftLeft))
+}
+
+func (c *Compiler) ExprBinaryShiftRight(n *ast.ExprBinaryShiftRight) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpShiftRight))
+}
+
+func (c *Compiler) ExprBinarySmaller(n *ast.ExprBinarySmaller) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLess))
+}
+
+func (c *Compiler) ExprBinarySmallerOrEqual(n *ast.ExprBinarySmallerOrEqual) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLessOrEqual))
+}
+
+func (c *Compiler) ExprBinarySpaceship(n *ast.ExprBinarySpaceship) {
+	n.Left.Accept(c)
+	n.Right.Accept(c)
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCompare))
+}
+
+func (c *Compiler) ExprCastArray(n *ast.ExprCastArray) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.ArrayType))
+}
+
+func (c *Compiler) ExprCastBool(n *ast.ExprCastBool) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.BoolType))
+}
+
+func (c *Compiler) ExprCastDouble(n *ast.ExprCastDouble) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.FloatType))
+}
+
+func (c *Compiler) ExprCastInt(n *ast.ExprCastInt) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.IntType))
+}
+
+func (c *Compiler) ExprCastObject(n *ast.ExprCastObject) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.ObjectType))
+}
+
+func (c *Compiler) ExprCastString(n *ast.ExprCastString) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.StringType))
+}
+
+func (c *Compiler) ExprCastUnset(n *ast.ExprCastUnset) {
+	n.Expr.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.NullType))
+}
+
+func (c *Compiler) ExprPropertyFetch(n *ast.ExprPropertyFetch) {
+	defer c.chain(n, n.Var)()
+	n.Var.Accept(c)
+	c.propertyName(n.Prop)
+
+	if c.arrayWriteMode[n] {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyWrite))
+	} else {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyFetch))
+	}
+}
+
+// ExprNullsafePropertyFetch => $a?->b. Rest of the chain of member accesses is skipped, if $a is null
+func (c *Compiler) ExprNullsafePropertyFetch(n *ast.ExprNullsafePropertyFetch) {
+	c.require(nullsafeOperator)
+
+	if c.arrayWriteMode[n] {
+		panic("Can't use nullsafe operator in write context")
+	}
+
+	defer c.chain(n, n.Var)()
+	n.Var.Accept(c)
+	c.nullsafeCheck()
+	c.propertyName(n.Prop)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyFetch))
+}
+
+// ExprNullsafeMethodCall => $a?->b(). Arguments are not evaluated, if $a is null
+func (c *Compiler) ExprNullsafeMethodCall(n *ast.ExprNullsafeMethodCall) {
+	c.require(nullsafeOperator)
+
+	if n.EllipsisTkn != nil {
+		panic("Cannot combine nullsafe operator with Closure creation")
+	}
+
+	defer c.chain(n, n.Var)()
+	n.Var.Accept(c)
+	c.nullsafeCheck()
+	argc := c.arguments(n.Args)
+	c.propertyName(n.Method)
+	c.line(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallMethod))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+}
+
+// nullsafeCheck jumps to the end of the chain of member accesses keeping null on the stack, if accessed value is null
+//
+//	$a?->b->c => LOAD $a, DUP, ISSET 1, JUMP_FALSE end, CONST "b", PROP_FETCH, CONST "c", PROP_FETCH, end:
+func (c *Compiler) nullsafeCheck() {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIsSet))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), 1)
+	c.nullsafe = append(c.nullsafe, c.emitJump(vm.OpJumpFalse))
+}
+
+// chain marks v as accessed by member access n. Returned function patches jumps of nullsafe operators
+// at the end of the outermost access of the chain
+func (c *Compiler) chain(n, v ast.Vertex) func() {
+	c.chained[v] = true
+	start := len(c.nullsafe)
+
+	return func() {
+		if !c.chained[n] {
+			c.patchJumps(c.nullsafe[start:])
+			c.nullsafe = c.nullsafe[:start]
+		}
+	}
+}
+
+// propertyName compiles name of property or method, which can be either identifier or expression
+func (c *Compiler) propertyName(n ast.Vertex) {
+	if id, ok := n.(*ast.Identifier); ok {
+		c.constant(n, vm.String(id.Value))
+	} else {
+		n.Accept(c)
+	}
+}
+
+// staticPropertyName compiles name of static property, which is a variable or a variable variable
+func (c *Compiler) staticPropertyName(n ast.Vertex) {
+	if v, ok := n.(*ast.ExprVariable); ok {
+		if id, ok := v.Name.(*ast.Identifier); ok {
+			c.constant(n, vm.String(strings.TrimPrefix(string(id.Value), "$")))
+			return
+		}
+
+		v.Name.Accept(c)
+		return
+	}
+
+	n.Accept(c)
+}
+
+// isMember reports if n is a property or static property fetch
+func isMember(n ast.Vertex) bool {
+	switch n.(type) {
+	case *ast.ExprPropertyFetch, *ast.ExprStaticPropertyFetch:
+		return true
+	default:
+		return false
+	}
+}
+
+// propertyCompoundAssign => $x->prop += $y, X::$prop += $y
+func (c *Compiler) propertyCompoundAssign(n ast.Vertex, op vm.Operator, expr func()) {
+	var name func()
+	var fetch, assign vm.Operator
+
+	switch n := n.(type) {
+	case *ast.ExprPropertyFetch:
+		n.Var.Accept(c)
+		name = func() { c.propertyName(n.Prop) }
+		fetch, assign = vm.OpPropertyFetch, vm.OpPropertyAssign
+	case *ast.ExprStaticPropertyFetch:
+		c.classRef(n.Class)
+		name = func() { c.staticPropertyName(n.Prop) }
+		fetch, assign = vm.OpStaticPropertyFetch, vm.OpStaticPropertyAssign
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+	name()
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(fetch))
+	expr()
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(op))
+	name()
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(assign))
+}
+
+// compoundAssign => $x += $y
+func (c *Compiler) compoundAssign(v, expr ast.Vertex, assignOp, op vm.Operator) {
+	if isMember(v) {
+		c.propertyCompoundAssign(v, op, func() { expr.Accept(c) })
+		return
+	}
+
+	expr.Accept(c)
+	v.Accept(c)
+
+	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(assignOp))
+}
+
+// classRef pushes class referenced by n, which is either a name or an expression evaluated to object or class name
+func (c *Compiler) classRef(n ast.Vertex) {
+	switch n.(type) {
+	case *ast.Name, *ast.NameFullyQualified, *ast.NameRelative, *ast.Identifier:
+		c.constant(n, vm.String(c.className(n)))
+	default:
+		n.Accept(c)
+	}
+}
+
+// ExprNew => new A(1). Anonymous class is declared, when it is compiled, and instantiated by name => new class(1) {}
+func (c *Compiler) ExprNew(n *ast.ExprNew) {
+	args := n.Args
+
+	if class, ok := n.Class.(*ast.StmtClass); ok {
+		c.StmtClass(class)
+		c.constant(n, c.classes[len(c.classes)-1].Name)
+		args = class.Args
+	} else {
+		c.classRef(n.Class)
+	}
+
+	argc := c.arguments(args)
+	c.line(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNew))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+}
+
+func (c *Compiler) ExprStaticPropertyFetch(n *ast.ExprStaticPropertyFetch) {
+	c.classRef(n.Class)
+	c.staticPropertyName(n.Prop)
+
+	if c.arrayWriteMode[n] {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticPropertyWrite))
+	} else {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpStaticPropertyFetch))
+	}
+}
+
+func (c *Compiler) ExprClassConstFetch(n *ast.ExprClassConstFetch) {
+	name := identifier(n.Const)
+
+	if strings.EqualFold(name, "class") {
+		switch class := c.className(n.Class); strings.ToLower(class) {
+		case "self", "parent", "static", "":
+		default:
+			c.constant(n, vm.String(class))
+			return
+		}
+	}
+
+	c.classRef(n.Class)
+	c.constant(n.Const, vm.String(name))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpClassConstFetch))
+}
+
+func (c *Compiler) ExprMethodCall(n *ast.ExprMethodCall) {
+	defer c.chain(n, n.Var)()
+	n.Var.Accept(c)
+
+	if n.EllipsisTkn != nil {
+		c.require(firstClassCallables)
+		c.propertyName(n.Method)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMethodCallable))
+		return
+	}
+
+	argc := c.arguments(n.Args)
+	c.propertyName(n.Method)
+	c.line(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallMethod))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+}
+
+func (c *Compiler) ExprStaticCall(n *ast.ExprStaticCall) {
+	c.classRef(n.Class)
+
+	if n.EllipsisTkn != nil {
+		c.require(firstClassCallables)
+		c.propertyName(n.Call)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMethodCallable))
+		return
+	}
+
+	argc := c.arguments(n.Args)
+	c.propertyName(n.Call)
+	c.line(n)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCallStatic))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(argc))
+}
+
+func (c *Compiler) ExprInstanceOf(n *ast.ExprInstanceOf) {
+	n.Expr.Accept(c)
+	c.classRef(n.Class)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpInstanceOf))
+}
+
+// ExprIsset => isset($a, $b->c). Property tested itself is checked by __isset() without reading it by __get()
+func (c *Compiler) ExprIsset(n *ast.ExprIsset) {
+	for _, v := range n.Vars {
+		for b, ok := v.(*ast.ExprBrackets); ok; b, ok = v.(*ast.ExprBrackets) {
+			v = b.Expr
+		}
+
+		if p, ok := v.(*ast.ExprPropertyFetch); ok {
+			c.issetOperand(p.Var)
+			c.propertyName(p.Prop)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyIsSet))
+		} else {
+			c.issetOperand(v)
+		}
+	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIsSet))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(len(n.Vars)))
+}
+
+// issetOperand compiles operand of isset() and ??. Array elements and properties are fetched without notices,
+// missing ones give null
+func (c *Compiler) issetOperand(n ast.Vertex) {
+	switch n := n.(type) {
+	case *ast.ExprArrayDimFetch:
+		if n.Dim != nil {
+			c.issetOperand(n.Var)
+			n.Dim.Accept(c)
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessQuiet))
+			return
+		}
+	case *ast.ExprPropertyFetch:
+		c.issetOperand(n.Var)
+		c.propertyName(n.Prop)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyFetchQuiet))
+		return
+	case *ast.ExprBrackets:
+		c.issetOperand(n.Expr)
+		return
+	case *ast.ExprVariable:
+		if identifier(n) != "$this" {
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoadQuiet))
+			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(n.Name, VariableAliasType))))
+			return
+		}
+	}
+
+	n.Accept(c)
 }
 
-func (c *Compiler) ExprAssign(n *ast.ExprAssign) {
-	switch n.Var.(type) {
-	case *ast.ExprArrayDimFetch:
-		c.arrayWriteMode[n.Var] = true
-		n.Var.Accept(c)
-		n.Expr.Accept(c)
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssignRef))
+func (c *Compiler) ExprTernary(n *ast.ExprTernary) {
+	n.Cond.Accept(c)
+
+	if n.IfTrue == nil {
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDup))
+		exit := c.emitJump(vm.OpJumpTrue)
 		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
-	default:
-		n.Expr.Accept(c)
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssign))
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(c.context.Resolve(n.Var, VariableAliasType))))
+		n.IfFalse.Accept(c)
+		c.patchJump(exit)
+		return
 	}
+
+	ifFalse := c.emitJump(vm.OpJumpFalse)
+	n.IfTrue.Accept(c)
+	exit := c.emitJump(vm.OpJump)
+	c.patchJump(ifFalse)
+	n.IfFalse.Accept(c)
+	c.patchJump(exit)
 }
 
-func (c *Compiler) ExprAssignBitwiseAnd(n *ast.ExprAssignBitwiseAnd) {
+func (c *Compiler) ExprBooleanNot(n *ast.ExprBooleanNot) {
 	n.Expr.Accept(c)
-	n.Var.Accept(c)
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignBwAnd))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNot))
 }
 
-func (c *Compiler) ExprAssignBitwiseOr(n *ast.ExprAssignBitwiseOr) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
-
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignBwOr))
+func (c *Compiler) ExprRequire(n *ast.ExprRequire) {
+	panic("not implemented")
 }
 
-func (c *Compiler) ExprAssignBitwiseXor(n *ast.ExprAssignBitwiseXor) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+func (c *Compiler) ExprRequireOnce(n *ast.ExprRequireOnce) {
+	panic("not implemented")
+}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignBwXor))
+func (c *Compiler) ExprInclude(n *ast.ExprInclude) {
+	panic("not implemented")
 }
 
-func (c *Compiler) ExprAssignCoalesce(*ast.ExprAssignCoalesce) {
+func (c *Compiler) ExprIncludeOnce(n *ast.ExprIncludeOnce) {
 	panic("not implemented")
 }
 
-func (c *Compiler) ExprAssignConcat(n *ast.ExprAssignConcat) {
+func (c *Compiler) ExprBrackets(n *ast.ExprBrackets) {
 	n.Expr.Accept(c)
-	n.Var.Accept(c)
+}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignConcat))
+func (c *Compiler) ScalarLnumber(n *ast.ScalarLnumber) {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, c.number(n.Value))))
 }
 
-func (c *Compiler) ExprAssignPow(n *ast.ExprAssignPow) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+func (c *Compiler) ScalarString(n *ast.ScalarString) {
+	c.constant(n, vm.String(unquote(n)))
+}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignPow))
+func (c *Compiler) ScalarDnumber(n *ast.ScalarDnumber) {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, c.number(n.Value))))
 }
 
-func (c *Compiler) ExprAssignShiftLeft(n *ast.ExprAssignShiftLeft) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+// number evaluates decimal, hexadecimal, octal or binary number literal. Integer, which overflows, is float
+func (c *Compiler) number(literal []byte) vm.Value {
+	s := string(literal)
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignShiftLeft))
-}
+	if strings.Contains(s, "_") {
+		c.require(numericSeparator)
+		s = strings.ReplaceAll(s, "_", "")
+	}
 
-func (c *Compiler) ExprAssignShiftRight(n *ast.ExprAssignShiftRight) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+	base, digits := 10, s
+
+	switch {
+	case len(s) > 1 && (s[1] == 'x' || s[1] == 'X'):
+		base, digits = 16, s[2:]
+	case len(s) > 1 && (s[1] == 'b' || s[1] == 'B'):
+		base, digits = 2, s[2:]
+	case len(s) > 1 && (s[1] == 'o' || s[1] == 'O'):
+		c.require(explicitOctal)
+		base, digits = 8, s[2:]
+	case len(s) > 1 && s[0] == '0' && !strings.ContainsAny(s, ".eE"):
+		base, digits = 8, s[1:]
+	}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignShiftRight))
-}
+	if i, err := strconv.ParseInt(digits, base, 64); err == nil {
+		return vm.Int(i)
+	}
 
-func (c *Compiler) ExprAssignReference(n *ast.ExprAssignReference) {
-	n.Expr.Accept(c)
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpLoadRef))
+	if base == 10 {
+		f, _ := strconv.ParseFloat(digits, 64)
+		return vm.Float(f)
+	}
 
-	n.Var.Accept(c)
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssign))
+	var f float64
+
+	for _, d := range digits {
+		v, _ := strconv.ParseUint(string(d), base, 8)
+		f = f*float64(base) + float64(v)
+	}
+
+	return vm.Float(f)
 }
 
-func (c *Compiler) ExprArray(n *ast.ExprArray) {
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayNew))
+func (c *Compiler) ScalarEncapsed(n *ast.ScalarEncapsed) {
+	c.encapsed(n, n.Parts, func(_ int, s string) string { return unescape(s, '"') })
+}
 
-	for _, item := range n.Items {
-		item.Accept(c)
+// ScalarHeredoc compiles heredoc and nowdoc. Indentation of closing marker is removed from every line
+// together with the last line break
+func (c *Compiler) ScalarHeredoc(n *ast.ScalarHeredoc) {
+	var indent string
+
+	if len(n.Parts) > 0 {
+		if last, ok := n.Parts[len(n.Parts)-1].(*ast.ScalarEncapsedStringPart); ok {
+			if i := bytes.LastIndexByte(last.Value, '\n'); i >= 0 {
+				indent = string(last.Value[i+1:])
+			}
+		}
 	}
+
+	nowdoc := bytes.ContainsRune(n.OpenHeredocTkn.Value, '\'')
+
+	c.encapsed(n, n.Parts, func(i int, s string) string {
+		s = strings.ReplaceAll(s, "\n"+indent, "\n")
+
+		if i == 0 {
+			s = strings.TrimPrefix(s, indent)
+		}
+
+		if i == len(n.Parts)-1 {
+			s = strings.TrimSuffix(s, "\n")
+		}
+
+		if nowdoc {
+			return s
+		}
+
+		return unescape(s, 0)
+	})
 }
 
-func (c *Compiler) ExprArrayDimFetch(n *ast.ExprArrayDimFetch) {
-	if n.Dim == nil {
-		c.arrayWriteMode[n.Var] = true
-		n.Var.Accept(c)
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
-	} else {
-		if c.arrayWriteMode[n] {
-			switch n.Var.(type) {
-			case *ast.ExprArrayDimFetch:
-				c.arrayWriteMode[n.Var] = true
-			}
-			n.Var.Accept(c)
-			n.Dim.Accept(c)
-			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
+// encapsed compiles parts of interpolated string and concatenates them with a single instruction.
+// Text of literal parts is produced by text:
+//
+//	"a $b {$c->d}" => CONST "a ", LOAD $b, LOAD $c, CONST "d", PROP_FETCH, CONCAT_N 3
+func (c *Compiler) encapsed(n ast.Vertex, parts []ast.Vertex, text func(i int, s string) string) {
+	dynamic := slices.ContainsFunc(parts, func(part ast.Vertex) bool {
+		_, ok := part.(*ast.ScalarEncapsedStringPart)
+		return !ok
+	})
+
+	if !dynamic {
+		var str strings.Builder
+
+		for i, part := range parts {
+			str.WriteString(text(i, string(part.(*ast.ScalarEncapsedStringPart).Value)))
+		}
+
+		c.constant(n, vm.String(str.String()))
+		return
+	}
+
+	count := 0
+
+	for i, part := range parts {
+		if literal, ok := part.(*ast.ScalarEncapsedStringPart); !ok {
+			part.Accept(c)
+		} else if s := text(i, string(literal.Value)); s != "" {
+			c.constant(part, vm.String(s))
 		} else {
-			n.Var.Accept(c)
-			n.Dim.Accept(c)
-			*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessRead))
+			continue
 		}
+
+		count++
 	}
+
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConcatN))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(count))
 }
 
-func (c *Compiler) ExprArrayItem(n *ast.ExprArrayItem) {
-	if n.Key == nil {
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessPush))
-	} else {
-		n.Key.Accept(c)
-		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessWrite))
-	}
-	n.Val.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssignRef))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+func (c *Compiler) ScalarEncapsedStringPart(n *ast.ScalarEncapsedStringPart) {
+	c.constant(n, vm.String(unescape(string(n.Value), '"')))
 }
 
-func (c *Compiler) ExprPostInc(n *ast.ExprPostInc) {
-	n.Var.Accept(c)
+// ScalarEncapsedStringVar => "${a}", "${a['b']}"
+func (c *Compiler) ScalarEncapsedStringVar(n *ast.ScalarEncapsedStringVar) {
+	name, ok := n.Name.(*ast.Identifier)
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPostIncrement))
-}
+	if !ok {
+		panic("not implemented")
+	}
 
-func (c *Compiler) ExprPreInc(n *ast.ExprPreInc) {
-	n.Var.Accept(c)
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.variable("$"+string(name.Value))))
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPreIncrement))
+	if n.Dim != nil {
+		n.Dim.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArrayAccessRead))
+	}
 }
 
-func (c *Compiler) ExprPostDec(n *ast.ExprPostDec) {
+// ScalarEncapsedStringBrackets => "{$a}"
+func (c *Compiler) ScalarEncapsedStringBrackets(n *ast.ScalarEncapsedStringBrackets) {
 	n.Var.Accept(c)
-
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPostDecrement))
 }
 
-func (c *Compiler) ExprPreDec(n *ast.ExprPreDec) {
-	n.Var.Accept(c)
+// constant pushes literal v, which is bound to vertex n
+func (c *Compiler) constant(n ast.Vertex, v vm.Value) {
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
+	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, v)))
+}
+
+// className resolves name of a class without registering it in function table
+func (c *Compiler) className(n ast.Vertex) string {
+	c.global.Names.Resolve(n, "")
+	return c.global.Names.ResolvedNames[n]
+}
+
+// constantExpr evaluates constant expression used as initializer of class member declared in scope
+func (c *Compiler) constantExpr(scope *vm.Class, n ast.Vertex) vm.Value {
+	switch n := n.(type) {
+	case *ast.ScalarLnumber:
+		return c.number(n.Value)
+	case *ast.ScalarDnumber:
+		return c.number(n.Value)
+	case *ast.ScalarString:
+		return vm.String(unquote(n))
+	case *ast.ExprConstFetch:
+		return c.global.Literals[c.global.Constant(c.className(n.Const))]
+	case *ast.ExprUnaryMinus:
+		switch v := c.constantExpr(scope, n.Expr).(type) {
+		case vm.Float:
+			return -v
+		default:
+			return -v.AsInt(nil)
+		}
+	case *ast.ExprUnaryPlus:
+		return c.constantExpr(scope, n.Expr)
+	case *ast.ExprBrackets:
+		return c.constantExpr(scope, n.Expr)
+	case *ast.ExprArray:
+		arr := vm.NewArray(nil)
+
+		for _, item := range n.Items {
+			item, _ := item.(*ast.ExprArrayItem)
+
+			switch {
+			case item == nil:
+				continue
+			case item.EllipsisTkn != nil:
+				constantSpread(arr, c.constantExpr(scope, item.Val))
+				continue
+			}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpPreDecrement))
-}
+			if item.Key == nil {
+				arr.OffsetSet(nil, arr.NextKey(), c.constantExpr(scope, item.Val))
+			} else {
+				arr.OffsetSet(nil, c.constantExpr(scope, item.Key), c.constantExpr(scope, item.Val))
+			}
+		}
 
-func (c *Compiler) ExprAssignDiv(n *ast.ExprAssignDiv) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+		return arr
+	case *ast.ExprClassConstFetch:
+		class := c.constantClass(scope, n.Class)
+		name := identifier(n.Const)
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignDiv))
-}
+		if strings.EqualFold(name, "class") {
+			return class.Name
+		}
 
-func (c *Compiler) ExprAssignMinus(n *ast.ExprAssignMinus) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+		constant, ok := class.Consts[vm.String(name)]
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignSub))
-}
+		if !ok {
+			panic(fmt.Sprintf("Undefined constant %s::%s", string(class.Name), name))
+		}
 
-func (c *Compiler) ExprAssignMod(n *ast.ExprAssignMod) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+		return c.classConstant(constant)
+	case *ast.ExprBinaryConcat:
+		return c.constantExpr(scope, n.Left).AsString(nil) + c.constantExpr(scope, n.Right).AsString(nil)
+	case *ast.ExprBinaryPlus:
+		return constantArithmetic(c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right), func(x, y vm.Int) vm.Int { return x + y }, func(x, y vm.Float) vm.Float { return x + y })
+	case *ast.ExprBinaryMinus:
+		return constantArithmetic(c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right), func(x, y vm.Int) vm.Int { return x - y }, func(x, y vm.Float) vm.Float { return x - y })
+	case *ast.ExprBinaryMul:
+		return constantArithmetic(c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right), func(x, y vm.Int) vm.Int { return x * y }, func(x, y vm.Float) vm.Float { return x * y })
+	case *ast.ExprBinaryBitwiseOr:
+		return c.constantExpr(scope, n.Left).AsInt(nil) | c.constantExpr(scope, n.Right).AsInt(nil)
+	case *ast.ExprBinaryBitwiseAnd:
+		return c.constantExpr(scope, n.Left).AsInt(nil) & c.constantExpr(scope, n.Right).AsInt(nil)
+	case *ast.ExprBinaryShiftLeft:
+		return c.constantExpr(scope, n.Left).AsInt(nil) << c.constantExpr(scope, n.Right).AsInt(nil)
+	case *ast.ExprBinaryShiftRight:
+		return c.constantExpr(scope, n.Left).AsInt(nil) >> c.constantExpr(scope, n.Right).AsInt(nil)
+	case *ast.ExprBinaryBitwiseXor:
+		return c.constantExpr(scope, n.Left).AsInt(nil) ^ c.constantExpr(scope, n.Right).AsInt(nil)
+	case *ast.ExprBitwiseNot:
+		return ^c.constantExpr(scope, n.Expr).AsInt(nil)
+	case *ast.ExprBinaryDiv:
+		return constantDiv(c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right))
+	case *ast.ExprBinaryMod:
+		x, y := c.constantExpr(scope, n.Left).AsInt(nil), c.constantExpr(scope, n.Right).AsInt(nil)
+
+		if y == 0 {
+			panic("Modulo by zero")
+		}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignMod))
+		return x % y
+	case *ast.ExprBinaryPow:
+		return constantPow(c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right))
+	case *ast.ExprBooleanNot:
+		return !c.constantExpr(scope, n.Expr).AsBool(nil)
+	case *ast.ExprBinaryBooleanAnd:
+		return c.constantExpr(scope, n.Left).AsBool(nil) && c.constantExpr(scope, n.Right).AsBool(nil)
+	case *ast.ExprBinaryLogicalAnd:
+		return c.constantExpr(scope, n.Left).AsBool(nil) && c.constantExpr(scope, n.Right).AsBool(nil)
+	case *ast.ExprBinaryBooleanOr:
+		return c.constantExpr(scope, n.Left).AsBool(nil) || c.constantExpr(scope, n.Right).AsBool(nil)
+	case *ast.ExprBinaryLogicalOr:
+		return c.constantExpr(scope, n.Left).AsBool(nil) || c.constantExpr(scope, n.Right).AsBool(nil)
+	case *ast.ExprBinaryLogicalXor:
+		return vm.Bool(c.constantExpr(scope, n.Left).AsBool(nil) != c.constantExpr(scope, n.Right).AsBool(nil))
+	case *ast.ExprBinaryIdentical:
+		return vm.Bool(c.constantExpr(scope, n.Left) == c.constantExpr(scope, n.Right))
+	case *ast.ExprBinaryNotIdentical:
+		return vm.Bool(c.constantExpr(scope, n.Left) != c.constantExpr(scope, n.Right))
+	case *ast.ExprBinaryEqual:
+		return vm.LooseEqual(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right))
+	case *ast.ExprBinaryNotEqual:
+		return !vm.LooseEqual(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right))
+	case *ast.ExprBinarySmaller:
+		return vm.Bool(vm.Spaceship(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right)) < 0)
+	case *ast.ExprBinarySmallerOrEqual:
+		return vm.Bool(vm.Spaceship(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right)) < 1)
+	case *ast.ExprBinaryGreater:
+		return vm.Bool(vm.Spaceship(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right)) > 0)
+	case *ast.ExprBinaryGreaterOrEqual:
+		return vm.Bool(vm.Spaceship(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right)) > -1)
+	case *ast.ExprBinarySpaceship:
+		return vm.Spaceship(nil, c.constantExpr(scope, n.Left), c.constantExpr(scope, n.Right))
+	case *ast.ExprTernary:
+		cond := c.constantExpr(scope, n.Cond)
+
+		switch {
+		case !bool(cond.AsBool(nil)):
+			return c.constantExpr(scope, n.IfFalse)
+		case n.IfTrue == nil:
+			return cond
+		default:
+			return c.constantExpr(scope, n.IfTrue)
+		}
+	case *ast.ExprBinaryCoalesce:
+		if v := c.constantExpr(scope, n.Left); v != (vm.Null{}) {
+			return v
+		}
+
+		return c.constantExpr(scope, n.Right)
+	default:
+		panic("Constant expression contains invalid operations")
+	}
 }
 
-func (c *Compiler) ExprAssignMul(n *ast.ExprAssignMul) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+// constantDiv divides x by y. Quotient of integers is integer, if there is no remainder
+func constantDiv(x, y vm.Value) vm.Value {
+	if y.AsFloat(nil) == 0 {
+		panic("Division by zero")
+	}
+
+	res := x.AsFloat(nil) / y.AsFloat(nil)
+
+	if x.Type() != vm.FloatType && y.Type() != vm.FloatType && res == vm.Float(int(res)) {
+		return res.AsInt(nil)
+	}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignMul))
+	return res
 }
 
-func (c *Compiler) ExprAssignPlus(n *ast.ExprAssignPlus) {
-	n.Expr.Accept(c)
-	n.Var.Accept(c)
+func constantArithmetic(x, y vm.Value, i func(x, y vm.Int) vm.Int, f func(x, y vm.Float) vm.Float) vm.Value {
+	if x.Type() == vm.FloatType || y.Type() == vm.FloatType {
+		return f(x.AsFloat(nil), y.AsFloat(nil))
+	}
 
-	binary.NativeEndian.PutUint64((*c.context.Bytecode())[len(*c.context.Bytecode())-16:], uint64(vm.OpAssignAdd))
+	return i(x.AsInt(nil), y.AsInt(nil))
 }
 
-func (c *Compiler) ExprBinaryIdentical(n *ast.ExprBinaryIdentical) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// constantPow raises x to power y. Power of integers is integer, unless the exponent is negative or it overflows
+func constantPow(x, y vm.Value) vm.Value {
+	res := math.Pow(float64(x.AsFloat(nil)), float64(y.AsFloat(nil)))
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIdentical))
+	if x.Type() != vm.FloatType && y.Type() != vm.FloatType && y.AsInt(nil) >= 0 && math.Abs(res) < math.MaxInt64 {
+		return vm.Int(res)
+	}
+
+	return vm.Float(res)
 }
 
-func (c *Compiler) ExprBinaryMinus(n *ast.ExprBinaryMinus) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// constantSpread appends elements of array v to arr. Integer keys are renumbered, string keys are kept
+func constantSpread(arr *vm.Array, v vm.Value) {
+	src, ok := v.(*vm.Array)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpSub))
+	if !ok {
+		panic("Only arrays and Traversables can be unpacked")
+	}
+
+	for _, key := range src.Keys(nil) {
+		val := src.OffsetGet(nil, key)
+
+		if _, ok := key.(vm.Int); ok {
+			key = arr.NextKey()
+		}
+
+		arr.OffsetSet(nil, key, val)
+	}
 }
 
-func (c *Compiler) ExprBinaryPlus(n *ast.ExprBinaryPlus) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// constantClass resolves class referenced in constant expression declared in scope
+func (c *Compiler) constantClass(scope *vm.Class, n ast.Vertex) *vm.Class {
+	switch name := c.className(n); strings.ToLower(name) {
+	case "self", "static":
+		return scope
+	case "parent":
+		if scope.Parent == nil {
+			panic("Cannot use \"parent\" when current class scope has no parent")
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAdd))
+		return scope.Parent
+	default:
+		if i := slices.IndexFunc(c.classes, func(class *vm.Class) bool { return strings.EqualFold(string(class.Name), name) }); i >= 0 {
+			return c.classes[i]
+		}
+
+		if class := c.ctx.ClassByName(vm.String(name)); class != nil {
+			return class
+		}
+
+		panic(fmt.Sprintf("Class \"%s\" not found", name))
+	}
 }
 
-func (c *Compiler) ExprBinaryBitwiseAnd(n *ast.ExprBinaryBitwiseAnd) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// classConstant returns value of class constant evaluating its initializer on first access
+func (c *Compiler) classConstant(constant *vm.ClassConstant) vm.Value {
+	expr, ok := c.constants[constant]
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwAnd))
+	if !ok {
+		return constant.Value
+	}
+
+	if expr == nil {
+		panic(fmt.Sprintf("Cannot declare self-referencing constant %s::%s", string(constant.Class.Name), string(constant.Name)))
+	}
+
+	c.constants[constant] = nil
+	constant.Value = c.constantExpr(constant.Class, expr)
+	delete(c.constants, constant)
+
+	return constant.Value
 }
 
-func (c *Compiler) ExprBinaryBitwiseOr(n *ast.ExprBinaryBitwiseOr) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+func unquote(n *ast.ScalarString) string {
+	value := n.Value
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwOr))
+	if value[0] == value[len(value)-1] {
+		switch value[0] {
+		case '"':
+			return unescape(string(value[1:len(value)-1]), '"')
+		case '\'', '`':
+			value = value[1 : len(value)-1]
+		}
+	}
+
+	return posixReplacer.Replace(string(value))
 }
 
-func (c *Compiler) ExprBinaryBitwiseXor(n *ast.ExprBinaryBitwiseXor) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// unescape replaces escape sequences of double-quoted string or heredoc. Escaped quote is replaced only
+// in strings delimited by it, unknown sequences are kept as is
+func unescape(s string, quote byte) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwXor))
+	var str strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			str.WriteByte(s[i])
+			continue
+		}
+
+		i++
+
+		switch c := s[i]; {
+		case c == 'n':
+			str.WriteByte('\n')
+		case c == 't':
+			str.WriteByte('\t')
+		case c == 'r':
+			str.WriteByte('\r')
+		case c == 'v':
+			str.WriteByte('\v')
+		case c == 'f':
+			str.WriteByte('\f')
+		case c == 'e':
+			str.WriteByte(0x1b)
+		case c == '\\', c == '$', c == quote && quote != 0:
+			str.WriteByte(c)
+		case c >= '0' && c <= '7':
+			j := i + 1
+
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+
+			b, _ := strconv.ParseUint(s[i:j], 8, 16)
+			str.WriteByte(byte(b))
+			i = j - 1
+		case c == 'x' && i+1 < len(s) && isHex(s[i+1]):
+			j := i + 2
+
+			if j < len(s) && isHex(s[j]) {
+				j++
+			}
+
+			b, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			str.WriteByte(byte(b))
+			i = j - 1
+		case c == 'u' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+
+			if r, err := strconv.ParseUint(s[i+2:i+max(end, 2)], 16, 32); end > 2 && err == nil {
+				str.WriteRune(rune(r))
+				i += end
+				break
+			}
+
+			str.WriteByte('\\')
+			str.WriteByte(c)
+		default:
+			str.WriteByte('\\')
+			str.WriteByte(c)
+		}
+	}
+
+	return str.String()
 }
 
-func (c *Compiler) ExprBitwiseNot(n *ast.ExprBitwiseNot) {
-	n.Expr.Accept(c)
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpBwNot))
+// identifier returns name of an identifier or a variable
+func identifier(n ast.Vertex) string {
+	switch n := n.(type) {
+	case *ast.Identifier:
+		return string(n.Value)
+	case *ast.ExprVariable:
+		return identifier(n.Name)
+	default:
+		return ""
+	}
 }
 
-func (c *Compiler) ExprBinaryBooleanAnd(n *ast.ExprBinaryBooleanAnd) {
-	panic("not implemented")
+// modifiers returns visibility and static flag of a class member
+func modifiers(list []ast.Vertex) (visibility vm.Visibility, static bool) {
+	for _, modifier := range list {
+		switch strings.ToLower(identifier(modifier)) {
+		case "protected":
+			visibility = vm.Protected
+		case "private":
+			visibility = vm.Private
+		case "static":
+			static = true
+		}
+	}
+
+	return
 }
 
-func (c *Compiler) ExprBinaryBooleanOr(n *ast.ExprBinaryBooleanOr) {
-	panic("not implemented")
+// hasModifier reports if list of modifiers contains modifier
+func hasModifier(list []ast.Vertex, modifier string) bool {
+	return slices.ContainsFunc(list, func(n ast.Vertex) bool { return strings.EqualFold(identifier(n), modifier) })
 }
 
-func (c *Compiler) ExprBinaryCoalesce(n *ast.ExprBinaryCoalesce) {
-	panic("not implemented")
+// defaultParams evaluates default values of optional params, which are not passed, on function entry
+//
+//	function f($a = 1)
+//	=> ARG_PASSED $a, JUMP_TRUE end, CONST 1, ASSIGN $a, POP, end:
+func (c *Compiler) defaultParams(params []ast.Vertex) {
+	for _, param := range params {
+		param := param.(*ast.Parameter)
+
+		if param.DefaultValue == nil {
+			continue
+		}
+
+		v := c.context.Var(c.context.Resolve(param.Var, VariableAliasType))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpArgPassed))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(v))
+		end := c.emitJump(vm.OpJumpTrue)
+		param.DefaultValue.Accept(c)
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssign))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(v))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+		c.patchJump(end)
+	}
 }
 
-func (c *Compiler) ExprBinaryConcat(n *ast.ExprBinaryConcat) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// assertParams converts arguments to declared types of parameters of function compiled in ctx on function entry
+func (c *Compiler) assertParams(ctx *internal.FunctionContext) {
+	for i, arg := range ctx.Args {
+		if arg.Decl == nil {
+			continue
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConcat))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpAssertParam))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(i))
+	}
 }
 
-func (c *Compiler) ExprBinaryDiv(n *ast.ExprBinaryDiv) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// promoteParams declares properties for constructor params with modifiers and initializes them on entry
+//
+//	function __construct(private $a)
+//	=> THIS, LOAD $a, CONST "a", PROP_ASSIGN, POP
+func (c *Compiler) promoteParams(ctx *internal.FunctionContext, method string, params []ast.Vertex) {
+	for i, param := range params {
+		param := param.(*ast.Parameter)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpDiv))
-}
+		if len(param.Modifiers) == 0 {
+			continue
+		}
 
-func (c *Compiler) ExprBinaryEqual(n *ast.ExprBinaryEqual) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		c.require(promotedProperties)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpEqual))
-}
+		if hasModifier(param.Modifiers, "readonly") {
+			c.require(readonlyProperties)
+		}
 
-func (c *Compiler) ExprBinaryGreater(n *ast.ExprBinaryGreater) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		switch {
+		case !strings.EqualFold(method, "__construct"):
+			panic("Cannot declare promoted property outside a constructor")
+		case param.VariadicTkn != nil:
+			panic("Cannot declare variadic promoted property")
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpGreater))
+		visibility, _ := modifiers(param.Modifiers)
+		name := strings.TrimPrefix(identifier(param.Var), "$")
+		c.class.AddProperty(c.property(name, visibility, ctx.Args[i].Decl, hasModifier(param.Modifiers, "readonly")))
+
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpThis))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLoad))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Var(ctx.Args[i].Name)))
+		c.constant(param, vm.String(name))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPropertyAssign))
+		*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPop))
+	}
 }
 
-func (c *Compiler) ExprBinaryGreaterOrEqual(n *ast.ExprBinaryGreaterOrEqual) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// typeDecl returns declaration of type n or nil, if type is not declared. Names of classes are resolved in current
+// namespace, built-in types are matched case-insensitively
+func (c *Compiler) typeDecl(n ast.Vertex) *vm.TypeDecl {
+	if n == nil {
+		return nil
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpGreaterOrEqual))
-}
+	decl := new(vm.TypeDecl)
+	c.declareType(decl, n)
 
-func (c *Compiler) ExprBinaryLogicalAnd(n *ast.ExprBinaryLogicalAnd) {
-	panic("not implemented")
+	return decl
 }
 
-func (c *Compiler) ExprBinaryLogicalOr(n *ast.ExprBinaryLogicalOr) {
-	panic("not implemented")
-}
+// declareType adds type n to declaration decl
+func (c *Compiler) declareType(decl *vm.TypeDecl, n ast.Vertex) {
+	var name string
 
-func (c *Compiler) ExprBinaryLogicalXor(n *ast.ExprBinaryLogicalXor) {
-	panic("not implemented")
-}
+	switch n := n.(type) {
+	case *ast.Nullable:
+		decl.Types |= vm.NullType
+		c.declareType(decl, n.Expr)
+		return
+	case *ast.Union:
+		c.require(unionTypes)
 
-func (c *Compiler) ExprBinaryMod(n *ast.ExprBinaryMod) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		for _, t := range n.Types {
+			c.declareType(decl, t)
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMod))
-}
+		return
+	case *ast.Intersection:
+		c.require(intersectionTypes)
+		decl.Intersection = true
 
-func (c *Compiler) ExprBinaryMul(n *ast.ExprBinaryMul) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		for _, t := range n.Types {
+			c.declareType(decl, t)
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpMul))
-}
+		return
+	case *ast.Identifier:
+		name = string(n.Value)
+	case *ast.Name:
+		if len(n.Parts) == 1 {
+			name = string(n.Parts[0].(*ast.NamePart).Value)
+		}
+	}
 
-func (c *Compiler) ExprBinaryNotEqual(n *ast.ExprBinaryNotEqual) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+	switch lower := strings.ToLower(name); {
+	case builtInTypeAsserts[lower] != 0:
+		decl.Types |= builtInTypeAsserts[lower]
+	case builtInTypes[lower] != 0:
+		if f, ok := typeFeatures[lower]; ok {
+			c.require(f)
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNotEqual))
+		decl.Flags |= builtInTypes[lower]
+	case lower == "self" || lower == "parent":
+		decl.Classes = append(decl.Classes, vm.String(lower))
+	default:
+		decl.Classes = append(decl.Classes, vm.String(c.className(n)))
+	}
 }
 
-func (c *Compiler) ExprBinaryNotIdentical(n *ast.ExprBinaryNotIdentical) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+func endsWithReturn(stmts []ast.Vertex) bool {
+	if len(stmts) == 0 {
+		return false
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNotIdentical))
+	_, ok := stmts[len(stmts)-1].(*ast.StmtReturn)
+	return ok
 }
 
-func (c *Compiler) ExprBinaryPow(n *ast.ExprBinaryPow) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+// linkClass resolves parent and interfaces of class and inherits their members
+func (c *Compiler) linkClass(class *vm.Class, linked map[*vm.Class]bool) {
+	if linked[class] {
+		return
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpPow))
-}
+	linked[class] = true
 
-func (c *Compiler) ExprBinaryShiftLeft(n *ast.ExprBinaryShiftLeft) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+	if name, ok := c.parents[class]; ok {
+		parent := c.findClass(name, linked)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpShiftLeft))
-}
+		if parent.Interface {
+			panic(fmt.Sprintf("Class %s cannot extend interface %s", string(class.Name), string(parent.Name)))
+		}
 
-func (c *Compiler) ExprBinaryShiftRight(n *ast.ExprBinaryShiftRight) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		class.Extend(parent)
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpShiftRight))
-}
+	for _, use := range c.uses[class] {
+		c.useTraits(class, use, linked)
+	}
 
-func (c *Compiler) ExprBinarySmaller(n *ast.ExprBinarySmaller) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+	for _, name := range c.interfaces[class] {
+		iface := c.findClass(name, linked)
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLess))
-}
+		if !iface.Interface {
+			panic(fmt.Sprintf("%s cannot implement %s - it is not an interface", string(class.Name), string(iface.Name)))
+		}
 
-func (c *Compiler) ExprBinarySmallerOrEqual(n *ast.ExprBinarySmallerOrEqual) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+		class.Implement(iface)
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpLessOrEqual))
-}
+	if class.Abstract || class.Interface || class.Trait {
+		return
+	}
 
-func (c *Compiler) ExprBinarySpaceship(n *ast.ExprBinarySpaceship) {
-	n.Left.Accept(c)
-	n.Right.Accept(c)
+	if methods := class.AbstractMethods(); len(methods) > 0 {
+		names := make([]string, len(methods))
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCompare))
-}
+		for i, m := range methods {
+			names[i] = string(m.Class.Name) + "::" + string(m.Name)
+		}
 
-func (c *Compiler) ExprCastArray(n *ast.ExprCastArray) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.ArrayType))
-}
+		plural := ""
 
-func (c *Compiler) ExprCastBool(n *ast.ExprCastBool) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.BoolType))
-}
+		if len(methods) > 1 {
+			plural = "s"
+		}
 
-func (c *Compiler) ExprCastDouble(n *ast.ExprCastDouble) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.FloatType))
+		panic(fmt.Sprintf("Class %s contains %d abstract method%s and must therefore be declared abstract or implement the remaining methods (%s)", string(class.Name), len(methods), plural, strings.Join(names, ", ")))
+	}
 }
 
-func (c *Compiler) ExprCastInt(n *ast.ExprCastInt) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.IntType))
-}
+// useTraits copies members of traits into class. Methods declared in class take precedence over trait methods,
+// which in turn override inherited ones
+func (c *Compiler) useTraits(class *vm.Class, use traitUse, linked map[*vm.Class]bool) {
+	traits := make([]*vm.Class, len(use.traits))
+	applied := make(map[string]*vm.Method)
 
-func (c *Compiler) ExprCastObject(n *ast.ExprCastObject) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.ObjectType))
-}
+	for i, name := range use.traits {
+		if traits[i] = c.findClass(name, linked); !traits[i].Trait {
+			panic(fmt.Sprintf("%s cannot use %s - it is not a trait", string(class.Name), string(traits[i].Name)))
+		}
+	}
 
-func (c *Compiler) ExprCastString(n *ast.ExprCastString) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.StringType))
-}
+	for _, trait := range traits {
+		names := make([]string, 0, len(trait.Methods))
 
-func (c *Compiler) ExprCastUnset(n *ast.ExprCastUnset) {
-	n.Expr.Accept(c)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpCast))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.NullType))
-}
+		for name := range trait.Methods {
+			names = append(names, string(name))
+		}
 
-func (c *Compiler) ExprPropertyFetch(n *ast.ExprPropertyFetch) {
-	panic("not implemented")
-}
+		slices.Sort(names)
 
-func (c *Compiler) ExprStaticPropertyFetch(n *ast.ExprStaticPropertyFetch) { panic("not implemented") }
+		for _, name := range names {
+			m := trait.Methods[vm.String(name)]
 
-func (c *Compiler) ExprMethodCall(n *ast.ExprMethodCall) { panic("not implemented") }
+			if use.excluded[strings.ToLower(string(trait.Name))+"::"+name] {
+				continue
+			}
 
-func (c *Compiler) ExprStaticCall(n *ast.ExprStaticCall) { panic("not implemented") }
+			if prev, ok := applied[name]; ok {
+				if !m.Abstract && !prev.Abstract {
+					panic(fmt.Sprintf("Trait method %s::%s has not been applied as %s::%s, because of collision with %s::%s", string(trait.Name), string(m.Name), string(class.Name), string(m.Name), string(prev.Class.Name), string(prev.Name)))
+				}
 
-func (c *Compiler) ExprIsset(n *ast.ExprIsset) {
-	for _, v := range n.Vars {
-		v.Accept(c)
+				if m.Abstract {
+					continue
+				}
+			}
+
+			applied[name] = m
+		}
 	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpIsSet))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(len(n.Vars)))
-}
+	methods := make(map[string]*vm.Method, len(applied))
 
-func (c *Compiler) ExprBooleanNot(n *ast.ExprBooleanNot) {
-	n.Expr.Accept(c)
+	for name, m := range applied {
+		copied := *m
+		methods[name] = &copied
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpNot))
-}
+	for _, alias := range use.aliases {
+		var m *vm.Method
 
-func (c *Compiler) ExprRequire(n *ast.ExprRequire) {
-	panic("not implemented")
-}
+		for _, trait := range traits {
+			if alias.trait == "" || strings.EqualFold(alias.trait, string(trait.Name)) {
+				if found, ok := trait.Method(vm.String(alias.method)); ok {
+					m = found
+					break
+				}
+			}
+		}
 
-func (c *Compiler) ExprRequireOnce(n *ast.ExprRequireOnce) {
-	panic("not implemented")
-}
+		if m == nil {
+			panic(fmt.Sprintf("An alias was defined for %s but this method does not exist", alias.method))
+		}
 
-func (c *Compiler) ExprInclude(n *ast.ExprInclude) {
-	panic("not implemented")
-}
+		if alias.alias == "" {
+			if copied, ok := methods[strings.ToLower(alias.method)]; ok {
+				copied.Visibility = *alias.visibility
+			}
 
-func (c *Compiler) ExprIncludeOnce(n *ast.ExprIncludeOnce) {
-	panic("not implemented")
-}
+			continue
+		}
 
-func (c *Compiler) ExprBrackets(n *ast.ExprBrackets) {
-	n.Expr.Accept(c)
-}
+		copied := *m
+		copied.Name = vm.String(alias.alias)
 
-func (c *Compiler) ScalarLnumber(n *ast.ScalarLnumber) {
-	i, _ := strconv.Atoi(unsafe.String(unsafe.SliceData(n.Value), len(n.Value)))
+		if alias.visibility != nil {
+			copied.Visibility = *alias.visibility
+		}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, vm.Int(i))))
-}
+		methods[strings.ToLower(alias.alias)] = &copied
+	}
 
-func (c *Compiler) ScalarString(n *ast.ScalarString) {
-	if n.Value[0] == n.Value[len(n.Value)-1] {
-		switch n.Value[0] {
-		case '"', '\'', '`':
-			n.Value = n.Value[1 : len(n.Value)-1]
+	for name, m := range methods {
+		if own, ok := class.Method(vm.String(name)); ok && (own.Class == class || m.Abstract && !own.Abstract) {
+			continue
 		}
-	}
 
-	s := posixReplacer.Replace(unsafe.String(unsafe.SliceData(n.Value), len(n.Value)))
+		class.AddMethod(m)
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, vm.String(s))))
-}
+	for _, trait := range traits {
+		for _, prop := range trait.Props {
+			if _, ok := class.Property(prop.Name); !ok {
+				class.AddProperty(c.copyProperty(prop))
+			}
+		}
 
-func (c *Compiler) ScalarDnumber(n *ast.ScalarDnumber) {
-	f, _ := strconv.ParseFloat(unsafe.String(unsafe.SliceData(n.Value), len(n.Value)), 64)
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, vm.Float(f))))
-}
+		for _, prop := range trait.Statics {
+			if _, ok := class.StaticProperty(prop.Name); !ok {
+				class.AddStaticProperty(c.copyProperty(prop))
+			}
+		}
 
-func (c *Compiler) ScalarEncapsed(n *ast.ScalarEncapsed) {
-	for _, part := range n.Parts {
-		part.Accept(c)
+		for name, constant := range trait.Consts {
+			if _, ok := class.Consts[name]; !ok {
+				class.Consts[name] = constant
+			}
+		}
 	}
 }
 
-func (c *Compiler) ScalarEncapsedStringPart(n *ast.ScalarEncapsedStringPart) {
-	s := unsafe.String(unsafe.SliceData(n.Value), len(n.Value))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConst))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(c.context.Literal(n, vm.String(s))))
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConcat))
-}
+// copyProperty copies trait property, which default value is evaluated after linking
+func (c *Compiler) copyProperty(prop *vm.Property) *vm.Property {
+	copied := *prop
+	c.initializers = append(c.initializers, func() { copied.Default = prop.Default })
 
-func (c *Compiler) ScalarEncapsedStringVar(n *ast.ScalarEncapsedStringVar) {
-	panic("not implemented")
+	return &copied
 }
 
-func (c *Compiler) ScalarEncapsedStringBrackets(n *ast.ScalarEncapsedStringBrackets) {
-	n.Var.Accept(c)
+// findClass returns linked class declared in compiled script or in runtime
+func (c *Compiler) findClass(name string, linked map[*vm.Class]bool) *vm.Class {
+	if i := slices.IndexFunc(c.classes, func(class *vm.Class) bool { return strings.EqualFold(string(class.Name), name) }); i >= 0 {
+		c.linkClass(c.classes[i], linked)
+		return c.classes[i]
+	}
 
-	*c.context.Bytecode() = binary.NativeEndian.AppendUint64(*c.context.Bytecode(), uint64(vm.OpConcat))
+	if class := c.ctx.ClassByName(vm.String(name)); class != nil {
+		return class
+	}
+
+	panic(fmt.Sprintf("Class \"%s\" not found", name))
 }
 
 func NewCompiler(extensions *Extensions) *Compiler {
@@ -850,6 +3513,9 @@ func (c *Compiler) Reset() {
 	c.contexts = c.contexts[:0]
 	c.global = nil
 	c.context = nil
+	c.class = nil
+	c.classes = nil
+	c.initializers = nil
 }
 
 func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunction {
@@ -876,6 +3542,17 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 	}
 	c.global.Labels = make(map[string]uint64)
 	c.arrayWriteMode = make(map[ast.Vertex]bool)
+	c.chained = make(map[ast.Vertex]bool)
+	c.signatures = make(map[string][]internal.Arg)
+	c.nullsafe = nil
+	c.parents = make(map[*vm.Class]string)
+	c.interfaces = make(map[*vm.Class][]string)
+	c.uses = make(map[*vm.Class][]traitUse)
+	c.constants = make(map[*vm.ClassConstant]ast.Vertex)
+	c.classes = nil
+	c.initializers = nil
+	c.statics = nil
+	c.strict = false
 	c.context = c.global
 	c.ctx = ctx
 
@@ -888,6 +3565,10 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 		}
 
 		for n, fn := range ext.Functions {
+			if named, ok := fn.(interface{ Named(string) vm.Callable }); ok {
+				fn = named.Named(n)
+			}
+
 			ctx.Functions = append(ctx.Functions, fn)
 			c.global.Functions = append(c.global.Functions, n)
 
@@ -895,9 +3576,9 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 
 			for _, arg := range fn.(interface{ GetArgs() []vm.Arg }).GetArgs() {
 				args = append(args, internal.Arg{
-					Name:  arg.Name,
-					IsRef: arg.ByRef,
-					Type:  arg.Type.String(),
+					Name:     arg.Name,
+					IsRef:    arg.ByRef,
+					Variadic: arg.Variadic,
 				})
 			}
 
@@ -909,7 +3590,12 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 		}
 	}
 
-	node, err := parser.Parse(input, conf.Config{Version: &version.Version{Major: 7, Minor: 4}})
+	node, err := parser.Parse(input, conf.Config{
+		Version: c.targetVersion(),
+		ErrorHandlerFunc: func(e *errors.Error) {
+			panic(e.String())
+		},
+	})
 
 	if err != nil {
 		panic(err)
@@ -917,7 +3603,26 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 
 	node.Accept(c)
 
+	linked := make(map[*vm.Class]bool, len(c.classes))
+
+	for _, class := range c.classes {
+		c.linkClass(class, linked)
+	}
+
+	for _, class := range c.classes {
+		for _, constant := range class.Consts {
+			c.classConstant(constant)
+		}
+	}
+
+	ctx.Classes = append(ctx.Classes, c.classes...)
+
+	for _, init := range c.initializers {
+		init()
+	}
+
 	ctx.Constants = c.global.Literals
+	ctx.StaticVars = c.statics
 	ctx.Functions = slices.Grow(ctx.Functions, len(c.contexts)+len(c.global.Functions))
 	ctx.Functions = ctx.Functions[:len(c.contexts)+len(c.global.Functions)]
 
@@ -926,14 +3631,67 @@ func (c *Compiler) Compile(input []byte, ctx *vm.GlobalContext) vm.CompiledFunct
 			continue
 		}
 		ctx.Functions[slices.Index(c.global.Functions, context.Name)] = vm.CompiledFunction{
+			Name:         context.Name,
 			Instructions: Optimizer(context.Instructions),
 			Args:         len(context.Args),
 			Vars:         len(context.Variables),
+			Required:     required(context.Args),
+			Params:       parameters(context.Args),
+			Return:       context.Return,
+			Strict:       c.strict,
+			Handlers:     context.Handlers,
+			Lines:        context.Lines,
+			Variables:    variableNames(context.Variables),
+			Bound:        context.Bound,
+			Static:       context.Static,
 		}
 	}
 
+	ctx.FunctionNames = ctx.FunctionNames[:0]
+
+	for _, name := range c.global.Functions {
+		ctx.FunctionNames = append(ctx.FunctionNames, vm.String(name))
+	}
+
 	return vm.CompiledFunction{
 		Instructions: Optimizer(c.global.Instructions),
 		Vars:         len(c.global.Variables),
+		Strict:       c.strict,
+		Handlers:     c.global.Handlers,
+		Lines:        c.global.Lines,
+		Variables:    variableNames(c.global.Variables),
+	}
+}
+
+// parameters returns parameters of compiled function, which are used to map arguments at runtime
+func parameters(args []internal.Arg) []vm.Arg {
+	params := make([]vm.Arg, len(args))
+
+	for i, arg := range args {
+		params[i] = vm.Arg{Name: strings.TrimPrefix(arg.Name, "$"), ByRef: arg.IsRef, Variadic: arg.Variadic, Decl: arg.Decl}
+	}
+
+	return params
+}
+
+// required returns number of leading parameters, which have to be passed. Optional parameter declared before
+// required one is required too
+func required(args []internal.Arg) (n int) {
+	for i, arg := range args {
+		if arg.Default == nil && !arg.Variadic {
+			n = i + 1
+		}
+	}
+
+	return n
+}
+
+func variableNames(variables []string) []vm.String {
+	names := make([]vm.String, len(variables))
+
+	for i, name := range variables {
+		names[i] = vm.String(name)
 	}
+
+	return names
 }
@@ -42,12 +42,17 @@ func TestLoop(t *testing.T) {
 		{
 			input:                "do{ $i++; } while($i < 5)",
 			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(5)},
-			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpPostIncrement), 0, uint64(vm.OpPop), uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpLess), uint64(vm.OpJumpFalse), 0, uint64(vm.OpReturn)}),
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpPostIncrement), 0, uint64(vm.OpPop), uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpLess), uint64(vm.OpJumpTrue), 0, uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "foreach([] as $a){ while(1){ foreach([] as $b){ break 3; } continue 2; } }",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1)},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpArrayNew), uint64(vm.OpForEachInit), uint64(vm.OpForEachValid), uint64(vm.OpJumpFalse), 32, uint64(vm.OpForEachValue), 0, uint64(vm.OpConst), 3, uint64(vm.OpJumpFalse), 29, uint64(vm.OpArrayNew), uint64(vm.OpForEachInit), uint64(vm.OpForEachValid), uint64(vm.OpJumpFalse), 24, uint64(vm.OpForEachValue), 1, uint64(vm.OpPop), uint64(vm.OpJump), 32, uint64(vm.OpForEachNext), uint64(vm.OpJump), 13, uint64(vm.OpPop), uint64(vm.OpJump), 29, uint64(vm.OpJump), 7, uint64(vm.OpForEachNext), uint64(vm.OpJump), 2, uint64(vm.OpPop), uint64(vm.OpReturn)}),
 		},
 		{
 			input:                "for($i=0;$i<5;$i++){ $x = &$i; }",
 			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(0), vm.Int(5)},
-			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpAssign), 0, uint64(vm.OpPop), uint64(vm.OpLoad), 0, uint64(vm.OpConst), 4, uint64(vm.OpLess), uint64(vm.OpJumpFalse), 22, uint64(vm.OpLoadRef), 0, uint64(vm.OpAssign), 1, uint64(vm.OpPop), uint64(vm.OpPostIncrement), 0, uint64(vm.OpPop), uint64(vm.OpJump), 5, uint64(vm.OpReturn)}),
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpAssign), 0, uint64(vm.OpPop), uint64(vm.OpLoad), 0, uint64(vm.OpConst), 4, uint64(vm.OpLess), uint64(vm.OpJumpFalse), 24, uint64(vm.OpLoadRef), 0, uint64(vm.OpBind), 1, uint64(vm.OpLoad), 1, uint64(vm.OpPop), uint64(vm.OpPostIncrement), 0, uint64(vm.OpPop), uint64(vm.OpJump), 5, uint64(vm.OpReturn)}),
 		},
 	}
 
@@ -129,6 +134,31 @@ func TestVariable(t *testing.T) {
 			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1)},
 			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpAssignConcat), 0, uint64(vm.OpPop), uint64(vm.OpReturn)}),
 		},
+		{
+			input:                "global $x",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpGlobalRef), 0, uint64(vm.OpBind), 0, uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "static $x = 1, $y",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpStaticRef), 0, uint64(vm.OpBind), 0, uint64(vm.OpStaticRef), 1, uint64(vm.OpBind), 1, uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "unset($x, $y['a'], $z->b)",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("a"), vm.String("b")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpUnset), 0, uint64(vm.OpLoadQuiet), 1, uint64(vm.OpConst), 3, uint64(vm.OpArrayUnset), uint64(vm.OpLoadQuiet), 2, uint64(vm.OpConst), 4, uint64(vm.OpPropertyUnset), uint64(vm.OpReturn)}),
+		},
+		{
+			input:             "[$a, $b] = $x",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(0), vm.Int(1)},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpLoad), 0, uint64(vm.OpAssign), 1,
+				uint64(vm.OpLoad), 1, uint64(vm.OpConst), 3, uint64(vm.OpArrayAccessRead), uint64(vm.OpAssign), 2, uint64(vm.OpPop),
+				uint64(vm.OpLoad), 1, uint64(vm.OpConst), 4, uint64(vm.OpArrayAccessRead), uint64(vm.OpAssign), 3, uint64(vm.OpPop),
+				uint64(vm.OpPop), uint64(vm.OpReturn),
+			}),
+		},
 		{
 			input:                "$x++",
 			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
@@ -169,6 +199,41 @@ func TestBranches(t *testing.T) {
 			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
 			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 4, uint64(vm.OpReturn)}),
 		},
+		{
+			input:                "if (true) {} elseif (false) {} else {}\n",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 6, uint64(vm.OpJump), 12, uint64(vm.OpConst), 1, uint64(vm.OpJumpFalse), 12, uint64(vm.OpJump), 12, uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "if (true) { while (false) {} }\n",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 10, uint64(vm.OpConst), 1, uint64(vm.OpJumpFalse), 10, uint64(vm.OpJump), 4, uint64(vm.OpReturn)}),
+		},
+		{
+			input:             "if (true && false) {}\n",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 10, uint64(vm.OpConst), 1, uint64(vm.OpCast), uint64(vm.BoolType), uint64(vm.OpJump), 12,
+				uint64(vm.OpConst), 1, uint64(vm.OpJumpFalse), 14, uint64(vm.OpReturn),
+			}),
+		},
+		{
+			input:             "if (false || true) {}\n",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpConst), 1, uint64(vm.OpJumpTrue), 10, uint64(vm.OpConst), 0, uint64(vm.OpCast), uint64(vm.BoolType), uint64(vm.OpJump), 12,
+				uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 14, uint64(vm.OpReturn),
+			}),
+		},
+		{
+			input:             "$a['b'] ?? (true ? 1 : 2)",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("b"), vm.Int(1), vm.Int(2)},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpLoadQuiet), 0, uint64(vm.OpConst), 3, uint64(vm.OpArrayAccessQuiet), uint64(vm.OpDup), uint64(vm.OpIsSet), 1, uint64(vm.OpJumpTrue), 21,
+				uint64(vm.OpPop), uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 19, uint64(vm.OpConst), 4, uint64(vm.OpJump), 21, uint64(vm.OpConst), 5,
+				uint64(vm.OpPop), uint64(vm.OpReturn),
+			}),
+		},
 	}
 
 	for _, c := range cases {
@@ -274,7 +339,7 @@ func TestIterators(t *testing.T) {
 				uint64(vm.OpPop),
 				uint64(vm.OpForEachInit),
 				uint64(vm.OpForEachValid),
-				uint64(vm.OpJumpFalse), 23,
+				uint64(vm.OpJumpFalse), 22,
 				uint64(vm.OpForEachValue), 0,
 				uint64(vm.OpForEachKey), 1,
 				uint64(vm.OpForEachNext),
@@ -298,7 +363,7 @@ func TestIterators(t *testing.T) {
 				uint64(vm.OpPop),
 				uint64(vm.OpForEachInit),
 				uint64(vm.OpForEachValid),
-				uint64(vm.OpJumpFalse), 21,
+				uint64(vm.OpJumpFalse), 20,
 				uint64(vm.OpForEachValue), 0,
 				uint64(vm.OpForEachNext),
 				uint64(vm.OpJump), 12,
@@ -321,7 +386,7 @@ func TestIterators(t *testing.T) {
 				uint64(vm.OpPop),
 				uint64(vm.OpForEachInit),
 				uint64(vm.OpForEachValid),
-				uint64(vm.OpJumpFalse), 21,
+				uint64(vm.OpJumpFalse), 20,
 				uint64(vm.OpForEachValueRef), 0,
 				uint64(vm.OpForEachNext),
 				uint64(vm.OpJump), 12,
@@ -341,3 +406,463 @@ func TestIterators(t *testing.T) {
 		})
 	}
 }
+
+func TestObjects(t *testing.T) {
+	cases := [...]compilerTestCase{
+		{
+			input:                "new A(1)",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("A"), vm.Int(1)},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpNew), 1, uint64(vm.OpPop), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$a->x = 1",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1), vm.String("x")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpPropertyAssign), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$a->x += 1",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("x"), vm.Int(1)},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpDup), uint64(vm.OpConst), 3, uint64(vm.OpPropertyFetch), uint64(vm.OpConst), 4, uint64(vm.OpAdd), uint64(vm.OpConst), 3, uint64(vm.OpPropertyAssign), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$a->x['y'] = 1",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("x"), vm.String("y"), vm.Int(1)},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpPropertyWrite), uint64(vm.OpConst), 4, uint64(vm.OpArrayAccessWrite), uint64(vm.OpConst), 5, uint64(vm.OpAssignRef), uint64(vm.OpPop), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$b = &$a->x",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("x")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpPropertyRef), uint64(vm.OpBind), 1, uint64(vm.OpLoad), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$this->m(1)",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1), vm.String("m")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpThis), uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpCallMethod), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "A::$x = 1",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("A"), vm.Int(1), vm.String("x")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpConst), 5, uint64(vm.OpStaticPropertyAssign), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "static::X",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("static"), vm.String("X")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpClassConstFetch), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "A::class",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("A")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "$a instanceof A",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("A")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpInstanceOf), uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "parent::m(1)",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("parent"), vm.Int(1), vm.String("m")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpConst), 4, uint64(vm.OpConst), 5, uint64(vm.OpCallStatic), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			ctx := new(vm.GlobalContext)
+			fn := compiler.Compile([]byte(fmt.Sprintf("<?php\n%s;", c.input)), ctx)
+			assert.Equal(t, c.expectedInstructions.String(), fn.Instructions.String())
+			assert.Equal(t, c.expectedConstants, ctx.Constants)
+		})
+	}
+}
+
+func TestClassDeclarations(t *testing.T) {
+	cases := [...]struct{ input, err string }{
+		{"interface I { function f(); } class A implements I {}", "Class A contains 1 abstract method and must therefore be declared abstract or implement the remaining methods (I::f)"},
+		{"abstract class A { abstract function f(); abstract function g(); } class B extends A {}", "Class B contains 2 abstract methods and must therefore be declared abstract or implement the remaining methods (A::f, A::g)"},
+		{"class A {} class B implements A {}", "B cannot implement A - it is not an interface"},
+		{"interface I {} class A extends I {}", "Class A cannot extend interface I"},
+		{"abstract class A { abstract function f(); } class B extends A { function f() {} }", ""},
+		{"trait T1 { function f() {} } trait T2 { function f() {} } class A { use T1, T2; }", "Trait method T2::f has not been applied as A::f, because of collision with T1::f"},
+		{"trait T1 { function f() {} } trait T2 { function f() {} } class A { use T1, T2 { T2::f insteadof T1; T1::f as g; } }", ""},
+		{"class B {} class A { use B; }", "A cannot use B - it is not a trait"},
+		{"enum E: int { case A = 1; case B = 1; }", "Duplicate value in enum E for cases A and B"},
+		{"enum E: int { case A = 'a'; }", "Enum case type string does not match enum backing type int"},
+		{"enum E { case A = 1; }", "Case A of non-backed enum E must not have a value"},
+		{"enum E: string { case A; }", "Case A of backed enum E must have a value"},
+		{"enum E { public $x; }", "Enum E cannot include properties"},
+		{"interface I { function f(); } enum E implements I { case A; }", "Class E contains 1 abstract method and must therefore be declared abstract or implement the remaining methods (I::f)"},
+		{"trait T { abstract function f(); } class A { use T; }", "Class A contains 1 abstract method and must therefore be declared abstract or implement the remaining methods (A::f)"},
+		{"class A { const X = 1; public $a = [self::X ?: 2, 1 <=> 2, ~1 ^ 3, 2 ** 3 % 5, !1 xor 1 / 4]; }", ""},
+		{"class A { public $a = 1 + f(); }", "Constant expression contains invalid operations"},
+		{"class A { const X = 1 / 0; public $a = self::X; }", "Division by zero"},
+		{"class A { public $a = 1 % 0; }", "Modulo by zero"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compile := func() { NewCompiler(nil).Compile([]byte("<?php\n"+c.input), new(vm.GlobalContext)) }
+
+			if c.err == "" {
+				assert.NotPanics(t, compile)
+			} else {
+				assert.PanicsWithValue(t, c.err, compile)
+			}
+		})
+	}
+}
+
+func TestParamClassType(t *testing.T) {
+	compiler := NewCompiler(nil)
+	ctx := new(vm.GlobalContext)
+	compiler.Compile([]byte("<?php\nfunction f(int $a, ?A $b, B $c = null, $d = 1): int|string|null {}"), ctx)
+
+	fn := ctx.Functions[0].(vm.CompiledFunction)
+	expected := instructionsToBytecode([]uint64{
+		uint64(vm.OpArgPassed), 2, uint64(vm.OpJumpTrue), 9, uint64(vm.OpConst), 2, uint64(vm.OpAssign), 2, uint64(vm.OpPop),
+		uint64(vm.OpArgPassed), 3, uint64(vm.OpJumpTrue), 18, uint64(vm.OpConst), 3, uint64(vm.OpAssign), 3, uint64(vm.OpPop),
+		uint64(vm.OpAssertParam), 0, uint64(vm.OpAssertParam), 1, uint64(vm.OpAssertParam), 2,
+		uint64(vm.OpConst), 4, uint64(vm.OpConst), 5, uint64(vm.OpNew), 1, uint64(vm.OpPop), uint64(vm.OpThrow),
+	})
+	assert.Equal(t, expected.String(), fn.Instructions.String())
+	assert.Equal(t, &vm.TypeDecl{Types: vm.IntType}, fn.Params[0].Decl)
+	assert.Equal(t, &vm.TypeDecl{Types: vm.NullType, Classes: []vm.String{"A"}}, fn.Params[1].Decl)
+	assert.Equal(t, &vm.TypeDecl{Types: vm.NullType, Classes: []vm.String{"B"}}, fn.Params[2].Decl)
+	assert.Nil(t, fn.Params[3].Decl)
+	assert.Equal(t, "string|int|null", fn.Return.String())
+	assert.Equal(t, vm.String("f(): Return value must be of type string|int|null, none returned"), ctx.Constants[5])
+}
+
+func TestReturnType(t *testing.T) {
+	cases := [...]struct {
+		input, err string
+	}{
+		{"function f(): void { return 1; }", "A void function must not return a value"},
+		{"function f(): int { return; }", "A function with return type must return a value"},
+		{"function f(): never { return; }", "A never-returning function must not return"},
+		{"function f(): void { return; }", ""},
+		{"function f(): iterable { yield 1; return; }", ""},
+		{"declare(strict_types=2);", "strict_types declaration must have 0 or 1 as its value"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compile := func() { NewCompiler(nil).Compile([]byte("<?php\n"+c.input), new(vm.GlobalContext)) }
+
+			if c.err == "" {
+				assert.NotPanics(t, compile)
+			} else {
+				assert.PanicsWithValue(t, c.err, compile)
+			}
+		})
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	cases := [...]struct {
+		compilerTestCase
+		expectedHandlers []vm.Handler
+	}{
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "try { throw $e; } catch (Exception $e) {}",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("Exception")},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpThrow), uint64(vm.OpJump), 19, uint64(vm.OpDup), uint64(vm.OpConst), 3, uint64(vm.OpInstanceOf), uint64(vm.OpJumpTrue), 13, uint64(vm.OpJump), 18, uint64(vm.OpAssign), 0, uint64(vm.OpPop), uint64(vm.OpJump), 19, uint64(vm.OpThrow), uint64(vm.OpReturn)}),
+			},
+			expectedHandlers: []vm.Handler{{Start: 0, Catch: 5}},
+		},
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "try {} finally { $a = 1; }",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1)},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 2, uint64(vm.OpJump), 4, uint64(vm.OpConst), 3, uint64(vm.OpAssign), 0, uint64(vm.OpPop), uint64(vm.OpEndFinally), uint64(vm.OpReturn)}),
+			},
+			expectedHandlers: []vm.Handler{{Start: 0, Finally: 4}},
+		},
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "foreach ([] as $v) { try {} finally {} }",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpArrayNew), uint64(vm.OpForEachInit), uint64(vm.OpForEachValid), uint64(vm.OpJumpFalse), 15, uint64(vm.OpForEachValue), 0, uint64(vm.OpConst), 2, uint64(vm.OpJump), 11, uint64(vm.OpEndFinally), uint64(vm.OpForEachNext), uint64(vm.OpJump), 2, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+			},
+			expectedHandlers: []vm.Handler{{Start: 7, Finally: 11, Depth: 1}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			ctx := new(vm.GlobalContext)
+			fn := compiler.Compile([]byte(fmt.Sprintf("<?php\n%s", c.input)), ctx)
+			assert.Equal(t, c.expectedInstructions, fn.Instructions)
+			assert.Equal(t, c.expectedConstants, ctx.Constants)
+			assert.Equal(t, c.expectedHandlers, fn.Handlers)
+		})
+	}
+}
+
+func TestClosures(t *testing.T) {
+	cases := [...]struct {
+		compilerTestCase
+		expectedBound int
+	}{
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "$f = function ($x) use ($a, &$b) { return $x; }; $f(1);",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1)},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpLoadRef), 1, uint64(vm.OpClosure), 0, uint64(vm.OpAssign), 2, uint64(vm.OpPop), uint64(vm.OpLoad), 2, uint64(vm.OpConst), 3, uint64(vm.OpCallDynamic), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+			},
+			expectedBound: 2,
+		},
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "$f = fn($x) => $x + $y;",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpClosure), 0, uint64(vm.OpAssign), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+			},
+			expectedBound: 1,
+		},
+		{
+			compilerTestCase: compilerTestCase{
+				input:                "$f = $o->m(...);",
+				expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("m")},
+				expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpLoad), 0, uint64(vm.OpConst), 3, uint64(vm.OpMethodCallable), uint64(vm.OpAssign), 1, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			ctx := new(vm.GlobalContext)
+			fn := compiler.Compile([]byte(fmt.Sprintf("<?php\n%s", c.input)), ctx)
+			assert.Equal(t, c.expectedInstructions, fn.Instructions)
+			assert.Equal(t, c.expectedConstants, ctx.Constants)
+
+			if c.expectedBound > 0 {
+				assert.Equal(t, c.expectedBound, ctx.Functions[0].(vm.CompiledFunction).Bound)
+			}
+		})
+	}
+}
+
+func TestArguments(t *testing.T) {
+	cases := [...]compilerTestCase{
+		{
+			input:             "f(1, ...$a, b: 2);",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("f"), vm.Int(1), vm.String("b"), vm.Int(2)},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpConst), 3, uint64(vm.OpArrayNew),
+				uint64(vm.OpArrayAccessPush), uint64(vm.OpConst), 4, uint64(vm.OpAssignRef), uint64(vm.OpPop),
+				uint64(vm.OpLoad), 0, uint64(vm.OpArraySpread),
+				uint64(vm.OpConst), 5, uint64(vm.OpArrayAccessWrite), uint64(vm.OpConst), 6, uint64(vm.OpAssignRef), uint64(vm.OpPop),
+				uint64(vm.OpPackArgs), uint64(vm.OpCallDynamic), 1, uint64(vm.OpPop), uint64(vm.OpReturn),
+			}),
+		},
+		{
+			input:             "function f($a, ...$b) {} f(1, 2, 3);",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1), vm.Int(2), vm.Int(3)},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpConst), 3, uint64(vm.OpArrayNew),
+				uint64(vm.OpArrayAccessPush), uint64(vm.OpConst), 4, uint64(vm.OpAssignRef), uint64(vm.OpPop),
+				uint64(vm.OpArrayAccessPush), uint64(vm.OpConst), 5, uint64(vm.OpAssignRef), uint64(vm.OpPop),
+				uint64(vm.OpCall), 0, uint64(vm.OpPop), uint64(vm.OpReturn),
+			}),
+		},
+		{
+			input:             "f(1); function f($a, $b = 2) {}",
+			expectedConstants: []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.Int(1), vm.Int(2)},
+			expectedInstructions: instructionsToBytecode([]uint64{
+				uint64(vm.OpConst), 3, uint64(vm.OpSkipArg), uint64(vm.OpCall), 0, uint64(vm.OpPop), uint64(vm.OpReturn),
+			}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			ctx := new(vm.GlobalContext)
+			fn := compiler.Compile([]byte(fmt.Sprintf("<?php\n%s", c.input)), ctx)
+			assert.Equal(t, c.expectedInstructions, fn.Instructions)
+			assert.Equal(t, c.expectedConstants, ctx.Constants)
+		})
+	}
+
+	assert.PanicsWithValue(t, "Cannot use positional argument after named argument", func() {
+		NewCompiler(nil).Compile([]byte("<?php\nf(a: 1, 2);"), new(vm.GlobalContext))
+	})
+}
+
+func TestGenerator(t *testing.T) {
+	compiler := NewCompiler(nil)
+	ctx := new(vm.GlobalContext)
+	compiler.Compile([]byte("<?php\nfunction g(?A $a) { while (true) { yield $a; } }"), ctx)
+
+	expected := instructionsToBytecode([]uint64{
+		uint64(vm.OpAssertParam), 0,
+		uint64(vm.OpGenerator),
+		uint64(vm.OpConst), 0, uint64(vm.OpJumpFalse), 14,
+		uint64(vm.OpLoad), 0, uint64(vm.OpYield), 1, uint64(vm.OpPop),
+		uint64(vm.OpJump), 3,
+		uint64(vm.OpConst), 2, uint64(vm.OpReturnValue),
+	})
+	assert.Equal(t, expected.String(), ctx.Functions[0].(vm.CompiledFunction).Instructions.String())
+	assert.Panics(t, func() { NewCompiler(nil).Compile([]byte("<?php\nyield 1;"), new(vm.GlobalContext)) })
+}
+
+func TestSwitch(t *testing.T) {
+	compiler := NewCompiler(nil)
+	ctx := new(vm.GlobalContext)
+	fn := compiler.Compile([]byte("<?php\nswitch ($a) { case 1: case 2: case 3: case 4: case 5: break; }"), ctx)
+
+	chain := []uint64{uint64(vm.OpLoad), 0, uint64(vm.OpJumpTable), 8, uint64(vm.OpJump), 40}
+
+	for i := uint64(3); i < 8; i++ {
+		chain = append(chain, uint64(vm.OpDup), uint64(vm.OpConst), i, uint64(vm.OpEqual), uint64(vm.OpJumpTrue), 38)
+	}
+
+	expected := instructionsToBytecode(append(chain, uint64(vm.OpJump), 40, uint64(vm.OpJump), 40, uint64(vm.OpPop), uint64(vm.OpReturn)))
+	assert.Equal(t, expected.String(), fn.Instructions.String())
+	assert.Equal(t, vm.NewArray(map[vm.Value]vm.Value{vm.Int(1): vm.Int(36), vm.Int(2): vm.Int(36), vm.Int(3): vm.Int(36), vm.Int(4): vm.Int(36), vm.Int(5): vm.Int(36)}), ctx.Constants[8])
+	assert.Panics(t, func() { NewCompiler(nil).Compile([]byte("<?php\nbreak;"), new(vm.GlobalContext)) })
+}
+
+func TestInterpolation(t *testing.T) {
+	cases := [...]compilerTestCase{
+		{
+			input:                `"a $x {$y['k']}b"`,
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("a "), vm.String(" "), vm.String("k"), vm.String("b")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpLoad), 0, uint64(vm.OpConst), 4, uint64(vm.OpLoad), 1, uint64(vm.OpConst), 5, uint64(vm.OpArrayAccessRead), uint64(vm.OpConst), 6, uint64(vm.OpConcatN), 5, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                `"\$x\t\x41\101\u{e9}\q"`,
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("$x\tAAé\\q")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "<<<EOT\n  a\n    $x\n  EOT",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("a\n  ")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpLoad), 0, uint64(vm.OpConcatN), 2, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+		{
+			input:                "<<<'EOT'\n  $x\\n\n  EOT",
+			expectedConstants:    []vm.Value{vm.Bool(true), vm.Bool(false), vm.Null{}, vm.String("$x\\n")},
+			expectedInstructions: instructionsToBytecode([]uint64{uint64(vm.OpConst), 3, uint64(vm.OpPop), uint64(vm.OpReturn)}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			ctx := new(vm.GlobalContext)
+			fn := compiler.Compile([]byte(fmt.Sprintf("<?php\n%s;", c.input)), ctx)
+			assert.Equal(t, c.expectedInstructions.String(), fn.Instructions.String())
+			assert.Equal(t, c.expectedConstants, ctx.Constants)
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	cases := [...]struct {
+		version, input, err string
+	}{
+		{"8.1", "enum E { case A; }", ""},
+		{"8.0", "enum E { case A; }", "Cannot use enumerations before PHP 8.1, target version is 8.0"},
+		{"8.0", "function f(): never { throw new E; }", "Cannot use never type before PHP 8.1, target version is 8.0"},
+		{"8.0", "function f(A&B $x) {}", "Cannot use intersection types before PHP 8.1, target version is 8.0"},
+		{"8.0", "$f = strlen(...);", "Cannot use first-class callable syntax before PHP 8.1, target version is 8.0"},
+		{"8.0", "echo match (1) { 1 => 2 }, $a?->b;", ""},
+		{"8.0", "class A { public readonly int $x; }", "Cannot use readonly properties before PHP 8.1, target version is 8.0"},
+		{"8.1", "readonly class A {}", "Cannot use readonly classes before PHP 8.2, target version is 8.1"},
+		{"8.0", "readonly class A {}", "Cannot use readonly classes before PHP 8.2, target version is 8.0"},
+		{"8.0", "class A { function __construct(private int $x) {} }", ""},
+		{"7.4", "class A { function __construct(private int $x) {} }", "syntax error: unexpected T_PRIVATE, expecting T_VARIABLE at line 2"},
+		{"7.4", "$f = fn() => 1; $a ??= 1;", ""},
+		{"7.3", "$f = fn() => 1;", "Cannot use arrow functions before PHP 7.4, target version is 7.3"},
+		{"7.4", "$a = 1_000 + 0xf_f + 1_0.5;", ""},
+		{"7.3", "$a = 1_000;", "Cannot use numeric literal separator before PHP 7.4, target version is 7.3"},
+		{"7.3", "$a = 1_0.5;", "Cannot use numeric literal separator before PHP 7.4, target version is 7.3"},
+		{"8.1", "$a = 0o17 + 0O17;", ""},
+		{"8.0", "$a = 0o17;", "Cannot use explicit octal notation before PHP 8.1, target version is 8.0"},
+		{"7.0", "$a = 017 + 0x1A + 0b11;", ""},
+		{"7.4", "echo match (1) { 1 => 2 };", "syntax error: unexpected T_DOUBLE_ARROW at line 2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.version+" "+c.input, func(t *testing.T) {
+			compiler := NewCompiler(nil)
+			assert.NoError(t, compiler.SetVersion(c.version))
+			compile := func() { compiler.Compile([]byte("<?php\n"+c.input), new(vm.GlobalContext)) }
+
+			if c.err == "" {
+				assert.NotPanics(t, compile)
+			} else {
+				assert.PanicsWithValue(t, c.err, compile)
+			}
+		})
+	}
+
+	assert.EqualError(t, NewCompiler(nil).SetVersion("9.0"), "PHP version 9.0 is not supported, supported versions are 7.0 to 8.1")
+	assert.EqualError(t, NewCompiler(nil).SetVersion("7.9"), "PHP version 7.9 is not supported: the version is out of supported range")
+	assert.Error(t, NewCompiler(nil).SetVersion("eight"))
+}
+
+func TestProperties(t *testing.T) {
+	ctx := new(vm.GlobalContext)
+	NewCompiler(nil).Compile([]byte(`<?php
+class A {
+    public $a;
+    protected ?int $b;
+    public function __construct(private readonly string $c, $d = 1) {}
+}`), ctx)
+
+	class := ctx.ClassByName("A")
+	a, _ := class.Property("a")
+	b, _ := class.Property("b")
+	c, _ := class.Property("c")
+
+	assert.Equal(t, vm.Null{}, a.Default)
+	assert.Nil(t, a.Decl)
+	assert.Nil(t, b.Default)
+	assert.Equal(t, "?int", b.Decl.String())
+	assert.Equal(t, vm.Private, c.Visibility)
+	assert.True(t, c.Readonly)
+	assert.Equal(t, "string", c.Decl.String())
+	assert.Len(t, class.Props, 3)
+
+	errors := [...]struct{ input, err string }{
+		{"class A { public readonly $a; }", "Readonly property A::$a must have type"},
+		{"class A { public readonly int $a = 1; }", "Readonly property A::$a cannot have default value"},
+		{"class A { public static readonly int $a; }", "Static property A::$a cannot be readonly"},
+		{"class A { public int $a = null; }", "Default value for property of type int may not be null. Use the nullable type ?int to allow null default value"},
+		{"class A { public string $a = 1; }", "Cannot use int as default value for property A::$a of type string"},
+		{"class A { const C = 1.5; public ?int $a = self::C; }", "Cannot use float as default value for property A::$a of type ?int"},
+		{"class A { public callable $a; }", "Property A::$a cannot have type callable"},
+		{"class A { public $a; function __construct(public $a) {} }", "Cannot redeclare A::$a"},
+		{"class A { function f(public $a) {} }", "Cannot declare promoted property outside a constructor"},
+		{"class A { function __construct(public ...$a) {} }", "Cannot declare variadic promoted property"},
+		{"abstract class A { abstract function __construct(public $a); }", "Cannot declare promoted property in an abstract constructor"},
+	}
+
+	for _, e := range errors {
+		assert.PanicsWithValue(t, e.err, func() { NewCompiler(nil).Compile([]byte("<?php\n"+e.input), new(vm.GlobalContext)) }, e.input)
+	}
+}
+
+func TestNullsafe(t *testing.T) {
+	compiler := NewCompiler(nil)
+	ctx := new(vm.GlobalContext)
+	fn := compiler.Compile([]byte("<?php\n$a?->b->c();"), ctx)
+
+	expected := instructionsToBytecode([]uint64{
+		uint64(vm.OpLoad), 0, uint64(vm.OpDup), uint64(vm.OpIsSet), 1, uint64(vm.OpJumpFalse), 14,
+		uint64(vm.OpConst), 3, uint64(vm.OpPropertyFetch),
+		uint64(vm.OpConst), 4, uint64(vm.OpCallMethod), 0,
+		uint64(vm.OpPop), uint64(vm.OpReturn),
+	})
+	assert.Equal(t, expected.String(), fn.Instructions.String())
+	assert.PanicsWithValue(t, "Cannot combine nullsafe operator with Closure creation", func() {
+		NewCompiler(nil).Compile([]byte("<?php\n$a?->b(...);"), new(vm.GlobalContext))
+	})
+}
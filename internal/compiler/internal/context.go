@@ -7,14 +7,24 @@ import (
 )
 
 type Arg struct {
-	Name    string
-	Type    string
-	Default ast.Vertex
-	IsRef   bool
+	Name     string
+	Decl     *vm.TypeDecl // declared type, nil if parameter is not typed
+	Default  ast.Vertex
+	IsRef    bool
+	Variadic bool
+}
+
+// Loop is a loop or switch statement, which can be left by break and continue statements
+type Loop struct {
+	Breaks    []int // offsets of operands of jumps, which leave the statement
+	Continues []int // offsets of operands of jumps to the next iteration
+	Values    int   // number of values kept on the stack by the statement, e.g. foreach iterator
+	Switch    bool  // continue targeting switch acts like break
+	Finally   int   // number of try statements with finally block, which are open inside the statement
 }
 
 type Context interface {
-	Arg(string, string, ast.Vertex, bool) Arg
+	Arg(Arg) Arg
 	Parent() Context
 	Child(string) *FunctionContext
 	Global() *GlobalContext
@@ -26,6 +36,11 @@ type Context interface {
 	Var(string) int
 	AddLabel(string, uint64)
 	FindLabel(string) uint64
+	AddHandler(vm.Handler)
+	AddLine(int)
+	EnterLoop(*Loop)
+	LeaveLoop()
+	Loop(int) *Loop
 }
 
 type FunctionContext struct {
@@ -38,6 +53,13 @@ type FunctionContext struct {
 	Variables    []string
 	BuiltIn      bool
 	Labels       map[string]uint64
+	Handlers     []vm.Handler
+	Lines        []vm.Line
+	Bound        int          // number of variables captured by closure
+	Static       bool         // closure is declared static
+	Generator    bool         // function contains yield
+	Return       *vm.TypeDecl // declared return type, nil if function is not typed
+	Loops        []*Loop
 }
 
 func (ctx *FunctionContext) Parent() Context { return ctx.Context }
@@ -51,12 +73,11 @@ func (ctx *FunctionContext) Child(fn string) *FunctionContext {
 }
 func (ctx *FunctionContext) Global() *GlobalContext { return ctx.Context.Global() }
 func (ctx *FunctionContext) Bytecode() *vm.Bytecode { return &ctx.Instructions }
-func (ctx *FunctionContext) Arg(name string, _type string, def ast.Vertex, isRef bool) Arg {
-	if i := slices.IndexFunc(ctx.Args, func(arg Arg) bool { return arg.Name == name }); i >= 0 {
+func (ctx *FunctionContext) Arg(a Arg) Arg {
+	if i := slices.IndexFunc(ctx.Args, func(arg Arg) bool { return arg.Name == a.Name }); i >= 0 {
 		return ctx.Args[i]
 	}
 
-	a := Arg{name, _type, def, isRef}
 	ctx.Args = append(ctx.Args, a)
 	return a
 }
@@ -80,6 +101,11 @@ func (ctx *FunctionContext) Resolve(vertex ast.Vertex, aliasType string) string
 func (ctx *FunctionContext) Var(n string) int                  { return slices.Index(ctx.Variables, n) }
 func (ctx *FunctionContext) AddLabel(label string, pos uint64) { ctx.Labels[label] = pos }
 func (ctx *FunctionContext) FindLabel(label string) uint64     { return ctx.Labels[label] }
+func (ctx *FunctionContext) AddHandler(h vm.Handler)           { ctx.Handlers = append(ctx.Handlers, h) }
+func (ctx *FunctionContext) AddLine(line int)                  { ctx.Lines = addLine(ctx.Lines, ctx.Instructions, line) }
+func (ctx *FunctionContext) EnterLoop(l *Loop)                 { ctx.Loops = append(ctx.Loops, l) }
+func (ctx *FunctionContext) LeaveLoop()                        { ctx.Loops = ctx.Loops[:len(ctx.Loops)-1] }
+func (ctx *FunctionContext) Loop(level int) *Loop              { return loop(ctx.Loops, level) }
 
 type GlobalContext struct {
 	Names          *NameResolver
@@ -90,6 +116,9 @@ type GlobalContext struct {
 	Variables      []string
 	Functions      []string
 	Labels         map[string]uint64
+	Handlers       []vm.Handler
+	Lines          []vm.Line
+	Loops          []*Loop
 }
 
 func (ctx *GlobalContext) Parent() Context { return nil }
@@ -118,8 +147,8 @@ func (ctx *GlobalContext) Literal(n ast.Vertex, v vm.Value) int {
 	ctx.Constants[n] = slices.Index(ctx.Literals, v)
 	return ctx.Constants[n]
 }
-func (ctx *GlobalContext) Bytecode() *vm.Bytecode                   { return &ctx.Instructions }
-func (ctx *GlobalContext) Arg(string, string, ast.Vertex, bool) Arg { return Arg{} }
+func (ctx *GlobalContext) Bytecode() *vm.Bytecode { return &ctx.Instructions }
+func (ctx *GlobalContext) Arg(Arg) Arg            { return Arg{} }
 func (ctx *GlobalContext) Resolve(vertex ast.Vertex, aliasType string) string {
 	ctx.Names.Resolve(vertex, aliasType)
 
@@ -148,3 +177,32 @@ func (ctx *GlobalContext) Constant(c string) int {
 func (ctx *GlobalContext) Var(n string) int                  { return slices.Index(ctx.Variables, n) }
 func (ctx *GlobalContext) AddLabel(label string, pos uint64) { ctx.Labels[label] = pos }
 func (ctx *GlobalContext) FindLabel(label string) uint64     { return ctx.Labels[label] }
+func (ctx *GlobalContext) AddHandler(h vm.Handler)           { ctx.Handlers = append(ctx.Handlers, h) }
+func (ctx *GlobalContext) AddLine(line int)                  { ctx.Lines = addLine(ctx.Lines, ctx.Instructions, line) }
+func (ctx *GlobalContext) EnterLoop(l *Loop)                 { ctx.Loops = append(ctx.Loops, l) }
+func (ctx *GlobalContext) LeaveLoop()                        { ctx.Loops = ctx.Loops[:len(ctx.Loops)-1] }
+func (ctx *GlobalContext) Loop(level int) *Loop              { return loop(ctx.Loops, level) }
+
+// addLine records that instructions following bytecode are compiled from line
+func addLine(lines []vm.Line, bytecode vm.Bytecode, line int) []vm.Line {
+	pc := len(bytecode) >> 3
+
+	switch {
+	case len(lines) > 0 && lines[len(lines)-1].Line == line:
+		return lines
+	case len(lines) > 0 && lines[len(lines)-1].Pc == pc:
+		lines[len(lines)-1].Line = line
+		return lines
+	}
+
+	return append(lines, vm.Line{Pc: pc, Line: line})
+}
+
+// loop returns enclosing statement, which is level statements up from the innermost one, or nil
+func loop(loops []*Loop, level int) *Loop {
+	if level < 1 || level > len(loops) {
+		return nil
+	}
+
+	return loops[len(loops)-level]
+}
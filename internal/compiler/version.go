@@ -0,0 +1,91 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/VKCOM/php-parser/pkg/version"
+)
+
+// LatestVersion is the newest version of PHP, which syntax is supported by the parser
+const LatestVersion = "8.1"
+
+// oldestVersion is the oldest version of PHP, which semantics the virtual machine follows
+var oldestVersion = version.Version{Major: 7}
+
+// feature is a language construct, which is available since a version of PHP
+type feature struct {
+	name  string
+	since version.Version
+}
+
+var (
+	arrowFunctions      = feature{"arrow functions", version.Version{Major: 7, Minor: 4}}
+	coalesceAssignment  = feature{"null coalescing assignment operator", version.Version{Major: 7, Minor: 4}}
+	typedProperties     = feature{"typed properties", version.Version{Major: 7, Minor: 4}}
+	arraySpread         = feature{"spread operator in arrays", version.Version{Major: 7, Minor: 4}}
+	numericSeparator    = feature{"numeric literal separator", version.Version{Major: 7, Minor: 4}}
+	matchExpression     = feature{"match expression", version.Version{Major: 8}}
+	promotedProperties  = feature{"constructor property promotion", version.Version{Major: 8}}
+	nullsafeOperator    = feature{"nullsafe operator", version.Version{Major: 8}}
+	namedArguments      = feature{"named arguments", version.Version{Major: 8}}
+	throwExpression     = feature{"throw expression", version.Version{Major: 8}}
+	unionTypes          = feature{"union types", version.Version{Major: 8}}
+	mixedType           = feature{"mixed type", version.Version{Major: 8}}
+	staticReturnType    = feature{"static return type", version.Version{Major: 8}}
+	enumerations        = feature{"enumerations", version.Version{Major: 8, Minor: 1}}
+	neverType           = feature{"never type", version.Version{Major: 8, Minor: 1}}
+	intersectionTypes   = feature{"intersection types", version.Version{Major: 8, Minor: 1}}
+	newInInitializers   = feature{"new in initializers", version.Version{Major: 8, Minor: 1}}
+	firstClassCallables = feature{"first-class callable syntax", version.Version{Major: 8, Minor: 1}}
+	readonlyProperties  = feature{"readonly properties", version.Version{Major: 8, Minor: 1}}
+	explicitOctal       = feature{"explicit octal notation", version.Version{Major: 8, Minor: 1}}
+	readonlyClasses     = feature{"readonly classes", version.Version{Major: 8, Minor: 2}}
+)
+
+// ParseVersion parses version of PHP in "major.minor" form and checks that it can be targeted by the compiler
+func ParseVersion(v string) (*version.Version, error) {
+	ver, err := version.New(v)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid PHP version %q: %w", v, err)
+	}
+
+	latest, _ := version.New(LatestVersion)
+
+	if ver.Less(&oldestVersion) || ver.Greater(latest) {
+		return nil, fmt.Errorf("PHP version %s is not supported, supported versions are %d.%d to %s", v, oldestVersion.Major, oldestVersion.Minor, LatestVersion)
+	}
+
+	if err = ver.Validate(); err != nil {
+		return nil, fmt.Errorf("PHP version %s is not supported: %w", v, err)
+	}
+
+	return ver, nil
+}
+
+// SetVersion sets version of PHP targeted by scripts compiled later. Syntax of the version is parsed
+// and constructs introduced by later versions are rejected
+func (c *Compiler) SetVersion(v string) error {
+	ver, err := ParseVersion(v)
+
+	if err == nil {
+		c.version = ver
+	}
+
+	return err
+}
+
+// targetVersion returns version of PHP targeted by the compiler, which is the latest version by default
+func (c *Compiler) targetVersion() *version.Version {
+	if c.version == nil {
+		c.version, _ = ParseVersion(LatestVersion)
+	}
+
+	return c.version
+}
+
+// require rejects construct f, if it is not available in target version
+func (c *Compiler) require(f feature) {
+	if v := c.targetVersion(); v.Less(&f.since) {
+		panic(fmt.Sprintf("Cannot use %s before PHP %d.%d, target version is %d.%d", f.name, f.since.Major, f.since.Minor, v.Major, v.Minor))
+	}
+}
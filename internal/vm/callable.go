@@ -1,5 +1,11 @@
 package vm
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 type Callable interface {
 	Invoke(Context)
 }
@@ -10,22 +16,42 @@ type Arg struct {
 	ByRef    bool
 	Variadic bool
 	Default  Value
+	Decl     *TypeDecl // declared type of parameter of compiled function, which is checked by ASSERT_PARAM
 }
 
 type argList []Arg
 
-func (a argList) Map(ctx Context, args []Value) ([]Value, Throwable) {
-	if len(args) < len(a) {
-		return nil, NewThrowable("not enough arguments", EError)
+// Map converts arguments of built-in function fn to types of parameters and fills default values of arguments,
+// which are not passed
+func (a argList) Map(ctx Context, fn string, args []Value) ([]Value, Throwable) {
+	if passed := a.count(args); len(args) < len(a) || passed < a.required() {
+		return nil, a.countError(fn, passed)
 	}
 
 	for i, arg := range a {
+		if arg.Variadic {
+			continue
+		}
+
 		if args[i] == nil {
-			args[i] = arg.Default
+			if args[i] = arg.Default; args[i] == nil {
+				return nil, NewArgumentCountError(fmt.Sprintf("%s(): Argument #%d ($%s) not passed", fn, i+1, arg.Name))
+			}
 		}
 
 		if arg.Type > 0 {
-			args[i] = args[i].Cast(ctx, arg.Type)
+			v, ok := coerce(ctx, args[i], arg.Type)
+
+			// Strict typing mode of the caller only allows widening of integers to floats
+			if t := deref(args[i]).Type(); strictTypes(ctx) && t != arg.Type && (t != IntType || arg.Type != FloatType) {
+				ok = false
+			}
+
+			if !ok {
+				return nil, NewTypeError(fmt.Sprintf("%s(): Argument #%d ($%s) must be of type %s, %s given", fn, i+1, arg.Name, typeName(arg.Type), DebugType(args[i])))
+			}
+
+			args[i] = v
 		}
 
 		if arg.ByRef {
@@ -36,51 +62,537 @@ func (a argList) Map(ctx Context, args []Value) ([]Value, Throwable) {
 	return args, nil
 }
 
+// countError returns error of built-in function fn called with wrong number of arguments
+func (a argList) countError(fn string, passed int) Throwable {
+	required, expected, expects := a.required(), a.required(), "exactly"
+
+	switch {
+	case len(a) > 0 && a[len(a)-1].Variadic:
+		expects = "at least"
+	case passed > len(a):
+		if expected = len(a); required < len(a) {
+			expects = "at most"
+		}
+	case required < len(a):
+		expects = "at least"
+	}
+
+	if expected == 1 {
+		return NewArgumentCountError(fmt.Sprintf("%s() expects %s 1 argument, %d given", fn, expects, passed))
+	}
+
+	return NewArgumentCountError(fmt.Sprintf("%s() expects %s %d arguments, %d given", fn, expects, expected, passed))
+}
+
+// required returns number of arguments without default value
+func (a argList) required() (n int) {
+	for i, arg := range a {
+		if arg.Default == nil && !arg.Variadic {
+			n = i + 1
+		}
+	}
+
+	return n
+}
+
+// count returns number of passed arguments. Missing arguments are padded with nil,
+// values of variadic parameter are counted one by one
+func (a argList) count(args []Value) (n int) {
+	for i, arg := range args {
+		if i < len(a) && a[i].Variadic {
+			if rest, ok := arg.(*Array); ok && rest.Count(nil) > 0 {
+				n = i + int(rest.Count(nil))
+			}
+
+			break
+		}
+
+		if arg != nil {
+			n = i + 1
+		}
+	}
+
+	return n
+}
+
 type BuiltInFunction[RT Value] struct {
-	Args argList
-	Fn   func(Context, ...Value) RT
+	Name     string // name shown in diagnostics
+	Args     argList
+	Fn       func(Context, ...Value) RT
+	receiver bool // function is a method, which receives object or class as the first argument
 }
 
 func NewBuiltInFunction[RT Value, F ~func(Context, ...Value) RT](fn F, args ...Arg) BuiltInFunction[RT] {
-	return BuiltInFunction[RT]{args, fn}
+	return BuiltInFunction[RT]{Args: args, Fn: fn}
 }
 func (f BuiltInFunction[RT]) GetArgs() []Arg { return f.Args }
+
+// Named returns the function shown in diagnostics as name
+func (f BuiltInFunction[RT]) Named(name string) Callable {
+	f.Name = name
+	return f
+}
+
+// method returns the function as method name of class
+func (f BuiltInFunction[RT]) method(class, name String) Callable {
+	f.Name, f.receiver = string(class)+"::"+string(name), true
+	return f
+}
+
+// countError returns error of the function called with passed arguments, not counting the receiver of method
+func (f BuiltInFunction[RT]) countError(passed int) Throwable {
+	if f.receiver {
+		return f.Args[1:].countError(f.Name, passed)
+	}
+
+	return f.Args.countError(f.Name, passed)
+}
+
 func (f BuiltInFunction[RT]) Invoke(ctx Context) {
 	args := ctx.Slice(-len(f.Args), 0)
-	mapped, err := f.Args.Map(ctx, args)
+	params, passed := f.Args, args
 
-	if err != nil {
+	if f.receiver {
+		params, passed = params[1:], passed[1:]
+	}
+
+	// arguments are mapped in place, so the receiver is kept in front of them
+	if _, err := params.Map(ctx, f.Name, passed); err != nil {
 		ctx.Throw(err)
 		return
 	}
 
-	res := f.Fn(ctx, mapped...)
+	// values of variadic parameter are passed as trailing arguments
+	if n := len(f.Args); n > 0 && f.Args[n-1].Variadic {
+		rest := args[n-1].(*Array)
+		args = args[: n-1 : n-1]
+
+		for _, key := range rest.Keys(ctx) {
+			args = append(args, *rest.hash[key].Deref())
+		}
+	}
+
+	res := f.Fn(ctx, args...)
 	ctx.MovePointer(-len(f.Args))
 	ctx.Push(res)
 }
 
 type CompiledFunction struct {
+	Name         string // name shown in diagnostics
 	Instructions Bytecode
 	Args, Vars   int
+	Required     int       // number of leading parameters, which must be passed. Default values of the rest are evaluated by the function
+	Params       []Arg     // parameters, which named and variadic arguments are mapped to
+	Return       *TypeDecl // declared return type
+	Strict       bool      // function is declared in file with strict_types=1
+	Handlers     []Handler
+	Lines        []Line   // line table sorted by position of instructions
+	Variables    []String // names of variables, which are shown in diagnostics
+	Bound        int      // number of variables captured by closure
+	Static       bool     // closure is not bound to $this
+}
+
+// Line is an entry of line table of a function. Instructions from Pc up to Pc of the next entry are compiled
+// from the Line of source
+type Line struct {
+	Pc, Line int
+}
+
+// GetArgs returns parameters of the function. Functions without parameters metadata accept positional arguments only
+func (f CompiledFunction) GetArgs() []Arg {
+	if len(f.Params) < f.Args {
+		return make([]Arg, f.Args)
+	}
+
+	return f.Params
 }
 
 func (f CompiledFunction) Invoke(parent Context) {
 	global := parent.Global()
+	vars, overflow := f.Vars, global.overflow(f.Vars+f.Args)
+
+	// on overflow the frame is pushed without variables, as callers complete it before the error unwinds it
+	if overflow {
+		vars = 0
+	}
+
 	frame := global.NextFrame()
 	frame.ctx.Context = parent
 	frame.ctx.global = global
-	frame.ctx.vars = frame.ctx.global.Slice(-f.Args, f.Vars)
+	frame.ctx.vars = frame.ctx.global.Slice(-f.Args, vars)
+	frame.ctx.function, frame.ctx.params, frame.ctx.returns = f.Name, f.GetArgs(), f.Return
+	frame.ctx.strict, frame.ctx.extra = f.Strict, nil
+	frame.ctx.argc = f.passed(frame.ctx.vars[:f.Args])
+	missing := -1
 
 	for i := 0; i < len(frame.ctx.vars); i++ {
 		v := &frame.ctx.vars[i]
-		if *v == nil {
+
+		switch {
+		case i >= f.Args:
+			*v = Null{}
+		case *v != nil, i >= f.Required:
+			// optional parameter, which is not passed, stays undefined until ARG_PASSED evaluates its default value
+		default:
+			if missing < 0 {
+				missing = i
+			}
+
 			*v = Null{}
 		}
 	}
 
+	frame.ctx.this, frame.ctx.class, frame.ctx.static = nil, nil, nil
 	frame.ctx.pc = -1
-	frame.ctx.args = frame.ctx.vars[:len(frame.ctx.vars)-f.Vars]
+	frame.ctx.args = frame.ctx.vars[:len(frame.ctx.vars)-vars]
+	frame.ctx.names = f.Variables
 	frame.fp = parent.TopIndex() - f.Args
 	frame.bytecode = f.Instructions
-	parent.MovePointer(f.Vars + f.Args)
+	frame.handlers = f.Handlers
+	frame.lines = f.Lines
+
+	if !overflow {
+		parent.MovePointer(f.Vars + f.Args)
+	}
+
+	frame.base = parent.TopIndex()
+	frame.generator = nil
+
+	if missing >= 0 && !overflow {
+		frame.ctx.Throw(f.missingArgument(missing, frame.ctx.argc))
+	}
+}
+
+// passed returns number of arguments passed to parameters args. Missing arguments are nil,
+// values of variadic parameter are counted one by one
+func (f CompiledFunction) passed(args []Value) (n int) {
+	params := f.GetArgs()
+
+	for i, arg := range args {
+		if i == len(params)-1 && params[i].Variadic {
+			if rest, ok := arg.(*Array); ok && rest.Count(nil) > 0 {
+				n = i + int(rest.Count(nil))
+			}
+
+			break
+		}
+
+		if arg != nil {
+			n = i + 1
+		}
+	}
+
+	return n
+}
+
+// missingArgument returns error of required parameter i, which is not passed. Parameters skipped by named
+// arguments are reported by position, otherwise the function is called with too few arguments
+func (f CompiledFunction) missingArgument(i, passed int) Throwable {
+	name := functionName(f.Name)
+
+	if i < passed {
+		return NewArgumentCountError(fmt.Sprintf("%s(): Argument #%d ($%s) not passed", name, i+1, f.GetArgs()[i].Name))
+	}
+
+	expected := "exactly"
+
+	if f.Required < f.Args {
+		expected = "at least"
+	}
+
+	return NewArgumentCountError(fmt.Sprintf("Too few arguments to function %s(), %d passed and %s %d expected", name, passed, expected, f.Required))
+}
+
+// functionName returns name of function shown in diagnostics. Closures are distinguished by their number internally
+func functionName(name string) string {
+	name, _, _ = strings.Cut(name, "#")
+	return name
+}
+
+// passedArgs returns current values of arguments passed to the function including ones passed beyond its parameters
+func (ctx *FunctionContext) passedArgs() []Value {
+	values := make([]Value, 0, ctx.argc)
+
+	for i, arg := range ctx.args {
+		if len(values) == ctx.argc-len(ctx.extra) {
+			break
+		}
+
+		if i == len(ctx.params)-1 && ctx.params[i].Variadic {
+			rest := deref(arg).(*Array)
+
+			for _, key := range rest.Keys(ctx) {
+				if _, ok := key.(Int); ok {
+					values = append(values, deref(*rest.hash[key].Deref()))
+				}
+			}
+
+			break
+		}
+
+		if arg == nil {
+			arg = Null{}
+		}
+
+		values = append(values, deref(arg))
+	}
+
+	return append(values, ctx.extra...)
+}
+
+// functionScope returns context of user function, which called built-in function fn
+func functionScope(ctx Context, fn string) (*FunctionContext, Throwable) {
+	if scope, ok := ctx.(*FunctionContext); ok {
+		if _, global := scope.Context.(*GlobalContext); !global {
+			return scope, nil
+		}
+	}
+
+	return nil, NewError(fn + "() cannot be called from the global scope")
+}
+
+// FuncNumArgs => func_num_args()
+func FuncNumArgs(ctx Context, _ ...Value) Value {
+	scope, err := functionScope(ctx, "func_num_args")
+
+	if err != nil {
+		ctx.Throw(err)
+		return Int(-1)
+	}
+
+	return Int(scope.argc)
+}
+
+// FuncGetArgs => func_get_args()
+func FuncGetArgs(ctx Context, _ ...Value) Value {
+	scope, err := functionScope(ctx, "func_get_args")
+
+	if err != nil {
+		ctx.Throw(err)
+		return Null{}
+	}
+
+	args := NewArray(nil)
+
+	for _, v := range scope.passedArgs() {
+		*args.assign(ctx, nil).Deref() = v
+	}
+
+	return args
+}
+
+// packedArgs is an array of arguments, which are spread over parameters of the callee => f(...$args, name: $x).
+// Integer keys are positional arguments, string keys are names of parameters
+type packedArgs struct{ *Array }
+
+// unpackArgs spreads packed arguments on top of the stack. It returns number of positional arguments on the stack
+// and keys of named arguments of the pack
+func unpackArgs(ctx *FunctionContext, argc int) (int, *Array, []Value) {
+	if argc != 1 {
+		return argc, nil, nil
+	}
+
+	packed, ok := (*ctx.global.sp).(packedArgs)
+
+	if !ok {
+		return argc, nil, nil
+	}
+
+	var named []Value
+
+	ctx.global.MovePointer(-1)
+	argc = 0
+
+	if ctx.global.overflow(int(packed.Count(ctx))) {
+		return argc, nil, nil
+	}
+
+	for _, key := range packed.Keys(ctx) {
+		if _, ok := key.(String); ok {
+			named = append(named, key)
+			continue
+		}
+
+		ctx.global.Push(packed.hash[key])
+		argc++
+	}
+
+	return argc, packed.Array, named
+}
+
+// fitArgs pads or trims argc arguments on top of the stack to params of a function. Arguments exceeding variadic
+// parameter are collected into an array, packed arguments are spread by position and matched by name.
+// Arguments exceeding other parameters are removed from the stack and returned, so that func_get_args() sees them.
+// References passed to parameters, which are not declared by reference, are dereferenced.
+// On failure arguments are removed from the stack
+func fitArgs(ctx *FunctionContext, argc int, params []Arg) (extra []Value, ok bool) {
+	argc, packed, named := unpackArgs(ctx, argc)
+	expected := len(params)
+	var rest *Array
+
+	collect := func(key, v Value) {
+		if ref, ok := v.(Ref); ok && params[expected-1].ByRef {
+			rest.bind(ctx, key, ref)
+		} else {
+			*rest.assign(ctx, key).Deref() = deref(v)
+		}
+	}
+
+	if ctx.global.thrown != nil || ctx.global.overflow(expected) {
+		ctx.global.MovePointer(-argc)
+		return nil, false
+	}
+
+	if expected > 0 && params[expected-1].Variadic {
+		rest = NewArray(nil)
+
+		if extra := argc - expected + 1; extra > 0 {
+			for _, v := range ctx.global.Slice(-extra, 0) {
+				collect(nil, v)
+			}
+
+			ctx.global.MovePointer(-extra)
+			argc -= extra
+		}
+
+		for ; argc < expected-1; argc++ {
+			ctx.global.Push(nil)
+		}
+
+		ctx.global.Push(rest)
+		argc++
+	}
+
+	for ; argc < expected; argc++ {
+		ctx.global.Push(nil)
+	}
+
+	if argc > expected {
+		for _, v := range ctx.global.Slice(expected-argc, 0) {
+			extra = append(extra, deref(v))
+		}
+	}
+
+	ctx.global.MovePointer(expected - argc)
+	args := ctx.global.Slice(-expected, 0)
+
+	for _, key := range named {
+		name := string(key.(String))
+		i := slices.IndexFunc(params, func(arg Arg) bool { return arg.Name == name })
+
+		switch {
+		case (i < 0 || params[i].Variadic) && rest != nil:
+			collect(key, packed.hash[key])
+			continue
+		case i < 0:
+			ctx.Throw(NewError(fmt.Sprintf("Unknown named parameter $%s", name)))
+		case args[i] != nil:
+			ctx.Throw(NewError(fmt.Sprintf("Named parameter $%s overwrites previous argument", name)))
+		default:
+			args[i] = packed.hash[key]
+			continue
+		}
+
+		ctx.global.MovePointer(-expected)
+		return nil, false
+	}
+
+	for i, arg := range args {
+		if !params[i].ByRef {
+			args[i] = deref(arg)
+		}
+	}
+
+	return extra, true
+}
+
+// surplus rejects arguments exceeding parameters of built-in function fn. Arguments are removed from the stack
+// and the callee slot under them is replaced by null
+func surplus(ctx *FunctionContext, fn Callable, params int, extra []Value) bool {
+	builtIn, ok := fn.(interface{ countError(int) Throwable })
+
+	if !ok || len(extra) == 0 {
+		return false
+	}
+
+	ctx.Throw(builtIn.countError(params + len(extra)))
+	ctx.global.MovePointer(-params)
+	*ctx.global.sp = Null{}
+
+	return true
+}
+
+// passExtra attaches arguments exceeding parameters to frame of the called function
+func passExtra(frame *Frame, extra []Value) {
+	frame.ctx.extra = extra
+	frame.ctx.argc += len(extra)
+}
+
+// stackMethod is a built-in method, which works with arguments on the stack of the caller directly.
+// Like compiled methods, it replaces the object slot under the arguments with its result
+type stackMethod interface {
+	Callable
+	call(this Value, ctx *FunctionContext, argc int)
+}
+
+// callMethod invokes method m of this with argc arguments on top of the stack.
+// Arguments are preceded by the object slot, which is replaced by the result of the method.
+// Built-in methods receive the object as their first argument
+func callMethod(ctx *FunctionContext, m *Method, this Value, static *Class, argc int) {
+	if m.Static {
+		this = nil
+	}
+
+	if m.Abstract {
+		ctx.Throw(NewError(fmt.Sprintf("Cannot call abstract method %s::%s()", string(m.Class.Name), string(m.Name))))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	switch fn := m.Fn.(type) {
+	case CompiledFunction:
+		extra, ok := fitArgs(ctx, argc, fn.GetArgs())
+
+		if !ok {
+			*ctx.global.sp = Null{}
+			return
+		}
+
+		fn.Invoke(ctx)
+		frame := ctx.global.frame
+		passExtra(frame, extra)
+		frame.ctx.this, frame.ctx.class, frame.ctx.static = this, m.Class, static
+		frame.fp--
+	case stackMethod:
+		argc, _, named := unpackArgs(ctx, argc)
+
+		if len(named) > 0 {
+			ctx.Throw(NewError(fmt.Sprintf("Unknown named parameter $%s", string(named[0].(String)))))
+		}
+
+		if ctx.global.thrown != nil {
+			ctx.global.MovePointer(-argc)
+			*ctx.global.sp = Null{}
+			return
+		}
+
+		fn.call(this, ctx, argc)
+	default:
+		if this != nil {
+			ctx.global.Slice(-argc-1, -argc)[0] = this
+		}
+
+		params := fn.(interface{ GetArgs() []Arg }).GetArgs()[1:]
+		extra, ok := fitArgs(ctx, argc, params)
+
+		if !ok {
+			*ctx.global.sp = Null{}
+			return
+		}
+
+		if !surplus(ctx, fn, len(params), extra) {
+			fn.Invoke(ctx)
+		}
+	}
 }
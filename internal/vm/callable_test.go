@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestArgList_Map(t *testing.T) {
+	args := argList{{Name: "str", Type: StringType}, {Name: "flags", Type: IntType, Default: Int(0)}}
+	ctx := new(GlobalContext)
+
+	mapped, err := args.Map(ctx, "f", []Value{Int(1), nil})
+	assert.Nil(t, err)
+	assert.Equal(t, []Value{String("1"), Int(0)}, mapped)
+
+	_, err = args.Map(ctx, "f", []Value{nil, nil})
+	assert.IsType(t, &exception{}, err)
+	assert.Same(t, argumentCountError, err.(*exception).obj.class)
+	assert.Equal(t, "f() expects at least 1 argument, 0 given", err.Error())
+
+	_, err = args.Map(ctx, "f", []Value{String("a"), String("b")})
+	assert.Same(t, typeError, err.(*exception).obj.class)
+	assert.Equal(t, "f(): Argument #2 ($flags) must be of type int, string given", err.Error())
+
+	_, err = args.Map(ctx, "f", []Value{NewArray(nil), nil})
+	assert.Equal(t, "f(): Argument #1 ($str) must be of type string, array given", err.Error())
+
+	variadic := argList{{Name: "value"}, {Name: "values", Variadic: true}}
+	_, err = variadic.Map(ctx, "var_dump", []Value{nil, NewArray(nil)})
+	assert.Equal(t, "var_dump() expects at least 1 argument, 0 given", err.Error())
+}
+
+func TestFitArgs(t *testing.T) {
+	params := []Arg{{Name: "a"}, {Name: "b"}, {Name: "rest", Variadic: true}}
+	g := &GlobalContext{}
+	ctx := &FunctionContext{Context: g, global: g}
+	ctx.Init()
+
+	ctx.Push(Int(1))
+	_, ok := fitArgs(ctx, 1, params)
+	assert.True(t, ok)
+	assert.Equal(t, []Value{Int(1), nil, NewArray(nil)}, ctx.global.Slice(-3, 0))
+	ctx.global.MovePointer(-3)
+
+	ctx.Push(Int(1))
+	ctx.Push(Int(2))
+	ctx.Push(Int(3))
+	ctx.Push(Int(4))
+	_, ok = fitArgs(ctx, 4, params)
+	assert.True(t, ok)
+	rest := NewArray(nil)
+	rest.OffsetSet(ctx, Int(0), Int(3))
+	rest.OffsetSet(ctx, Int(1), Int(4))
+	assert.Equal(t, []Value{Int(1), Int(2), rest}, ctx.global.Slice(-3, 0))
+	ctx.global.MovePointer(-3)
+
+	packed := NewArray(nil)
+	packed.OffsetSet(ctx, Int(0), Int(1))
+	packed.OffsetSet(ctx, String("b"), Int(2))
+	packed.OffsetSet(ctx, String("c"), Int(3))
+	ctx.Push(packedArgs{packed})
+	_, ok = fitArgs(ctx, 1, params)
+	assert.True(t, ok)
+	rest = NewArray(nil)
+	rest.OffsetSet(ctx, String("c"), Int(3))
+	assert.Equal(t, []Value{Int(1), Int(2), rest}, ctx.global.Slice(-3, 0))
+	ctx.global.MovePointer(-3)
+
+	packed = NewArray(nil)
+	packed.OffsetSet(ctx, Int(0), Int(1))
+	packed.OffsetSet(ctx, String("a"), Int(2))
+	ctx.Push(packedArgs{packed})
+	_, ok = fitArgs(ctx, 1, params)
+	assert.False(t, ok)
+	assert.Equal(t, "Named parameter $a overwrites previous argument", ctx.global.thrown.Error())
+	ctx.global.thrown = nil
+
+	ctx.Push(Int(1))
+	ctx.Push(Int(2))
+	ctx.Push(Int(3))
+	extra, ok := fitArgs(ctx, 3, params[:1])
+	assert.True(t, ok)
+	assert.Equal(t, []Value{Int(2), Int(3)}, extra)
+	assert.Equal(t, []Value{Int(1)}, ctx.global.Slice(-1, 0))
+}
+
+func TestFuncGetArgs(t *testing.T) {
+	g := &GlobalContext{}
+	caller := &FunctionContext{Context: g, global: g}
+	rest := NewArray(nil)
+	rest.OffsetSet(caller, Int(0), Int(3))
+	rest.OffsetSet(caller, String("named"), Int(4))
+	ctx := &FunctionContext{
+		Context: caller,
+		global:  g,
+		args:    []Value{Int(1), Int(2), rest},
+		params:  []Arg{{Name: "a"}, {Name: "b"}, {Name: "rest", Variadic: true}},
+		argc:    3,
+	}
+
+	expected := NewArray(nil)
+	expected.OffsetSet(ctx, Int(0), Int(1))
+	expected.OffsetSet(ctx, Int(1), Int(2))
+	expected.OffsetSet(ctx, Int(2), Int(3))
+	assert.Equal(t, expected, FuncGetArgs(ctx))
+	assert.Equal(t, Int(3), FuncNumArgs(ctx))
+
+	ctx.args, ctx.params, ctx.argc = []Value{Int(1), nil}, []Arg{{Name: "a"}, {Name: "b"}}, 1
+	assert.Equal(t, []Value{Int(1)}, ctx.passedArgs())
+
+	ctx.argc, ctx.extra = 4, []Value{Int(5), Int(6)}
+	ctx.args[1] = Int(2)
+	assert.Equal(t, []Value{Int(1), Int(2), Int(5), Int(6)}, ctx.passedArgs())
+
+	FuncGetArgs(caller)
+	assert.Equal(t, "func_get_args() cannot be called from the global scope", g.thrown.Error())
+}
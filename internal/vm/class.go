@@ -0,0 +1,625 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//go:generate stringer -type=Visibility -linecomment
+type Visibility byte
+
+const (
+	Public    Visibility = iota // public
+	Protected                   // protected
+	Private                     // private
+)
+
+type Property struct {
+	Name       String
+	Class      *Class // declaring class
+	Visibility Visibility
+	Default    Value     // nil for typed property without default value, which is uninitialized
+	Decl       *TypeDecl // declared type, nil for untyped property
+	Readonly   bool
+}
+
+type Method struct {
+	Name       String
+	Class      *Class // declaring class
+	Visibility Visibility
+	Static     bool
+	Abstract   bool
+	Fn         Callable
+}
+
+type ClassConstant struct {
+	Name       String
+	Class      *Class // declaring class
+	Visibility Visibility
+	Value      Value
+}
+
+type Class struct {
+	Name       String
+	Parent     *Class
+	Interfaces []*Class // all interfaces implemented by class including inherited ones
+	Abstract   bool
+	Interface  bool
+	Trait      bool
+	Enum       bool
+	Readonly   bool    // all properties are readonly and dynamic properties cannot be created
+	Backing    Type    // type of values of backed enumeration
+	Cases      []*Enum // cases of enumeration in declaration order
+	Props      []*Property
+	Hidden     []*Property // private properties of ancestors, which are redeclared by the class or its ancestors
+	Statics    []*Property
+	Consts     map[String]*ClassConstant
+	Methods    map[String]*Method
+}
+
+func NewClass(name String) *Class {
+	return &Class{Name: name, Consts: make(map[String]*ClassConstant), Methods: make(map[String]*Method)}
+}
+
+// Extend inherits properties and methods of parent, which are not redeclared in class
+func (c *Class) Extend(parent *Class) {
+	c.Parent = parent
+	props := make([]*Property, 0, len(parent.Props)+len(c.Props))
+
+	for _, prop := range parent.Props {
+		if _, ok := c.Property(prop.Name); !ok {
+			props = append(props, prop)
+		} else if prop.Visibility == Private {
+			c.Hidden = append(c.Hidden, prop)
+		}
+	}
+
+	c.Props = append(props, c.Props...)
+	c.Hidden = append(c.Hidden, parent.Hidden...)
+
+	for _, prop := range parent.Statics {
+		if _, ok := c.StaticProperty(prop.Name); !ok {
+			c.Statics = append(c.Statics, prop)
+		}
+	}
+
+	for name, constant := range parent.Consts {
+		if _, ok := c.Consts[name]; !ok {
+			c.Consts[name] = constant
+		}
+	}
+
+	for name, method := range parent.Methods {
+		if _, ok := c.Methods[name]; !ok {
+			c.Methods[name] = method
+		}
+	}
+
+	c.Implement(parent.Interfaces...)
+}
+
+// Implement adds interfaces to class inheriting their constants and abstract methods, which are not declared in class
+func (c *Class) Implement(interfaces ...*Class) {
+	for _, iface := range interfaces {
+		if slices.Contains(c.Interfaces, iface) {
+			continue
+		}
+
+		c.Interfaces = append(c.Interfaces, iface)
+		c.Implement(iface.Interfaces...)
+
+		for name, constant := range iface.Consts {
+			if _, ok := c.Consts[name]; !ok {
+				c.Consts[name] = constant
+			}
+		}
+
+		for name, method := range iface.Methods {
+			if _, ok := c.Methods[name]; !ok {
+				c.Methods[name] = method
+			}
+		}
+	}
+}
+
+// AbstractMethods returns methods of class without implementation sorted by name
+func (c *Class) AbstractMethods() []*Method {
+	var methods []*Method
+
+	for _, method := range c.Methods {
+		if method.Abstract {
+			methods = append(methods, method)
+		}
+	}
+
+	slices.SortFunc(methods, func(a, b *Method) int { return strings.Compare(string(a.Name), string(b.Name)) })
+
+	return methods
+}
+
+func (c *Class) AddProperty(prop *Property) {
+	prop.Class = c
+	c.Props = append(c.Props, prop)
+}
+
+func (c *Class) AddStaticProperty(prop *Property) {
+	prop.Class = c
+	c.Statics = append(c.Statics, prop)
+}
+
+func (c *Class) AddConstant(constant *ClassConstant) {
+	constant.Class = c
+	c.Consts[constant.Name] = constant
+}
+
+func (c *Class) AddMethod(method *Method) {
+	method.Class = c
+
+	if fn, ok := method.Fn.(interface{ method(String, String) Callable }); ok {
+		method.Fn = fn.method(c.Name, method.Name)
+	}
+
+	c.Methods[String(strings.ToLower(string(method.Name)))] = method
+}
+
+func (c *Class) Property(name String) (*Property, bool) {
+	if i := slices.IndexFunc(c.Props, func(prop *Property) bool { return prop.Name == name }); i >= 0 {
+		return c.Props[i], true
+	}
+
+	return nil, false
+}
+
+func (c *Class) StaticProperty(name String) (*Property, bool) {
+	if i := slices.IndexFunc(c.Statics, func(prop *Property) bool { return prop.Name == name }); i >= 0 {
+		return c.Statics[i], true
+	}
+
+	return nil, false
+}
+
+func (c *Class) Method(name String) (*Method, bool) {
+	m, ok := c.Methods[String(strings.ToLower(string(name)))]
+	return m, ok
+}
+
+func (c *Class) InstanceOf(parent *Class) bool {
+	if parent.Interface {
+		return c == parent || slices.Contains(c.Interfaces, parent)
+	}
+
+	for ; c != nil; c = c.Parent {
+		if c == parent {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Class) NewInstance(ctx Context) *Object {
+	obj := NewObject(ctx, c)
+
+	for _, prop := range c.Hidden {
+		if prop.Default != nil {
+			obj.set(prop.hiddenKey(), prop.defaultValue())
+		}
+	}
+
+	for _, prop := range c.Props {
+		if prop.Default != nil {
+			obj.set(prop.Name, prop.defaultValue())
+		}
+	}
+
+	if ctx != nil && c.InstanceOf(throwableInterface) {
+		ctx.Global().locate(obj)
+	}
+
+	return obj
+}
+
+func (p *Property) defaultValue() Value {
+	switch v := p.Default.(type) {
+	case *Array:
+		return v.Copy()
+	case nil:
+		return Null{}
+	default:
+		return v
+	}
+}
+
+// hiddenKey returns key of private property p in objects of a subclass, which redeclares the property.
+// The key is qualified by the declaring class as in the array cast of an object
+func (p *Property) hiddenKey() String {
+	return "\x00" + p.Class.Name + "\x00" + p.Name
+}
+
+// uninitialized returns error raised, when typed property p is read before it is assigned
+func (p *Property) uninitialized() Throwable {
+	return NewError(fmt.Sprintf("Typed property %s::$%s must not be accessed before initialization", string(p.Class.Name), string(p.Name)))
+}
+
+// modify returns error raised, when initialized readonly property p is modified
+func (p *Property) modify() Throwable {
+	return NewError(fmt.Sprintf("Cannot modify readonly property %s::$%s", string(p.Class.Name), string(p.Name)))
+}
+
+// SetDefault converts default value v to declared type of p. Defaults are checked in strict typing mode,
+// which only widens integers to floats. Classes of the declaration are looked up in ctx
+func (p *Property) SetDefault(ctx *GlobalContext, v Value) bool {
+	if p.Decl != nil && v != nil {
+		accepted, ok := p.Decl.accept(&FunctionContext{Context: ctx, global: ctx, class: p.Class, static: p.Class}, v, true)
+
+		if !ok {
+			return false
+		}
+
+		v = accepted
+	}
+
+	p.Default = v
+	return true
+}
+
+// coerce converts v to declared type of p as it is assigned in typing mode of ctx
+func (p *Property) coerce(ctx *FunctionContext, v Value) (Value, bool) {
+	if p.Decl == nil {
+		return v, true
+	}
+
+	c, ok := p.Decl.accept(ctx, v, strictTypes(ctx))
+
+	if !ok {
+		ctx.Throw(NewTypeError(fmt.Sprintf("Cannot assign %s to property %s::$%s of type %s", DebugType(v), string(p.Class.Name), string(p.Name), p.Decl)))
+	}
+
+	return c, ok
+}
+
+// findStatic resolves static property name of the class as seen from scope
+func findStatic(scope, class *Class, name String) (*Property, Throwable) {
+	prop, ok := class.StaticProperty(name)
+
+	if !ok {
+		return nil, NewError(fmt.Sprintf("Access to undeclared static property %s::$%s", string(class.Name), string(name)))
+	}
+
+	if !canAccess(scope, prop.Class, prop.Visibility) {
+		return nil, NewError(fmt.Sprintf("Cannot access %s property %s::$%s", prop.Visibility, string(class.Name), string(name)))
+	}
+
+	return prop, nil
+}
+
+// findConstant resolves constant name of the class as seen from scope
+func findConstant(scope, class *Class, name String) (*ClassConstant, Throwable) {
+	constant, ok := class.Consts[name]
+
+	if !ok {
+		return nil, NewError(fmt.Sprintf("Undefined constant %s::%s", string(class.Name), string(name)))
+	}
+
+	if !canAccess(scope, constant.Class, constant.Visibility) {
+		return nil, NewError(fmt.Sprintf("Cannot access %s constant %s::%s", constant.Visibility, string(class.Name), string(name)))
+	}
+
+	return constant, nil
+}
+
+// resolveClass returns class referenced by v, which is either an object or name of a class.
+// forward reports if the reference is self, parent or static, which forward the called class
+func resolveClass(ctx *FunctionContext, v Value) (class *Class, forward bool) {
+	if obj, ok := object(v); ok {
+		return obj.class, false
+	}
+
+	name := v.AsString(ctx)
+
+	switch strings.ToLower(string(name)) {
+	case "self":
+		class = ctx.class
+	case "parent":
+		if ctx.class != nil {
+			if class = ctx.class.Parent; class == nil {
+				ctx.Throw(NewError("Cannot use \"parent\" when current class scope has no parent"))
+				return nil, true
+			}
+		}
+	case "static":
+		class = ctx.static
+	default:
+		if class = ctx.global.ClassByName(name); class == nil {
+			ctx.Throw(NewError(fmt.Sprintf("Class \"%s\" not found", string(name))))
+		}
+
+		return class, false
+	}
+
+	if class == nil {
+		ctx.Throw(NewError(fmt.Sprintf("Cannot use \"%s\" when no class scope is active", strings.ToLower(string(name)))))
+	}
+
+	return class, true
+}
+
+// property resolves property name of o as seen from scope of ctx. It returns key, which the property is stored
+// under, and its declaration, if any. Private property of the scope redeclared by a subclass has a key of its own
+func (o *Object) property(ctx *FunctionContext, name String) (String, *Property) {
+	if len(o.class.Hidden) > 0 && ctx.class != nil && ctx.class != o.class {
+		for _, prop := range o.class.Hidden {
+			if prop.Class == ctx.class && prop.Name == name {
+				return prop.hiddenKey(), prop
+			}
+		}
+	}
+
+	prop, _ := o.class.Property(name)
+	return name, prop
+}
+
+// accessible checks if declared property name of o can be accessed from scope of ctx
+func (o *Object) accessible(ctx *FunctionContext, name String) bool {
+	if _, prop := o.property(ctx, name); prop != nil && !canAccess(ctx.class, prop.Class, prop.Visibility) {
+		ctx.Throw(NewError(fmt.Sprintf("Cannot access %s property %s::$%s", prop.Visibility, string(o.class.Name), string(name))))
+		return false
+	}
+
+	return true
+}
+
+// visible returns property name of o, if it is set and accessible from scope of ctx. Access to other properties
+// is intercepted by magic methods
+func (o *Object) visible(ctx *FunctionContext, name String) (Ref, bool) {
+	key, prop := o.property(ctx, name)
+
+	if prop != nil && !canAccess(ctx.class, prop.Class, prop.Visibility) {
+		return Ref{}, false
+	}
+
+	return o.get(key)
+}
+
+// declared returns property name declared by class of o, if it is accessible from scope of ctx
+func (o *Object) declared(ctx *FunctionContext, name String) (*Property, bool) {
+	if _, prop := o.property(ctx, name); prop != nil && canAccess(ctx.class, prop.Class, prop.Visibility) {
+		return prop, true
+	}
+
+	return nil, false
+}
+
+func (o *Object) fetch(ctx *FunctionContext, name String) Value {
+	if ref, ok := o.visible(ctx, name); ok {
+		return deref(ref)
+	}
+
+	if prop, ok := o.declared(ctx, name); ok && prop.Decl != nil {
+		ctx.Throw(prop.uninitialized())
+		return Null{}
+	}
+
+	if v, ok := o.magic(ctx, guardGet, "__get", name); ok {
+		return v
+	}
+
+	if !o.accessible(ctx, name) {
+		return Null{}
+	}
+
+	ctx.Throw(NewThrowable(fmt.Sprintf("Undefined property: %s::$%s", string(o.class.Name), string(name)), EWarning))
+	return Null{}
+}
+
+// ref returns a reference to property name of o. Undeclared property is created
+func (o *Object) ref(ctx *FunctionContext, name String) Ref {
+	if !o.accessible(ctx, name) {
+		return NewRef(nil)
+	}
+
+	key, prop := o.property(ctx, name)
+
+	if ref, ok := o.get(key); ok {
+		return ref
+	}
+
+	if prop == nil && o.class.Readonly {
+		ctx.Throw(NewError(fmt.Sprintf("Cannot create dynamic property %s::$%s", string(o.class.Name), string(name))))
+		return NewRef(nil)
+	}
+
+	return o.set(key, Null{})
+}
+
+// write returns a reference to property name of o, which is modified in place => $x->prop['test'] = 1.
+// Value read by __get() is modified indirectly, so the change is not stored in the object
+func (o *Object) write(ctx *FunctionContext, name String) Ref {
+	prop, declared := o.declared(ctx, name)
+
+	if ref, ok := o.visible(ctx, name); ok {
+		if declared && prop.Readonly {
+			ctx.Throw(prop.modify())
+			return NewRef(nil)
+		}
+
+		return ref
+	}
+
+	if declared && prop.Decl != nil {
+		ctx.Throw(prop.uninitialized())
+		return NewRef(nil)
+	}
+
+	if v, ok := o.magic(ctx, guardGet, "__get", name); ok {
+		return NewRef(&v)
+	}
+
+	return o.ref(ctx, name)
+}
+
+// assign sets property name of o to v and returns the assigned value. Value of typed property is coerced
+// to the declared type. Undeclared and inaccessible properties are set by __set()
+func (o *Object) assign(ctx *FunctionContext, name String, v Value) Value {
+	prop, declared := o.declared(ctx, name)
+
+	if _, ok := o.visible(ctx, name); !ok && (!declared || prop.Decl == nil) {
+		if _, ok = o.magic(ctx, guardSet, "__set", name, v); ok {
+			return v
+		}
+	}
+
+	if declared {
+		var ok bool
+
+		if v, ok = prop.coerce(ctx, v); !ok || !o.initialize(ctx, prop) {
+			return v
+		}
+	}
+
+	assignTryRef(o.ref(ctx, name).Deref(), v)
+	return v
+}
+
+// initialize checks that readonly property prop of o can be assigned from scope of ctx. Readonly property
+// is initialized once from scope of the declaring class
+func (o *Object) initialize(ctx *FunctionContext, prop *Property) bool {
+	if !prop.Readonly {
+		return true
+	}
+
+	if _, ok := o.visible(ctx, prop.Name); ok {
+		ctx.Throw(prop.modify())
+		return false
+	}
+
+	if ctx.class != prop.Class {
+		ctx.Throw(NewError(fmt.Sprintf("Cannot initialize readonly property %s::$%s from %s", string(prop.Class.Name), string(prop.Name), scopeName(ctx.class))))
+		return false
+	}
+
+	return true
+}
+
+// bind makes property name of o the reference ref => [&$x->prop] = $y. Value of typed property is coerced
+// to the declared type, readonly property cannot be referenced
+func (o *Object) bind(ctx *FunctionContext, name String, ref Ref) {
+	if prop, ok := o.declared(ctx, name); ok {
+		if prop.Readonly {
+			ctx.Throw(prop.modify())
+			return
+		}
+
+		v, ok := prop.coerce(ctx, deref(ref))
+
+		if !ok {
+			return
+		}
+
+		*ref.Deref() = v
+	}
+
+	o.ref(ctx, name)
+	key, _ := o.property(ctx, name)
+
+	if _, ok := o.get(key); ok {
+		o.props[key] = ref
+	}
+}
+
+// isset reports if property name of o is set for isset(). Inaccessible properties are checked by __isset()
+func (o *Object) isset(ctx *FunctionContext, name String) bool {
+	if ref, ok := o.visible(ctx, name); ok {
+		return deref(ref) != Null{}
+	}
+
+	set, ok := o.magic(ctx, guardIsSet, "__isset", name)
+	return ok && bool(set.AsBool(ctx))
+}
+
+// quiet reads property name of o for ?? operator and for objects and arrays tested by isset(). Undefined
+// and inaccessible properties give null, unless __isset() reports them as set and __get() reads them
+func (o *Object) quiet(ctx *FunctionContext, name String) Value {
+	if ref, ok := o.visible(ctx, name); ok {
+		return deref(ref)
+	}
+
+	if set, ok := o.magic(ctx, guardIsSet, "__isset", name); ok && bool(set.AsBool(ctx)) {
+		if v, ok := o.magic(ctx, guardGet, "__get", name); ok {
+			return v
+		}
+	}
+
+	return Null{}
+}
+
+// remove unsets property name of o. Undeclared and inaccessible properties are unset by __unset().
+// Unset typed property becomes uninitialized
+func (o *Object) remove(ctx *FunctionContext, name String) {
+	prop, declared := o.declared(ctx, name)
+
+	if _, ok := o.visible(ctx, name); !ok && (!declared || prop.Decl == nil) {
+		if _, ok = o.magic(ctx, guardUnset, "__unset", name); ok {
+			return
+		}
+	}
+
+	if declared && prop.Readonly {
+		if _, ok := o.visible(ctx, name); ok || ctx.class != prop.Class {
+			ctx.Throw(NewError(fmt.Sprintf("Cannot unset readonly property %s::$%s", string(prop.Class.Name), string(name))))
+			return
+		}
+	}
+
+	if o.accessible(ctx, name) {
+		key, _ := o.property(ctx, name)
+		o.unset(key)
+	}
+}
+
+// canAccess checks if a member declared in class with visibility v is accessible from scope
+func canAccess(scope, class *Class, v Visibility) bool {
+	switch v {
+	case Private:
+		return scope == class
+	case Protected:
+		return scope != nil && (scope.InstanceOf(class) || class.InstanceOf(scope))
+	default:
+		return true
+	}
+}
+
+// findMethod resolves method name of the class as seen from scope. Private methods of scope take precedence
+func findMethod(scope, class *Class, name String) (*Method, Throwable) {
+	if scope != nil && class.InstanceOf(scope) {
+		if m, ok := scope.Method(name); ok && m.Class == scope && m.Visibility == Private {
+			return m, nil
+		}
+	}
+
+	m, ok := class.Method(name)
+
+	if !ok {
+		return nil, NewError(fmt.Sprintf("Call to undefined method %s::%s()", string(class.Name), string(name)))
+	}
+
+	if !canAccess(scope, m.Class, m.Visibility) {
+		return nil, NewError(fmt.Sprintf("Call to %s method %s::%s() from %s", m.Visibility, string(class.Name), string(name), scopeName(scope)))
+	}
+
+	return m, nil
+}
+
+func scopeName(scope *Class) string {
+	if scope == nil {
+		return "global scope"
+	}
+
+	return fmt.Sprintf("scope %s", string(scope.Name))
+}
+
+var stdClass = NewClass("stdClass")
+
+// coreClasses are available in every GlobalContext
+var coreClasses = []*Class{stdClass}
@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestClass_Extend(t *testing.T) {
+	parent := NewClass("A")
+	parent.AddProperty(&Property{Name: "x", Default: Int(1)})
+	parent.AddProperty(&Property{Name: "y", Visibility: Protected, Default: Int(2)})
+	parent.AddMethod(&Method{Name: "foo"})
+	parent.AddMethod(&Method{Name: "Bar"})
+
+	child := NewClass("B")
+	child.AddProperty(&Property{Name: "y", Default: Int(3)})
+	child.AddProperty(&Property{Name: "z"})
+	child.AddMethod(&Method{Name: "foo"})
+	child.Extend(parent)
+
+	assert.True(t, child.InstanceOf(parent))
+	assert.False(t, parent.InstanceOf(child))
+
+	names := make([]String, 0, len(child.Props))
+	for _, prop := range child.Props {
+		names = append(names, prop.Name)
+	}
+	assert.Equal(t, []String{"x", "y", "z"}, names)
+
+	foo, _ := child.Method("FOO")
+	assert.Same(t, child, foo.Class)
+	bar, _ := child.Method("bar")
+	assert.Same(t, parent, bar.Class)
+}
+
+func TestFindMethod(t *testing.T) {
+	parent := NewClass("A")
+	parent.AddMethod(&Method{Name: "secret", Visibility: Private})
+	parent.AddMethod(&Method{Name: "shared", Visibility: Protected})
+	child := NewClass("B")
+	child.AddMethod(&Method{Name: "secret"})
+	child.Extend(parent)
+
+	tests := [...]struct {
+		name         string
+		scope, class *Class
+		method       String
+		declaring    *Class
+		err          string
+	}{
+		{"public from global scope", nil, child, "secret", child, ""},
+		{"private of scope takes precedence", parent, child, "secret", parent, ""},
+		{"protected from child scope", child, child, "shared", parent, ""},
+		{"protected from global scope", nil, child, "shared", nil, "Call to protected method B::shared() from global scope"},
+		{"undefined", nil, child, "missing", nil, "Call to undefined method B::missing()"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := findMethod(test.scope, test.class, test.method)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Same(t, test.declaring, m.Class)
+		})
+	}
+}
+
+func TestClass_NewInstance(t *testing.T) {
+	ctx := new(GlobalContext)
+	class := NewClass("A")
+	class.AddProperty(&Property{Name: "list", Default: NewArray(nil)})
+
+	first, second := class.NewInstance(ctx), class.NewInstance(ctx)
+	first.AsArray(ctx).OffsetGet(ctx, String("list")).(*Array).OffsetSet(ctx, Int(0), Int(1))
+
+	assert.Equal(t, Int(0), second.AsArray(ctx).OffsetGet(ctx, String("list")).(*Array).Count(ctx))
+	assert.NotEqual(t, first.id, second.id)
+}
+
+func TestClass_ExtendStatics(t *testing.T) {
+	parent := NewClass("A")
+	parent.AddStaticProperty(&Property{Name: "x", Default: Int(1)})
+	parent.AddStaticProperty(&Property{Name: "y", Default: Int(2)})
+	parent.AddConstant(&ClassConstant{Name: "C", Value: Int(1)})
+	parent.AddConstant(&ClassConstant{Name: "D", Visibility: Private, Value: Int(2)})
+
+	child := NewClass("B")
+	child.AddStaticProperty(&Property{Name: "y", Default: Int(3)})
+	child.AddConstant(&ClassConstant{Name: "C", Value: Int(4)})
+	child.Extend(parent)
+
+	x, _ := child.StaticProperty("x")
+	assert.Same(t, parent, x.Class)
+	y, _ := child.StaticProperty("y")
+	assert.Same(t, child, y.Class)
+
+	assert.Equal(t, Int(4), child.Consts["C"].Value)
+
+	_, err := findConstant(nil, child, "D")
+	assert.Equal(t, "Cannot access private constant B::D", err.Error())
+	_, err = findStatic(nil, child, "z")
+	assert.Equal(t, "Access to undeclared static property B::$z", err.Error())
+
+	g := new(GlobalContext)
+	*g.static(x).Deref() = Int(5)
+	assert.Equal(t, Int(5), *g.static(x).Deref())
+	assert.Equal(t, Int(1), x.Default)
+}
+
+func TestClass_Implement(t *testing.T) {
+	named := NewClass("Named")
+	named.Interface = true
+	named.AddMethod(&Method{Name: "name", Abstract: true})
+	named.AddConstant(&ClassConstant{Name: "C", Value: Int(1)})
+
+	greets := NewClass("Greets")
+	greets.Interface = true
+	greets.AddMethod(&Method{Name: "greet", Abstract: true})
+	greets.Implement(named)
+
+	parent := NewClass("A")
+	parent.Abstract = true
+	parent.AddMethod(&Method{Name: "name"})
+	parent.Implement(greets)
+
+	child := NewClass("B")
+	child.Extend(parent)
+
+	assert.True(t, child.InstanceOf(named))
+	assert.True(t, child.InstanceOf(greets))
+	assert.True(t, greets.InstanceOf(named))
+	assert.False(t, named.InstanceOf(greets))
+	assert.Equal(t, Int(1), child.Consts["C"].Value)
+
+	abstract := child.AbstractMethods()
+	assert.Len(t, abstract, 1)
+	assert.Equal(t, String("greet"), abstract[0].Name)
+}
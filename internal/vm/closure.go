@@ -0,0 +1,495 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+var closureClass = NewClass("Closure")
+
+func init() {
+	closureClass.AddMethod(&Method{Name: "bind", Static: true, Fn: NewBuiltInFunction(closureBind,
+		Arg{Name: "class"},
+		Arg{Name: "closure"},
+		Arg{Name: "newThis"},
+		Arg{Name: "newScope", Default: String("static")},
+	)})
+	closureClass.AddMethod(&Method{Name: "bindTo", Fn: NewBuiltInFunction(closureBindTo,
+		Arg{Name: "this"},
+		Arg{Name: "newThis"},
+		Arg{Name: "newScope", Default: String("static")},
+	)})
+	closureClass.AddMethod(&Method{Name: "fromCallable", Static: true, Fn: NewBuiltInFunction(closureFromCallable,
+		Arg{Name: "class"},
+		Arg{Name: "callback"},
+	)})
+	closureClass.AddMethod(&Method{Name: "call", Fn: closureMethod(closureCall)})
+	closureClass.AddMethod(&Method{Name: "__invoke", Fn: closureMethod((*Closure).invoke)})
+	coreClasses = append(coreClasses, closureClass)
+}
+
+// Closure is an anonymous function with variables captured from the scope, where it was created.
+// Closures are also created from named functions and methods by Closure::fromCallable() and strlen(...) syntax
+type Closure struct {
+	*Object
+
+	Fn     Callable
+	Bound  []Value // captured variables, which follow arguments of the function
+	this   Value
+	scope  *Class
+	static *Class
+	method *Method // method, which closure was created from
+}
+
+func (c *Closure) DebugInfo(Context) string { return fmt.Sprintf("object(Closure)#%d (0) {\n}", c.id) }
+
+// invoke calls closure with argc arguments on top of the stack. Arguments are preceded by the closure slot,
+// which is replaced by the result
+func (c *Closure) invoke(ctx *FunctionContext, argc int) {
+	if c.method != nil {
+		callMethod(ctx, c.method, c.this, c.static, argc)
+		return
+	}
+
+	fn, ok := c.Fn.(CompiledFunction)
+
+	if !ok {
+		callFunction(ctx, c.Fn, argc)
+		return
+	}
+
+	extra, ok := fitArgs(ctx, argc, fn.GetArgs())
+
+	if !ok {
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	fn.Invoke(ctx)
+	frame := ctx.global.frame
+	passExtra(frame, extra)
+	copy(frame.ctx.vars[fn.Args:], c.Bound)
+	frame.ctx.this, frame.ctx.class, frame.ctx.static = c.this, c.scope, c.static
+	frame.fp--
+}
+
+// bind duplicates closure with new $this and scope. Invalid bindings emit a warning and return nil
+func (c *Closure) bind(ctx *FunctionContext, this Value, scope *Class) *Closure {
+	obj, isObject := object(this)
+
+	if fn, ok := c.Fn.(CompiledFunction); ok && fn.Static && isObject {
+		ctx.Throw(NewThrowable("Cannot bind an instance to a static closure", EWarning))
+		return nil
+	}
+
+	if c.method != nil {
+		switch {
+		case scope != c.scope:
+			ctx.Throw(NewThrowable("Cannot rebind scope of closure created from method", EWarning))
+			return nil
+		case !isObject && c.this != nil:
+			ctx.Throw(NewThrowable("Cannot unbind $this of method", EWarning))
+			return nil
+		case isObject && !obj.class.InstanceOf(c.method.Class):
+			ctx.Throw(NewThrowable(fmt.Sprintf("Cannot bind method %s::%s() to object of class %s", string(c.method.Class.Name), string(c.method.Name), string(obj.class.Name)), EWarning))
+			return nil
+		}
+	}
+
+	bound := *c
+	bound.Object = NewObject(ctx, closureClass)
+	bound.this, bound.scope, bound.static = nil, scope, scope
+
+	if isObject {
+		bound.this, bound.static = this, obj.class
+	}
+
+	return &bound
+}
+
+// closureMethod is a method of Closure, which works with arguments on the stack of the caller directly
+type closureMethod func(c *Closure, ctx *FunctionContext, argc int)
+
+func (closureMethod) Invoke(Context) { panic("closure method must be called by callMethod") }
+func (fn closureMethod) call(this Value, ctx *FunctionContext, argc int) {
+	fn(this.(*Closure), ctx, argc)
+}
+
+// closureScope resolves scope argument of Closure::bind(). "static" keeps current scope of closure
+func closureScope(ctx *FunctionContext, c *Closure, scope Value) (*Class, bool) {
+	if obj, ok := object(scope); ok {
+		return obj.class, true
+	}
+
+	if _, ok := scope.(Null); ok {
+		return nil, true
+	}
+
+	if strings.EqualFold(string(scope.AsString(ctx)), "static") {
+		return c.scope, true
+	}
+
+	class := ctx.global.ClassByName(scope.AsString(ctx))
+
+	if class == nil {
+		ctx.Throw(NewError(fmt.Sprintf("Class \"%s\" not found", string(scope.AsString(ctx)))))
+	}
+
+	return class, class != nil
+}
+
+// closureBind => Closure::bind($closure, $newThis, $newScope)
+func closureBind(ctx Context, args ...Value) Value {
+	return closureBindTo(ctx, args[1:]...)
+}
+
+// closureBindTo => $closure->bindTo($newThis, $newScope)
+func closureBindTo(ctx Context, args ...Value) Value {
+	fctx := ctx.(*FunctionContext)
+	c, ok := deref(args[0]).(*Closure)
+
+	if !ok {
+		fctx.Throw(NewTypeError(fmt.Sprintf("Closure::bind(): Argument #1 ($closure) must be of type Closure, %s given", DebugType(args[0]))))
+		return Null{}
+	}
+
+	scope, ok := closureScope(fctx, c, deref(args[2]))
+
+	if !ok {
+		return Null{}
+	}
+
+	if bound := c.bind(fctx, deref(args[1]), scope); bound != nil {
+		return bound
+	}
+
+	return Null{}
+}
+
+// closureCall => $closure->call($newThis, ...$args)
+func closureCall(c *Closure, ctx *FunctionContext, argc int) {
+	if argc == 0 {
+		ctx.Throw(NewArgumentCountError("Closure::call() expects at least 1 argument, 0 given"))
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	args := ctx.global.Slice(-argc, 0)
+	this := deref(args[0])
+	obj, ok := object(this)
+
+	if !ok {
+		ctx.Throw(NewTypeError(fmt.Sprintf("Closure::call(): Argument #1 ($newThis) must be of type object, %s given", DebugType(this))))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	copy(args, args[1:])
+	ctx.global.MovePointer(-1)
+
+	if bound := c.bind(ctx, this, obj.class); bound != nil {
+		bound.invoke(ctx, argc-1)
+		return
+	}
+
+	ctx.global.MovePointer(1 - argc)
+	*ctx.global.sp = Null{}
+}
+
+// closureFromCallable => Closure::fromCallable($callback)
+func closureFromCallable(ctx Context, args ...Value) Value {
+	if c := makeCallableClosure(ctx.(*FunctionContext), deref(args[1])); c != nil {
+		return c
+	}
+
+	return Null{}
+}
+
+// makeCallableClosure creates closure calling function or method referenced by callable value in scope of ctx
+func makeCallableClosure(ctx *FunctionContext, callable Value) *Closure {
+	switch callable := callable.(type) {
+	case *Closure:
+		return callable
+	case String:
+		if class, method, ok := strings.Cut(string(callable), "::"); ok {
+			return methodClosure(ctx, String(class), String(method))
+		}
+
+		if fn := ctx.global.FunctionByName(callable); fn != nil {
+			return &Closure{Object: NewObject(ctx, closureClass), Fn: fn}
+		}
+
+		return nil
+	case *Array:
+		target, ok := callable.access(Int(0))
+		method, found := callable.access(Int(1))
+
+		if ok && found && callable.Count(ctx) == 2 {
+			return methodClosure(ctx, deref(target), method.AsString(ctx))
+		}
+
+		ctx.Throw(NewError("Array callback must have exactly two elements"))
+	default:
+		if _, ok := invokable(callable); ok {
+			return methodClosure(ctx, callable, "__invoke")
+		}
+
+		ctx.Throw(NewError("Value not callable"))
+	}
+
+	return nil
+}
+
+// isCallable reports whether callable value v can be called from scope of ctx
+func isCallable(ctx *FunctionContext, v Value) bool {
+	switch v := deref(v).(type) {
+	case *Closure:
+		return true
+	case String:
+		if class, method, ok := strings.Cut(string(v), "::"); ok {
+			return isCallableMethod(ctx, String(class), String(method))
+		}
+
+		return ctx.global.function(v) != nil
+	case *Array:
+		target, ok := v.access(Int(0))
+		method, found := v.access(Int(1))
+
+		return ok && found && v.Count(ctx) == 2 && isCallableMethod(ctx, deref(target), method.AsString(ctx))
+	default:
+		_, ok := invokable(v)
+		return ok
+	}
+}
+
+// isCallableMethod reports whether method name of target, which is an object or a class name, can be called
+func isCallableMethod(ctx *FunctionContext, target Value, name String) bool {
+	var class *Class
+
+	if obj, ok := object(target); ok {
+		class = obj.class
+	} else if target.Type() == StringType {
+		switch strings.ToLower(string(target.(String))) {
+		case "self", "static", "parent":
+			class, _ = resolveClass(ctx, target)
+		default:
+			class = ctx.global.ClassByName(target.(String))
+		}
+	}
+
+	if class == nil {
+		return false
+	}
+
+	if _, err := findMethod(ctx.class, class, name); err == nil {
+		return true
+	}
+
+	if _, ok := object(target); !ok {
+		target = contextThis(ctx, class)
+	}
+
+	_, _, ok := fallback(class, target)
+	return ok
+}
+
+// contextThis returns $this of ctx, if it is an instance of class
+func contextThis(ctx *FunctionContext, class *Class) Value {
+	if obj, ok := object(ctx.this); ok && obj.class.InstanceOf(class) {
+		return ctx.this
+	}
+
+	return nil
+}
+
+// methodClosure creates closure of method name of target, which is an object or a class name
+func methodClosure(ctx *FunctionContext, target Value, name String) *Closure {
+	var this Value
+
+	class, forward := resolveClass(ctx, target)
+
+	if class == nil {
+		return nil
+	}
+
+	static := class
+
+	if _, ok := object(target); ok {
+		this = target
+	} else if forward && ctx.static != nil {
+		static = ctx.static
+	}
+
+	m, err := findMethod(ctx.class, class, name)
+
+	if err != nil {
+		ctx.Throw(err)
+		return nil
+	}
+
+	if m.Static {
+		this = nil
+	} else if this == nil {
+		if obj, ok := object(ctx.this); ok && obj.class.InstanceOf(m.Class) {
+			this = ctx.this
+		} else {
+			ctx.Throw(NewError(fmt.Sprintf("Non-static method %s::%s() cannot be called statically", string(m.Class.Name), string(m.Name))))
+			return nil
+		}
+	}
+
+	return &Closure{Object: NewObject(ctx, closureClass), Fn: m.Fn, this: this, scope: m.Class, static: static, method: m}
+}
+
+// MakeCallable => strlen(...); $f(...)
+func MakeCallable(ctx *FunctionContext) {
+	if c := makeCallableClosure(ctx, deref(*ctx.global.sp)); c != nil {
+		*ctx.global.sp = c
+	} else {
+		*ctx.global.sp = Null{}
+	}
+}
+
+// MakeMethodCallable => $obj->method(...); Foo::bar(...)
+func MakeMethodCallable(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	if c := methodClosure(ctx, deref(*ctx.global.sp), name); c != nil {
+		*ctx.global.sp = c
+	} else {
+		*ctx.global.sp = Null{}
+	}
+}
+
+// MakeClosure => function () use ($x) {}; fn() => $x
+func MakeClosure(ctx *FunctionContext) {
+	fn := ctx.global.Functions[ctx.global.r1].(CompiledFunction)
+	closure := &Closure{Object: NewObject(ctx, closureClass), Fn: fn, scope: ctx.class, static: ctx.static}
+	closure.Bound = append([]Value(nil), ctx.global.Slice(-fn.Bound, 0)...)
+	ctx.global.MovePointer(-fn.Bound)
+
+	for i, v := range closure.Bound {
+		if !v.IsRef() {
+			closure.Bound[i] = deref(v)
+		}
+	}
+
+	if !fn.Static {
+		closure.this = ctx.this
+	}
+
+	ctx.global.Push(closure)
+}
+
+// CallDynamic => $f(); "strlen"($s); [$obj, "method"](); "Foo::bar"()
+func CallDynamic(ctx *FunctionContext) {
+	argc := int(ctx.global.r1)
+	callee := deref(ctx.global.Slice(-argc-1, -argc)[0])
+
+	switch callee := callee.(type) {
+	case *Closure:
+		callee.invoke(ctx, argc)
+		return
+	case *Object:
+		if m, ok := invokable(callee); ok {
+			callMethod(ctx, m, callee, callee.class, argc)
+			return
+		}
+
+		ctx.Throw(NewError(fmt.Sprintf("Object of type %s is not callable", string(callee.class.Name))))
+	case String:
+		if class, method, ok := strings.Cut(string(callee), "::"); ok {
+			callStaticCallable(ctx, String(class), String(method), argc)
+			return
+		}
+
+		if fn := ctx.global.FunctionByName(callee); fn != nil {
+			callFunction(ctx, fn, argc)
+			return
+		}
+	case *Array:
+		target, ok := callee.access(Int(0))
+		method, found := callee.access(Int(1))
+
+		if ok && found && callee.Count(ctx) == 2 {
+			if obj, ok := object(target); ok {
+				name := method.AsString(ctx)
+				m, err := findMethod(ctx.class, obj.class, name)
+
+				if err == nil {
+					callMethod(ctx, m, obj, obj.class, argc)
+					return
+				}
+
+				if callFallback(ctx, obj.class, obj, obj.class, name, argc) {
+					return
+				}
+
+				ctx.Throw(err)
+			} else {
+				callStaticCallable(ctx, target.AsString(ctx), method.AsString(ctx), argc)
+				return
+			}
+		}
+
+		ctx.Throw(NewError("Array callback must have exactly two elements"))
+	default:
+		ctx.Throw(NewError("Value not callable"))
+	}
+
+	ctx.global.MovePointer(-argc)
+	*ctx.global.sp = Null{}
+}
+
+// callStaticCallable calls method of class referenced by callable string or array
+func callStaticCallable(ctx *FunctionContext, class, method String, argc int) {
+	c, _ := resolveClass(ctx, class)
+
+	if c == nil {
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	m, err := findMethod(ctx.class, c, method)
+
+	if err != nil {
+		if callFallback(ctx, c, contextThis(ctx, c), c, method, argc) {
+			return
+		}
+
+		ctx.Throw(err)
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	callMethod(ctx, m, contextThis(ctx, m.Class), c, argc)
+}
+
+// callFunction invokes fn with argc arguments on top of the stack. Arguments are preceded by the callee slot,
+// which is replaced by the result
+func callFunction(ctx *FunctionContext, fn Callable, argc int) {
+	params := fn.(interface{ GetArgs() []Arg }).GetArgs()
+	extra, ok := fitArgs(ctx, argc, params)
+
+	if !ok || surplus(ctx, fn, len(params), extra) {
+		if !ok {
+			*ctx.global.sp = Null{}
+		}
+
+		return
+	}
+
+	switch fn := fn.(type) {
+	case CompiledFunction:
+		fn.Invoke(ctx)
+		passExtra(ctx.global.frame, extra)
+		ctx.global.frame.fp--
+	default:
+		fn.Invoke(ctx)
+		res := ctx.global.Pop()
+		*ctx.global.sp = res
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"slices"
+	"strings"
 	"sync"
 	"unsafe"
 )
@@ -13,9 +14,13 @@ import (
 const frameSize = int(unsafe.Sizeof(Frame{}))
 
 type Frame struct {
-	ctx      FunctionContext
-	bytecode Bytecode
-	fp       int
+	ctx       FunctionContext
+	bytecode  Bytecode
+	handlers  []Handler
+	lines     []Line
+	fp        int
+	base      int        // top of the stack after variables of the function
+	generator *Generator // generator, which is executed in the frame
 }
 
 type Context interface {
@@ -34,17 +39,47 @@ type Context interface {
 	Output() io.Writer
 }
 
+const (
+	maxFrames = 999 // number of frames on the stack of a thread
+
+	// stackReserve is the number of values and frames allocated above the limits of a thread. An instruction
+	// pushes a few values at most, so it does not write past the stack, before the overflow is detected
+	stackReserve = 16
+)
+
+// thread is a stack of values and frames executed by the engine. Every fiber has a thread of its own.
+// Pointers to the stacks are initialized one element below their bottom, so an element is reserved there
+type thread struct {
+	frame *Frame
+	Stack[Value]
+
+	frames []Frame
+}
+
+func (t *thread) init() {
+	t.stack = make([]Value, 1+stackSize+stackReserve)[1:]
+	t.Stack.Init()
+	t.frames = make([]Frame, 1+maxFrames+stackReserve)[1:]
+	t.frame = (*Frame)(unsafe.Add(unsafe.Pointer(&t.frames[0]), -frameSize))
+}
+
 type GlobalContext struct {
 	context.Context
-	Stack[Value]
+	*thread
 
-	frames [999]Frame
-	frame  *Frame
+	fiber *Fiber // fiber being executed, nil for the main thread
 
 	Constants     []Value
 	Functions     []Callable
 	FunctionNames []String
+	Classes       []*Class
+	StaticVars    []Value // storage of static variables declared in functions
+	File          String  // path of the main script, which is reported by exceptions
 	initialized   sync.Once
+	objects       int // last allocated object id
+	statics       map[*Property]Ref
+	globals       []Value   // variables of the main script
+	thrown        Throwable // error raised by current instruction
 
 	in  io.Reader
 	out io.Writer
@@ -68,24 +103,115 @@ func NewGlobalContext(ctx context.Context, in io.Reader, out io.Writer) GlobalCo
 }
 
 func (g *GlobalContext) FunctionByName(name String) Callable {
-	defer func() {
-		if err := recover(); err != nil {
-			g.Throw(NewThrowable(fmt.Sprintf("Function \"%s\" does not exist", name), EError))
+	fn := g.function(name)
+
+	if fn == nil {
+		g.Throw(NewError(fmt.Sprintf("Call to undefined function %s()", strings.TrimPrefix(string(name), "\\"))))
+	}
+
+	return fn
+}
+
+// function returns function declared with name or nil
+func (g *GlobalContext) function(name String) Callable {
+	name = String(strings.TrimPrefix(string(name), "\\"))
+	i := slices.IndexFunc(g.FunctionNames, func(fn String) bool { return strings.EqualFold(string(fn), string(name)) })
+
+	if i < 0 || i >= len(g.Functions) || g.Functions[i] == nil {
+		return nil
+	}
+
+	return g.Functions[i]
+}
+func (g *GlobalContext) ClassByName(name String) *Class {
+	for _, classes := range [...][]*Class{g.Classes, coreClasses} {
+		if i := slices.IndexFunc(classes, func(class *Class) bool { return strings.EqualFold(string(class.Name), string(name)) }); i >= 0 {
+			return classes[i]
 		}
-	}()
+	}
+
+	return nil
+}
+// static returns storage of static property, which is initialized with default value on first access
+func (g *GlobalContext) static(prop *Property) Ref {
+	if g.statics == nil {
+		g.statics = make(map[*Property]Ref)
+	}
+
+	ref, ok := g.statics[prop]
 
-	return g.GetFunction(slices.Index(g.FunctionNames, name))
+	if !ok {
+		v := prop.defaultValue()
+		ref = NewRef(&v)
+		g.statics[prop] = ref
+	}
+
+	return ref
+}
+func (g *GlobalContext) nextObjectId() int {
+	g.objects++
+	return g.objects
 }
 func (g *GlobalContext) Init() {
 	g.initialized.Do(func() {
-		g.Stack.Init()
-		g.frame = (*Frame)(unsafe.Add(unsafe.Pointer(&g.frames[0]), -frameSize))
+		if g.thread == nil {
+			g.thread = new(thread)
+		}
+
+		g.thread.init()
 	})
 }
 func (g *GlobalContext) Parent() Context                { return nil }
 func (g *GlobalContext) Global() *GlobalContext         { return g }
 func (g *GlobalContext) GetFunction(index int) Callable { return g.Functions[index] }
-func (g *GlobalContext) Throw(Throwable)                { /* TODO */ }
+func (g *GlobalContext) Throw(t Throwable) {
+	if t.Level()&(EError|ECoreError|ECompileError|EUserError|ERecoverableError) == 0 {
+		fmt.Fprintf(os.Stderr, "PHP %s:  %s\n", t.Level(), t.Error())
+		return
+	}
+
+	if e, ok := t.(*exception); ok && e.obj.id == 0 {
+		e.obj.id = g.nextObjectId()
+		g.locate(e.obj)
+	}
+
+	if g.thrown == nil {
+		g.thrown = t
+	}
+}
+
+// unwind transfers control to the nearest handler of thrown exception. Frames without handler are discarded
+// down to bottom. Exception, which is not caught above bottom, is propagated to the frame below it or
+// to the resumer of the fiber. If exception is not caught at all, execution stops and exception is returned
+func (g *GlobalContext) unwind(bottom *Frame) Throwable {
+	t := g.thrown
+	g.thrown = nil
+	e, ok := t.(*exception)
+
+	for uintptr(unsafe.Pointer(g.frame)) >= uintptr(unsafe.Pointer(bottom)) {
+		if ok && g.frame.catch(e) {
+			return nil
+		}
+
+		frame := g.PopFrame()
+		g.Sp(frame.fp)
+
+		if frame.generator != nil {
+			frame.generator.finish()
+		}
+	}
+
+	if bottom != &g.frames[0] || g.fiber != nil {
+		g.thrown = t
+		return nil
+	}
+
+	if ok {
+		return e.uncaught()
+	}
+
+	return t
+}
 func (g *GlobalContext) NextFrame() *Frame {
 	g.frame = (*Frame)(unsafe.Add(unsafe.Pointer(g.frame), frameSize))
 	return g.frame
@@ -95,11 +221,25 @@ func (g *GlobalContext) PopFrame() *Frame {
 	g.frame = (*Frame)(unsafe.Add(unsafe.Pointer(g.frame), -frameSize))
 	return frame
 }
-func (g *GlobalContext) Run(fn CompiledFunction) {
+// Run executes fn and returns uncaught exception or fatal error, which stopped execution
+func (g *GlobalContext) Run(fn CompiledFunction) (err Throwable) {
 	g.Init()
 	fn.Invoke(g)
+	g.globals = g.frame.ctx.vars
 
-	for uintptr(unsafe.Pointer(g.frame)) >= uintptr(unsafe.Pointer(&g.frames[0])) && uintptr(unsafe.Pointer(g.frame)) <= uintptr(unsafe.Pointer(&g.frames[996])) {
+	return g.execute(&g.frames[0])
+}
+
+// execute runs frames until bottom frame returns. Frames below bottom belong to instructions, which are suspended
+// until nested execution completes, e.g. when a generator is resumed
+func (g *GlobalContext) execute(bottom *Frame) (err Throwable) {
+	if g.thrown != nil {
+		if err = g.unwind(bottom); err != nil {
+			return err
+		}
+	}
+
+	for uintptr(unsafe.Pointer(g.frame)) >= uintptr(unsafe.Pointer(bottom)) {
 		g.frame.ctx.pc++
 
 		switch g.frame.bytecode.ReadOperation(&g.frame.ctx) {
@@ -170,7 +310,7 @@ func (g *GlobalContext) Run(fn CompiledFunction) {
 		case OpConcat:
 			Concat(&g.frame.ctx)
 		case OpUnset:
-			// TODO: Unset
+			Unset(&g.frame.ctx)
 		case OpForEachInit:
 			ForEachInit(&g.frame.ctx)
 		case OpForEachNext:
@@ -181,8 +321,30 @@ func (g *GlobalContext) Run(fn CompiledFunction) {
 			Throw(&g.frame.ctx)
 		case OpCallByName:
 			CallByName(&g.frame.ctx)
-		case OpAssertType:
-			AssertType(&g.frame.ctx)
+		case OpDup:
+			Dup(&g.frame.ctx)
+		case OpThis:
+			This(&g.frame.ctx)
+		case OpPropertyFetch:
+			PropertyFetch(&g.frame.ctx)
+		case OpPropertyWrite:
+			PropertyWrite(&g.frame.ctx)
+		case OpPropertyAssign:
+			PropertyAssign(&g.frame.ctx)
+		case OpPropertyBind:
+			PropertyBind(&g.frame.ctx)
+		case OpStaticPropertyFetch:
+			StaticPropertyFetch(&g.frame.ctx)
+		case OpStaticPropertyWrite:
+			StaticPropertyWrite(&g.frame.ctx)
+		case OpStaticPropertyAssign:
+			StaticPropertyAssign(&g.frame.ctx)
+		case OpStaticPropertyBind:
+			StaticPropertyBind(&g.frame.ctx)
+		case OpClassConstFetch:
+			ClassConstFetch(&g.frame.ctx)
+		case OpInstanceOf:
+			InstanceOf(&g.frame.ctx)
 		case OpAssign:
 			Assign(&g.frame.ctx)
 		case OpAssignAdd:
@@ -233,6 +395,12 @@ func (g *GlobalContext) Run(fn CompiledFunction) {
 			JumpFalse(&g.frame.ctx)
 		case OpCall:
 			Call(&g.frame.ctx)
+		case OpCallMethod:
+			CallMethod(&g.frame.ctx)
+		case OpCallStatic:
+			CallStatic(&g.frame.ctx)
+		case OpNew:
+			New(&g.frame.ctx)
 		case OpEcho:
 			Echo(&g.frame.ctx)
 		case OpIsSet:
@@ -243,8 +411,87 @@ func (g *GlobalContext) Run(fn CompiledFunction) {
 			ForEachValue(&g.frame.ctx)
 		case OpForEachValueRef:
 			ForEachValueRef(&g.frame.ctx)
+		case OpEndFinally:
+			EndFinally(&g.frame.ctx)
+		case OpClosure:
+			MakeClosure(&g.frame.ctx)
+		case OpCallDynamic:
+			CallDynamic(&g.frame.ctx)
+		case OpCallable:
+			MakeCallable(&g.frame.ctx)
+		case OpMethodCallable:
+			MakeMethodCallable(&g.frame.ctx)
+		case OpGenerator:
+			Generate(&g.frame.ctx)
+		case OpYield:
+			Yield(&g.frame.ctx)
+		case OpYieldFrom:
+			YieldFrom(&g.frame.ctx)
+		case OpArrayAccessQuiet:
+			ArrayAccessQuiet(&g.frame.ctx)
+		case OpPropertyFetchQuiet:
+			PropertyFetchQuiet(&g.frame.ctx)
+		case OpJumpTable:
+			JumpTable(&g.frame.ctx)
+		case OpMatchError:
+			MatchError(&g.frame.ctx)
+		case OpGlobalRef:
+			GlobalRef(&g.frame.ctx)
+		case OpStaticRef:
+			StaticRef(&g.frame.ctx)
+		case OpBind:
+			Bind(&g.frame.ctx)
+		case OpArrayBind:
+			ArrayBind(&g.frame.ctx)
+		case OpJumpOut:
+			JumpOut(&g.frame.ctx)
+		case OpLoadQuiet:
+			LoadQuiet(&g.frame.ctx)
+		case OpPropertyUnset:
+			PropertyUnset(&g.frame.ctx)
+		case OpConcatN:
+			ConcatN(&g.frame.ctx)
+		case OpArraySpread:
+			ArraySpread(&g.frame.ctx)
+		case OpClone:
+			Clone(&g.frame.ctx)
+		case OpSkipArg:
+			SkipArg(&g.frame.ctx)
+		case OpPropertyRef:
+			PropertyRef(&g.frame.ctx)
+		case OpStaticPropertyRef:
+			StaticPropertyRef(&g.frame.ctx)
+		case OpPropertyIsSet:
+			PropertyIsSet(&g.frame.ctx)
+		case OpPackArgs:
+			PackArgs(&g.frame.ctx)
+		case OpArgPassed:
+			ArgPassed(&g.frame.ctx)
+		case OpAssertParam:
+			AssertParam(&g.frame.ctx)
+
+		}
+
+		if g.TopIndex() >= stackSize {
+			g.overflow(0)
 		}
+
+		if g.thrown != nil {
+			err = g.unwind(bottom)
+		}
+	}
+
+	return err
+}
+
+// overflow throws Error, if n more values or another frame do not fit on the stacks of the thread
+func (g *GlobalContext) overflow(n int) bool {
+	if g.TopIndex()+n < stackSize && uintptr(unsafe.Pointer(g.frame)) < uintptr(unsafe.Pointer(&g.frames[maxFrames-1])) {
+		return false
 	}
+
+	g.Throw(NewError("Maximum call stack size reached. Infinite recursion?"))
+	return true
 }
 func (g *GlobalContext) Output() io.Writer { return g.out }
 func (g *GlobalContext) Input() io.Reader  { return g.in }
@@ -254,7 +501,17 @@ type FunctionContext struct {
 
 	global     *GlobalContext // for faster access to GlobalContext
 	vars, args []Value
+	function   string    // name of the function
+	params     []Arg     // parameters of the function
+	returns    *TypeDecl // declared return type of the function
+	strict     bool      // function is declared in strict typing mode
+	extra      []Value   // arguments passed beyond parameters of the function
+	argc       int       // number of arguments passed to the function
+	names      []String  // names of variables, which are shown in diagnostics
 	pc, fp     int // Registers
+
+	this          Value // object or case of enumeration
+	class, static *Class // scope of the method being executed and class it was called on
 }
 
 func (ctx *FunctionContext) FunctionByName(name String) Callable {
@@ -263,6 +520,7 @@ func (ctx *FunctionContext) FunctionByName(name String) Callable {
 func (ctx *FunctionContext) Output() io.Writer         { return ctx.global.Output() }
 func (ctx *FunctionContext) Input() io.Reader          { return ctx.global.Input() }
 func (ctx *FunctionContext) Throw(throwable Throwable) { ctx.global.Throw(throwable) }
+func (ctx *FunctionContext) name() string              { return functionName(ctx.function) }
 func (ctx *FunctionContext) Arg(num int) Value         { return ctx.args[num] }
 func (ctx *FunctionContext) Parent() Context           { return ctx.Context }
 func (ctx *FunctionContext) Global() *GlobalContext    { return ctx.global }
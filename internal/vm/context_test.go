@@ -50,8 +50,6 @@ func BenchmarkCompiledFunction_Invoke(b *testing.B) {
 	var bytecode Bytecode
 	bytecode = binary.NativeEndian.AppendUint64(bytecode, uint64(OpConst))
 	bytecode = binary.NativeEndian.AppendUint64(bytecode, 0)
-	bytecode = binary.NativeEndian.AppendUint64(bytecode, uint64(OpAssertType))
-	bytecode = binary.NativeEndian.AppendUint64(bytecode, uint64(IntType))
 	bytecode = binary.NativeEndian.AppendUint64(bytecode, uint64(OpCall))
 	bytecode = binary.NativeEndian.AppendUint64(bytecode, 0)
 	bytecode = binary.NativeEndian.AppendUint64(bytecode, uint64(OpReturn))
@@ -100,7 +98,6 @@ func BenchmarkBuiltInFunction_Invoke(b *testing.B) {
 
 	for _, op := range []uint64{
 		uint64(OpConst), 0,
-		uint64(OpAssertType), uint64(IntType),
 		uint64(OpCall), 0,
 		uint64(OpReturn),
 	} {
@@ -0,0 +1,275 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DeclFlag is a type of type declaration, which is not a type of value
+type DeclFlag uint8
+
+const (
+	MixedDecl DeclFlag = 1 << iota
+	CallableDecl
+	IterableDecl
+	VoidDecl
+	NeverDecl
+	StaticDecl
+	FalseDecl
+	TrueDecl
+)
+
+// TypeDecl is a type declared for parameter or return value of a function
+type TypeDecl struct {
+	Types        Type     // types of values accepted by the declaration
+	Flags        DeclFlag // pseudo types accepted by the declaration
+	Classes      []String // classes of union type or classes intersected by intersection type
+	Intersection bool
+}
+
+// String returns declaration as it is shown in error messages
+func (d *TypeDecl) String() string {
+	if d.Flags&MixedDecl != 0 {
+		return "mixed"
+	}
+
+	sep := "|"
+
+	if d.Intersection {
+		sep = "&"
+	}
+
+	names := make([]string, 0, len(d.Classes)+4)
+
+	for _, class := range d.Classes {
+		names = append(names, string(class))
+	}
+
+	for _, pseudo := range [...]struct {
+		flag DeclFlag
+		name string
+	}{{StaticDecl, "static"}, {CallableDecl, "callable"}, {IterableDecl, "iterable"}} {
+		if d.Flags&pseudo.flag != 0 {
+			names = append(names, pseudo.name)
+		}
+	}
+
+	for _, t := range [...]Type{ObjectType, ArrayType, StringType, IntType, FloatType, BoolType} {
+		if d.Types&t != 0 {
+			names = append(names, typeName(t))
+		}
+	}
+
+	switch {
+	case d.Types&BoolType != 0:
+	case d.Flags&FalseDecl != 0:
+		names = append(names, "false")
+	case d.Flags&TrueDecl != 0:
+		names = append(names, "true")
+	}
+
+	switch {
+	case d.Flags&VoidDecl != 0:
+		names = append(names, "void")
+	case d.Flags&NeverDecl != 0:
+		names = append(names, "never")
+	}
+
+	if d.Types&NullType != 0 {
+		if len(names) == 1 {
+			return "?" + names[0]
+		}
+
+		names = append(names, "null")
+	}
+
+	return strings.Join(names, sep)
+}
+
+// accept checks if v matches the declaration and returns the value converted to declared type.
+// In coercive typing mode scalars are converted to a scalar type of the declaration, strict mode only widens
+// integers to floats
+func (d *TypeDecl) accept(ctx *FunctionContext, v Value, strict bool) (Value, bool) {
+	v = deref(v)
+
+	if d.Flags&MixedDecl != 0 {
+		return v, true
+	}
+
+	switch v := v.(type) {
+	case Null:
+		return v, d.Types&NullType != 0
+	case Bool:
+		if d.Types&BoolType != 0 || v && d.Flags&TrueDecl != 0 || !v && d.Flags&FalseDecl != 0 {
+			return v, true
+		}
+	case Int, Float, String:
+		if d.Types&v.Type() != 0 || d.Flags&CallableDecl != 0 && isCallable(ctx, v) {
+			return v, true
+		}
+	case *Array:
+		return v, d.Types&ArrayType != 0 || d.Flags&IterableDecl != 0 || d.Flags&CallableDecl != 0 && isCallable(ctx, v)
+	default:
+		if obj, ok := object(v); ok && d.acceptObject(ctx, obj, v) {
+			return v, true
+		}
+
+		if !strict && d.Types&StringType != 0 && stringable(v) {
+			return v.AsString(ctx), true
+		}
+
+		return v, false
+	}
+
+	if i, ok := v.(Int); ok && d.Types&FloatType != 0 {
+		return Float(i), true
+	}
+
+	if strict {
+		return v, false
+	}
+
+	return d.coerce(ctx, v)
+}
+
+// acceptObject checks if object obj, which is value v, is accepted by the declaration
+func (d *TypeDecl) acceptObject(ctx *FunctionContext, obj *Object, v Value) bool {
+	switch {
+	case d.Types&ObjectType != 0:
+		return true
+	case d.Flags&StaticDecl != 0 && ctx.static != nil && obj.class.InstanceOf(ctx.static):
+		return true
+	case d.Flags&IterableDecl != 0 && obj.class.InstanceOf(traversableInterface):
+		return true
+	case d.Flags&CallableDecl != 0 && isCallable(ctx, v):
+		return true
+	}
+
+	for _, name := range d.Classes {
+		var class *Class
+
+		switch strings.ToLower(string(name)) {
+		case "self":
+			class = ctx.class
+		case "parent":
+			if ctx.class != nil {
+				class = ctx.class.Parent
+			}
+		default:
+			class = ctx.global.ClassByName(name)
+		}
+
+		instance := class != nil && obj.class.InstanceOf(class)
+
+		if instance != d.Intersection {
+			return instance
+		}
+	}
+
+	return d.Intersection && len(d.Classes) > 0
+}
+
+// coerce converts scalar v to a scalar type of the declaration. Integers are preferred to floats, which are
+// preferred to strings and booleans. Numeric strings keep integer or float kind of their value, if it is declared
+func (d *TypeDecl) coerce(ctx *FunctionContext, v Value) (Value, bool) {
+	order := [...]Type{IntType, FloatType, StringType, BoolType}
+
+	switch v := v.(type) {
+	case Float:
+		if d.Types&IntType != 0 && (v == Float(math.Trunc(float64(v))) || d.Types&StringType == 0) {
+			break
+		}
+
+		order[0] = 0
+	case String:
+		n, ok := parseNumeric(v)
+
+		if !ok {
+			order[0], order[1] = 0, 0
+			break
+		}
+
+		if n.Type() == FloatType {
+			order[0], order[1] = FloatType, IntType
+		}
+	}
+
+	for _, t := range order {
+		if t == 0 || d.Types&t == 0 {
+			continue
+		}
+
+		if c, ok := coerce(ctx, v, t); ok {
+			return c, true
+		}
+	}
+
+	return v, false
+}
+
+// strictTypes reports if calls made in ctx are checked in strict typing mode
+func strictTypes(ctx Context) bool {
+	if fctx, ok := ctx.(*FunctionContext); ok {
+		return fctx.strict
+	}
+
+	return false
+}
+
+// AssertParam => function f(int $a). Argument of typed parameter is converted to declared type.
+// Arguments are checked in typing mode of the caller
+func AssertParam(ctx *FunctionContext) {
+	i := int(ctx.global.r1)
+	param := ctx.params[i]
+	strict := strictTypes(ctx.Context)
+	v := &ctx.vars[i]
+
+	if (*v).IsRef() {
+		v = (*v).(Ref).Deref()
+	}
+
+	if !param.Variadic {
+		if accepted, ok := param.Decl.accept(ctx, *v, strict); ok {
+			*v = accepted
+		} else {
+			ctx.Throw(NewTypeError(fmt.Sprintf("%s(): Argument #%d ($%s) must be of type %s, %s given", ctx.name(), i+1, param.Name, param.Decl, DebugType(*v))))
+		}
+
+		return
+	}
+
+	rest := (*v).(*Array)
+
+	for n, key := range rest.Keys(ctx) {
+		arg := rest.hash[key].Deref()
+
+		if accepted, ok := param.Decl.accept(ctx, *arg, strict); ok {
+			*arg = accepted
+			continue
+		}
+
+		if _, named := key.(String); named {
+			ctx.Throw(NewTypeError(fmt.Sprintf("%s(): Argument #%d ($%s) must be of type %s, %s given", ctx.name(), i+1+n, string(key.(String)), param.Decl, DebugType(*arg))))
+		} else {
+			ctx.Throw(NewTypeError(fmt.Sprintf("%s(): Argument #%d must be of type %s, %s given", ctx.name(), i+1+n, param.Decl, DebugType(*arg))))
+		}
+
+		return
+	}
+}
+
+// assertReturn converts value returned by the function to its return type
+func assertReturn(ctx *FunctionContext, v Value) (Value, bool) {
+	if ctx.returns == nil || ctx.returns.Flags&VoidDecl != 0 {
+		return v, true
+	}
+
+	accepted, ok := ctx.returns.accept(ctx, v, ctx.strict)
+
+	if !ok {
+		ctx.Throw(NewTypeError(fmt.Sprintf("%s(): Return value must be of type %s, %s returned", ctx.name(), ctx.returns, DebugType(v))))
+	}
+
+	return accepted, ok
+}
@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTypeDecl_String(t *testing.T) {
+	assert.Equal(t, "?int", (&TypeDecl{Types: IntType | NullType}).String())
+	assert.Equal(t, "string|int|null", (&TypeDecl{Types: IntType | StringType | NullType}).String())
+	assert.Equal(t, "A|array|false", (&TypeDecl{Types: ArrayType, Flags: FalseDecl, Classes: []String{"A"}}).String())
+	assert.Equal(t, "A&B", (&TypeDecl{Classes: []String{"A", "B"}, Intersection: true}).String())
+	assert.Equal(t, "mixed", (&TypeDecl{Types: NullType, Flags: MixedDecl}).String())
+}
+
+func TestTypeDecl_accept(t *testing.T) {
+	g := &GlobalContext{}
+	ctx := &FunctionContext{Context: g, global: g}
+
+	cases := [...]struct {
+		decl     *TypeDecl
+		v        Value
+		strict   bool
+		expected Value
+		ok       bool
+	}{
+		{&TypeDecl{Types: IntType}, String("5"), false, Int(5), true},
+		{&TypeDecl{Types: IntType}, String("5"), true, String("5"), false},
+		{&TypeDecl{Types: IntType}, String("five"), false, String("five"), false},
+		{&TypeDecl{Types: FloatType}, Int(2), true, Float(2), true},
+		{&TypeDecl{Types: IntType | StringType}, Float(1.5), false, String("1.5"), true},
+		{&TypeDecl{Types: IntType | FloatType}, String("1.5"), false, Float(1.5), true},
+		{&TypeDecl{Types: StringType | BoolType}, Int(1), false, String("1"), true},
+		{&TypeDecl{Types: IntType}, Null{}, false, Null{}, false},
+		{&TypeDecl{Types: ArrayType, Flags: FalseDecl}, Bool(false), true, Bool(false), true},
+		{&TypeDecl{Types: ArrayType, Flags: FalseDecl}, Bool(true), true, Bool(true), false},
+		{&TypeDecl{Flags: IterableDecl}, NewArray(nil), true, NewArray(nil), true},
+	}
+
+	for _, c := range cases {
+		v, ok := c.decl.accept(ctx, c.v, c.strict)
+		assert.Equal(t, c.ok, ok, "%s accepts %#v", c.decl, c.v)
+		assert.Equal(t, c.expected, v, "%s accepts %#v", c.decl, c.v)
+	}
+}
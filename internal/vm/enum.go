@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+)
+
+var (
+	unitEnum   = NewInterface("UnitEnum", &Method{Name: "cases", Static: true, Abstract: true})
+	backedEnum = NewInterface("BackedEnum",
+		&Method{Name: "from", Static: true, Abstract: true},
+		&Method{Name: "tryFrom", Static: true, Abstract: true},
+	)
+)
+
+func init() {
+	backedEnum.Implement(unitEnum)
+	coreClasses = append(coreClasses, unitEnum, backedEnum)
+}
+
+// NewInterface creates interface with abstract methods
+func NewInterface(name String, methods ...*Method) *Class {
+	class := NewClass(name)
+	class.Interface = true
+
+	for _, method := range methods {
+		class.AddMethod(method)
+	}
+
+	return class
+}
+
+// NewEnumClass creates enumeration backed by values of type backing or pure enumeration if backing is 0
+func NewEnumClass(name String, backing Type) *Class {
+	class := NewClass(name)
+	class.Enum = true
+	class.Backing = backing
+	class.AddMethod(&Method{Name: "cases", Static: true, Fn: NewBuiltInFunction(class.cases, Arg{Name: "class"})})
+
+	if backing != 0 {
+		class.AddMethod(&Method{Name: "from", Static: true, Fn: NewBuiltInFunction(class.from, Arg{Name: "class"}, Arg{Name: "value"})})
+		class.AddMethod(&Method{Name: "tryFrom", Static: true, Fn: NewBuiltInFunction(class.tryFrom, Arg{Name: "class"}, Arg{Name: "value"})})
+	}
+
+	return class
+}
+
+// AddCase declares case of enumeration, which is also available as class constant
+func (c *Class) AddCase(ctx Context, name String, value Value) *Enum {
+	e := &Enum{Object: NewObject(ctx, c), Case: name, Value: value}
+	c.Cases = append(c.Cases, e)
+	c.AddConstant(&ClassConstant{Name: name, Value: e})
+
+	return e
+}
+
+func (c *Class) cases(ctx Context, _ ...Value) *Array {
+	arr := NewArray(nil)
+
+	for _, e := range c.Cases {
+		arr.OffsetSet(ctx, arr.NextKey(), e)
+	}
+
+	return arr
+}
+
+func (c *Class) from(ctx Context, args ...Value) Value {
+	value, ok := c.backingValue(ctx, "from", args[1])
+
+	if !ok {
+		return Null{}
+	}
+
+	if e := c.find(value); e != nil {
+		return e
+	}
+
+	if c.Backing == StringType {
+		ctx.Throw(NewValueError(fmt.Sprintf("%q is not a valid backing value for enum %s", string(value.(String)), string(c.Name))))
+	} else {
+		ctx.Throw(NewValueError(fmt.Sprintf("%d is not a valid backing value for enum %s", value, string(c.Name))))
+	}
+
+	return Null{}
+}
+
+func (c *Class) tryFrom(ctx Context, args ...Value) Value {
+	if value, ok := c.backingValue(ctx, "tryFrom", args[1]); ok {
+		if e := c.find(value); e != nil {
+			return e
+		}
+	}
+
+	return Null{}
+}
+
+// backingValue converts argument v of method from or tryFrom to backing type of enumeration in typing mode
+// of the caller. TypeError is thrown, if the type does not accept v. Null is coerced like it is by other
+// built-in functions
+func (c *Class) backingValue(ctx Context, method string, v Value) (Value, bool) {
+	strict := strictTypes(ctx)
+
+	if v = deref(v); v == nil || v == (Null{}) && !strict {
+		return Null{}.Cast(ctx, c.Backing), true
+	}
+
+	if accepted, ok := (&TypeDecl{Types: c.Backing}).accept(functionContext(ctx), v, strict); ok {
+		return accepted, true
+	}
+
+	ctx.Throw(NewTypeError(fmt.Sprintf("%s::%s(): Argument #1 ($value) must be of type %s, %s given", string(c.Name), method, typeName(c.Backing), DebugType(v))))
+	return nil, false
+}
+
+// find returns case of enumeration backed by value, nil if there is no such case
+func (c *Class) find(value Value) *Enum {
+	if i := slices.IndexFunc(c.Cases, func(e *Enum) bool { return e.Value == value }); i >= 0 {
+		return c.Cases[i]
+	}
+
+	return nil
+}
+
+func (e *Enum) fetch(ctx Context, name String) Value {
+	switch {
+	case name == "name":
+		return e.Case
+	case name == "value" && e.Value != nil:
+		return e.Value
+	default:
+		ctx.Throw(NewThrowable(fmt.Sprintf("Undefined property: %s::$%s", string(e.class.Name), string(name)), EWarning))
+		return Null{}
+	}
+}
+
+// readonly returns error of modification of property of enumeration case
+func (e *Enum) readonly(name String) Throwable {
+	if name == "name" || name == "value" && e.Value != nil {
+		return NewError(fmt.Sprintf("Cannot modify readonly property %s::$%s", string(e.class.Name), string(name)))
+	}
+
+	return NewError(fmt.Sprintf("Cannot create dynamic property %s::$%s", string(e.class.Name), string(name)))
+}
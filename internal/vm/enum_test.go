@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEnumClass(t *testing.T) {
+	class := NewEnumClass("Suit", StringType)
+	hearts := class.AddCase(nil, "Hearts", String("H"))
+	spades := class.AddCase(nil, "Spades", String("S"))
+	class.Implement(backedEnum)
+
+	assert.Empty(t, class.AbstractMethods())
+	assert.True(t, class.InstanceOf(unitEnum))
+	assert.Same(t, spades, class.Consts["Spades"].Value)
+
+	cases := class.cases(nil)
+	assert.Equal(t, Int(2), cases.Count(nil))
+	assert.Same(t, hearts, cases.OffsetGet(nil, Int(0)))
+
+	g := &GlobalContext{}
+	ctx := &FunctionContext{Context: g, global: g}
+	assert.Same(t, spades, class.tryFrom(ctx, String("Suit"), String("S")))
+	assert.Equal(t, Null{}, class.tryFrom(ctx, String("Suit"), String("X")))
+	assert.Nil(t, g.thrown)
+
+	priority := NewEnumClass("Priority", IntType)
+	low := priority.AddCase(nil, "Low", Int(0))
+	assert.Same(t, low, priority.tryFrom(ctx, String("Priority"), String("0")))
+	assert.Equal(t, Null{}, priority.tryFrom(ctx, String("Priority"), String("abc")))
+	assert.Equal(t, "Priority::tryFrom(): Argument #1 ($value) must be of type int, string given", g.thrown.Error())
+	assert.Equal(t, "enum(Suit::Hearts)", hearts.DebugInfo(nil))
+	assert.Equal(t, "Cannot modify readonly property Suit::$value", hearts.readonly("value").Error())
+
+	status := NewEnumClass("Status", 0)
+	active := status.AddCase(nil, "Active", nil)
+	_, ok := status.Method("from")
+	assert.False(t, ok)
+	assert.Equal(t, "Cannot create dynamic property Status::$value", active.readonly("value").Error())
+}
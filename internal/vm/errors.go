@@ -1,24 +1,25 @@
 package vm
 
+//go:generate stringer -type=ErrorLevel -linecomment
 type ErrorLevel int16
 
 const (
-	EError ErrorLevel = 1 << iota
-	EWarning
-	EParse
-	ENotice
-	ECoreError
-	ECoreWarning
-	ECompileError
-	ECompileWarning
-	EUserError
-	EUserWarning
-	EUserNotice
-	EStrict
-	ERecoverableError
-	EDeprecated
-	EUserDeprecated
-	EAll = 1<<15 - 1
+	EError            ErrorLevel = 1 << iota // Fatal error
+	EWarning                                 // Warning
+	EParse                                   // Parse error
+	ENotice                                  // Notice
+	ECoreError                               // Fatal error
+	ECoreWarning                             // Warning
+	ECompileError                            // Fatal error
+	ECompileWarning                          // Warning
+	EUserError                               // Fatal error
+	EUserWarning                             // Warning
+	EUserNotice                              // Notice
+	EStrict                                  // Strict Standards
+	ERecoverableError                        // Recoverable fatal error
+	EDeprecated                              // Deprecated
+	EUserDeprecated                          // Deprecated
+	EAll              = 1<<15 - 1
 )
 
 type Throwable interface {
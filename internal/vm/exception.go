@@ -0,0 +1,323 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+var (
+	throwableInterface = NewInterface("Throwable",
+		&Method{Name: "getMessage", Abstract: true},
+		&Method{Name: "getCode", Abstract: true},
+		&Method{Name: "getFile", Abstract: true},
+		&Method{Name: "getLine", Abstract: true},
+		&Method{Name: "getTrace", Abstract: true},
+		&Method{Name: "getPrevious", Abstract: true},
+		&Method{Name: "getTraceAsString", Abstract: true},
+	)
+	exceptionClass = newThrowableClass("Exception")
+
+	errorClass          = newThrowableClass("Error")
+	typeError           = newErrorClass("TypeError", errorClass)
+	argumentCountError  = newErrorClass("ArgumentCountError", typeError)
+	valueError          = newErrorClass("ValueError", errorClass)
+	arithmeticError     = newErrorClass("ArithmeticError", errorClass)
+	divisionByZeroError = newErrorClass("DivisionByZeroError", arithmeticError)
+	unhandledMatchError = newErrorClass("UnhandledMatchError", errorClass)
+
+	logicException           = newErrorClass("LogicException", exceptionClass)
+	badFunctionCallException = newErrorClass("BadFunctionCallException", logicException)
+	runtimeException         = newErrorClass("RuntimeException", exceptionClass)
+)
+
+func init() {
+	coreClasses = append(coreClasses, throwableInterface, exceptionClass,
+		errorClass, typeError, argumentCountError, valueError, arithmeticError, divisionByZeroError, unhandledMatchError)
+
+	// exceptions of Standard PHP Library
+	coreClasses = append(coreClasses, logicException, badFunctionCallException,
+		newErrorClass("BadMethodCallException", badFunctionCallException),
+		newErrorClass("DomainException", logicException),
+		newErrorClass("InvalidArgumentException", logicException),
+		newErrorClass("LengthException", logicException),
+		newErrorClass("OutOfRangeException", logicException),
+		runtimeException,
+		newErrorClass("OutOfBoundsException", runtimeException),
+		newErrorClass("OverflowException", runtimeException),
+		newErrorClass("RangeException", runtimeException),
+		newErrorClass("UnderflowException", runtimeException),
+		newErrorClass("UnexpectedValueException", runtimeException),
+	)
+}
+
+// NewError creates Error raised by the engine
+func NewError(message string) Throwable { return newError(errorClass, message) }
+
+// NewTypeError creates TypeError raised when a value does not match expected type
+func NewTypeError(message string) Throwable { return newError(typeError, message) }
+
+// NewArgumentCountError creates ArgumentCountError raised when too few arguments are passed to a function
+func NewArgumentCountError(message string) Throwable { return newError(argumentCountError, message) }
+
+// NewValueError creates ValueError raised when an argument has correct type, but unacceptable value
+func NewValueError(message string) Throwable { return newError(valueError, message) }
+
+// NewDivisionByZeroError creates DivisionByZeroError raised by division or modulo by zero
+func NewDivisionByZeroError(message string) Throwable { return newError(divisionByZeroError, message) }
+
+// newError creates an exception of built-in class. Object id and location are assigned, when the exception is thrown
+func newError(class *Class, message string) Throwable {
+	obj := class.NewInstance(nil)
+	obj.set("message", String(message))
+	return &exception{obj}
+}
+
+func newErrorClass(name String, parent *Class) *Class {
+	class := NewClass(name)
+	class.Extend(parent)
+	return class
+}
+
+// newThrowableClass creates base class of exceptions, which implements Throwable
+func newThrowableClass(name String) *Class {
+	class := NewClass(name)
+	class.AddProperty(&Property{Name: "message", Visibility: Protected, Default: String("")})
+	class.AddProperty(&Property{Name: "code", Visibility: Protected, Default: Int(0)})
+	class.AddProperty(&Property{Name: "file", Visibility: Protected, Default: String("")})
+	class.AddProperty(&Property{Name: "line", Visibility: Protected, Default: Int(0)})
+	class.AddProperty(&Property{Name: "trace", Visibility: Private, Default: NewArray(nil)})
+	class.AddProperty(&Property{Name: "previous", Visibility: Private, Default: Null{}})
+	class.AddMethod(&Method{Name: "__construct", Fn: NewBuiltInFunction(exceptionConstruct,
+		Arg{Name: "this"},
+		Arg{Name: "message", Type: StringType, Default: String("")},
+		Arg{Name: "code", Type: IntType, Default: Int(0)},
+		Arg{Name: "previous", Default: Null{}},
+	)})
+	class.AddMethod(&Method{Name: "getMessage", Fn: exceptionGetter("message")})
+	class.AddMethod(&Method{Name: "getCode", Fn: exceptionGetter("code")})
+	class.AddMethod(&Method{Name: "getFile", Fn: exceptionGetter("file")})
+	class.AddMethod(&Method{Name: "getLine", Fn: exceptionGetter("line")})
+	class.AddMethod(&Method{Name: "getTrace", Fn: exceptionGetter("trace")})
+	class.AddMethod(&Method{Name: "getPrevious", Fn: exceptionGetter("previous")})
+	class.AddMethod(&Method{Name: "getTraceAsString", Fn: NewBuiltInFunction(exceptionTraceAsString, Arg{Name: "this"})})
+	class.Implement(throwableInterface)
+
+	return class
+}
+
+func exceptionConstruct(_ Context, args ...Value) Null {
+	obj, _ := object(args[0])
+	obj.set("message", args[1])
+	obj.set("code", args[2])
+	obj.set("previous", deref(args[3]))
+
+	return Null{}
+}
+
+func exceptionGetter(name String) Callable {
+	return NewBuiltInFunction(func(_ Context, args ...Value) Value {
+		obj, _ := object(args[0])
+
+		if ref, ok := obj.get(name); ok {
+			return *ref.Deref()
+		}
+
+		return Null{}
+	}, Arg{Name: "this"})
+}
+
+// exceptionTraceAsString formats stack trace of exception like PHP does, arguments of calls are omitted
+func exceptionTraceAsString(ctx Context, args ...Value) String {
+	obj, _ := object(args[0])
+	trace, _ := obj.get("trace")
+	frames, _ := deref(*trace.Deref()).(*Array)
+	var b strings.Builder
+
+	if frames != nil {
+		for i, key := range frames.Keys(ctx) {
+			frame, _ := deref(frames.OffsetGet(ctx, key)).(*Array)
+
+			if frame == nil {
+				continue
+			}
+
+			if file, ok := frame.OffsetGet(ctx, String("file")).(String); ok {
+				fmt.Fprintf(&b, "#%d %s(%d): ", i, string(file), frame.OffsetGet(ctx, String("line")).AsInt(ctx))
+			} else {
+				fmt.Fprintf(&b, "#%d [internal function]: ", i)
+			}
+
+			if class, ok := frame.OffsetGet(ctx, String("class")).(String); ok {
+				b.WriteString(string(class) + string(frame.OffsetGet(ctx, String("type")).AsString(ctx)))
+			}
+
+			fmt.Fprintf(&b, "%s()\n", string(frame.OffsetGet(ctx, String("function")).AsString(ctx)))
+		}
+
+		fmt.Fprintf(&b, "#%d {main}", frames.Count(ctx))
+	}
+
+	return String(b.String())
+}
+
+// locate sets file and line of current instruction as location of exception obj and records stack trace of calls,
+// which lead to it. Calls are listed from the innermost one, main script is not listed
+func (g *GlobalContext) locate(obj *Object) {
+	if g.thread == nil || uintptr(unsafe.Pointer(g.frame)) < uintptr(unsafe.Pointer(&g.frames[0])) {
+		return
+	}
+
+	obj.set("file", g.File)
+	obj.set("line", Int(g.frame.line()))
+	trace := NewArray(nil)
+
+	for f := g.frame; ; {
+		var caller *Frame
+
+		if f != &g.frames[0] {
+			caller = (*Frame)(unsafe.Add(unsafe.Pointer(f), -frameSize))
+		}
+
+		if f.ctx.function != "" {
+			call := NewArray(nil)
+
+			if caller != nil {
+				call.OffsetSet(g, String("file"), g.File)
+				call.OffsetSet(g, String("line"), Int(caller.line()))
+			}
+
+			class, function, method := strings.Cut(functionName(f.ctx.function), "::")
+
+			if !method {
+				function = class
+			}
+
+			call.OffsetSet(g, String("function"), String(function))
+
+			if method {
+				call.OffsetSet(g, String("class"), String(class))
+
+				if f.ctx.this != nil {
+					call.OffsetSet(g, String("type"), String("->"))
+				} else {
+					call.OffsetSet(g, String("type"), String("::"))
+				}
+			}
+
+			trace.OffsetSet(g, trace.NextKey(), call)
+		}
+
+		if caller == nil {
+			break
+		}
+
+		f = caller
+	}
+
+	obj.set("trace", trace)
+}
+
+// line returns line of source, which current instruction of the frame is compiled from, 0 if it is unknown
+func (f *Frame) line() int {
+	i, _ := slices.BinarySearchFunc(f.lines, f.ctx.pc+1, func(l Line, pc int) int { return l.Pc - pc })
+
+	if i == 0 {
+		return 0
+	}
+
+	return f.lines[i-1].Line
+}
+
+// exception is an object implementing Throwable, which is raised by throw statement
+type exception struct {
+	obj *Object
+}
+
+func (e *exception) Level() ErrorLevel { return EError }
+func (e *exception) Error() string {
+	if ref, ok := e.obj.get("message"); ok {
+		return string(deref(*ref.Deref()).AsString(nil))
+	}
+
+	return ""
+}
+
+// uncaught describes exception, which is not caught by any handler
+func (e *exception) uncaught() Throwable {
+	if message := e.Error(); message != "" {
+		return NewThrowable(fmt.Sprintf("Uncaught %s: %s", string(e.obj.class.Name), message), EError)
+	}
+
+	return NewThrowable(fmt.Sprintf("Uncaught %s", string(e.obj.class.Name)), EError)
+}
+
+// Handler is an entry of exception table of a function. Positions are indices of bytecode words
+type Handler struct {
+	Start   int // first instruction of try block
+	Catch   int // dispatch of catch blocks, which is also end of try block, 0 if there are no catch blocks
+	Finally int // first instruction of finally block, 0 if there is no finally block
+	Depth   int // number of values on operand stack at the start of try block
+}
+
+// pendingThrow is an exception, which is rethrown after finally block
+type pendingThrow struct{ *Object }
+
+// pendingReturn is a value returned from try block, which is returned after finally block
+type pendingReturn struct{ Value }
+
+// pendingJump is a break or continue out of try block, which jumps to pc after finally block.
+// Depth is the number of values on operand stack kept by the jump. Embedded Null only makes it a stack value
+type pendingJump struct {
+	Null
+	pc, depth int
+}
+
+// catch transfers control to the innermost handler of frame, which catches exception thrown at current position
+func (f *Frame) catch(e *exception) bool {
+	for _, h := range f.handlers {
+		switch {
+		case h.Catch > 0 && f.ctx.pc >= h.Start && f.ctx.pc < h.Catch:
+			f.enter(h, h.Catch, e.obj)
+			return true
+		case h.Finally > 0 && f.ctx.pc >= h.Start && f.ctx.pc < h.Finally:
+			f.enter(h, h.Finally, pendingThrow{e.obj})
+			return true
+		}
+	}
+
+	return false
+}
+
+// finally transfers control to the innermost finally block of frame, which encloses current position
+func (f *Frame) finally(v Value) bool {
+	for _, h := range f.handlers {
+		if h.Finally > 0 && f.ctx.pc >= h.Start && f.ctx.pc < h.Finally {
+			f.enter(h, h.Finally, v)
+			return true
+		}
+	}
+
+	return false
+}
+
+// jump transfers control to the innermost finally block of frame, which encloses current position, but not
+// target of the jump. Without such block the jump is made
+func (f *Frame) jump(j pendingJump) {
+	for _, h := range f.handlers {
+		if h.Finally > 0 && f.ctx.pc >= h.Start && f.ctx.pc < h.Finally && (j.pc < h.Start || j.pc >= h.Finally) {
+			f.enter(h, h.Finally, j)
+			return
+		}
+	}
+
+	f.ctx.global.Sp(f.base + j.depth)
+	f.ctx.pc = j.pc - 1
+}
+
+func (f *Frame) enter(h Handler, pc int, v Value) {
+	f.ctx.global.Sp(f.base + h.Depth)
+	f.ctx.global.Push(v)
+	f.ctx.pc = pc - 1
+}
@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGlobalContext_Unwind(t *testing.T) {
+	bytecode := func(ops ...uint64) (b Bytecode) {
+		for _, op := range ops {
+			b = binary.NativeEndian.AppendUint64(b, op)
+		}
+
+		return
+	}
+
+	e := exceptionClass.NewInstance(nil)
+	e.set("message", String("boom"))
+	thrower := CompiledFunction{Instructions: bytecode(uint64(OpConst), 0, uint64(OpThrow))}
+	main := CompiledFunction{
+		Instructions: bytecode(
+			uint64(OpConst), 2,
+			uint64(OpConst), 1,
+			uint64(OpCall), 0,
+			uint64(OpJump), 9,
+			uint64(OpPop),
+			uint64(OpReturnValue),
+		),
+		Handlers: []Handler{{Start: 2, Catch: 8, Depth: 1}},
+	}
+
+	ctx := GlobalContext{Functions: []Callable{thrower}, Constants: []Value{e, Int(1), Int(2)}}
+	assert.Nil(t, ctx.Run(main))
+	assert.Equal(t, Int(2), ctx.Pop())
+
+	main.Handlers = nil
+	err := ctx.Run(main)
+	assert.Equal(t, "Uncaught Exception: boom", err.Error())
+	assert.Equal(t, EError, err.Level())
+}
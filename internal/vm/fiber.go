@@ -0,0 +1,287 @@
+package vm
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+)
+
+var (
+	fiberClass      = NewClass("Fiber")
+	fiberErrorClass = newErrorClass("FiberError", errorClass)
+)
+
+func init() {
+	fiberClass.AddMethod(&Method{Name: "__construct", Fn: NewBuiltInFunction(fiberConstruct, Arg{Name: "this"}, Arg{Name: "callback"})})
+	fiberClass.AddMethod(&Method{Name: "start", Fn: fiberMethod((*Fiber).start)})
+	fiberClass.AddMethod(&Method{Name: "resume", Fn: NewBuiltInFunction(func(ctx Context, args ...Value) Value {
+		return args[0].(*Fiber).resume(ctx, deref(args[1]))
+	}, Arg{Name: "this"}, Arg{Name: "value", Default: Null{}})})
+	fiberClass.AddMethod(&Method{Name: "throw", Fn: NewBuiltInFunction(func(ctx Context, args ...Value) Value {
+		return args[0].(*Fiber).throw(ctx, deref(args[1]))
+	}, Arg{Name: "this"}, Arg{Name: "exception"})})
+	fiberClass.AddMethod(&Method{Name: "getReturn", Fn: NewBuiltInFunction(func(ctx Context, args ...Value) Value {
+		return args[0].(*Fiber).getReturn(ctx)
+	}, Arg{Name: "this"})})
+	fiberClass.AddMethod(&Method{Name: "isStarted", Fn: fiberPredicate(func(f *Fiber) bool { return f.state != fiberInit })})
+	fiberClass.AddMethod(&Method{Name: "isSuspended", Fn: fiberPredicate(func(f *Fiber) bool { return f.state == fiberSuspended })})
+	fiberClass.AddMethod(&Method{Name: "isRunning", Fn: fiberPredicate(func(f *Fiber) bool { return f.state == fiberRunning })})
+	fiberClass.AddMethod(&Method{Name: "isTerminated", Fn: fiberPredicate(func(f *Fiber) bool { return f.state == fiberTerminated })})
+	fiberClass.AddMethod(&Method{Name: "suspend", Static: true, Fn: NewBuiltInFunction(fiberSuspend, Arg{Name: "class"}, Arg{Name: "value", Default: Null{}})})
+	fiberClass.AddMethod(&Method{Name: "getCurrent", Static: true, Fn: NewBuiltInFunction(func(ctx Context, _ ...Value) Value {
+		if f := ctx.Global().fiber; f != nil {
+			return f
+		}
+
+		return Null{}
+	}, Arg{Name: "class"})})
+	coreClasses = append(coreClasses, fiberClass, fiberErrorClass)
+}
+
+// fiberMethod is a method of Fiber, which works with arguments on the stack of the caller directly
+type fiberMethod func(f *Fiber, ctx *FunctionContext, argc int)
+
+func (fiberMethod) Invoke(Context) { panic("fiber method must be called by callMethod") }
+func (fn fiberMethod) call(this Value, ctx *FunctionContext, argc int) {
+	fn(this.(*Fiber), ctx, argc)
+}
+
+// fiberPredicate adapts predicate on state of fiber to built-in method
+func fiberPredicate(fn func(*Fiber) bool) Callable {
+	return NewBuiltInFunction(func(_ Context, args ...Value) Bool { return Bool(fn(args[0].(*Fiber))) }, Arg{Name: "this"})
+}
+
+type fiberState int
+
+const (
+	fiberInit fiberState = iota
+	fiberSuspended
+	fiberRunning
+	fiberTerminated
+)
+
+// fiberTransfer is a value or an exception passed between fiber and its resumer, when control is switched
+type fiberTransfer struct {
+	value  Value
+	thrown Throwable
+	done   bool // fiber has terminated, value is its return value
+}
+
+// Fiber is a function with its own value and frame stacks, which can be suspended from any depth of calls.
+// Every fiber runs on a goroutine of its own, but only one of them executes at a time: control is handed over
+// synchronously through channels
+type Fiber struct {
+	*Object
+
+	routine  *fiberRoutine // goroutine of started fiber, nil when the fiber has terminated
+	callback *Closure
+	state    fiberState
+	returned Value
+	failed   bool // fiber terminated with an exception
+}
+
+// fiberRoutine is the goroutine, which runs a fiber. The goroutine does not reference the Fiber, so that a suspended
+// fiber, which the script no longer references, can be collected. Its goroutine is then stopped by release.
+// The stacks of a suspended fiber are roots of the collector, so a fiber referenced from them, e.g. by a closure
+// capturing the fiber, is never collected. Its goroutine is stopped, when the context of the script ends
+type fiberRoutine struct {
+	*thread
+
+	ctx                FunctionContext // root context, which the callback is called from
+	resumed, suspended chan fiberTransfer
+}
+
+func (f *Fiber) DebugInfo(Context) string {
+	return fmt.Sprintf("object(Fiber)#%d (0) {\n}", f.id)
+}
+
+// transfer switches execution to the fiber and waits until it suspends or terminates.
+// Returns value passed to Fiber::suspend() or null, if the fiber has terminated
+func (f *Fiber) transfer(ctx Context, t fiberTransfer) Value {
+	g, r := ctx.Global(), f.routine
+	thread, previous := g.thread, g.fiber
+	g.thread, g.fiber = r.thread, f
+	f.state = fiberRunning
+
+	r.resumed <- t
+	t = <-r.suspended
+
+	g.thread, g.fiber = thread, previous
+	f.state = fiberSuspended
+
+	if t.done {
+		f.state, f.routine, f.callback = fiberTerminated, nil, nil
+
+		if f.failed = t.thrown != nil; !f.failed {
+			f.returned, t.value = t.value, nil
+		}
+	}
+
+	if t.thrown != nil {
+		g.Throw(t.thrown)
+		return Null{}
+	}
+
+	if t.value == nil {
+		return Null{}
+	}
+
+	return t.value
+}
+
+// run calls callback on the stack of the fiber. Exception, which is not caught in the fiber, is rethrown to the resumer
+func (r *fiberRoutine) run(callback *Closure, args []Value) {
+	r.wait()
+
+	g := r.ctx.global
+	g.Push(callback)
+
+	for _, arg := range args {
+		g.Push(arg)
+	}
+
+	callback.invoke(&r.ctx, len(args))
+
+	if err := g.execute(&r.frames[0]); err != nil && g.thrown == nil {
+		g.thrown = err
+	}
+
+	t := fiberTransfer{done: true}
+
+	if t.thrown, g.thrown = g.thrown, nil; t.thrown == nil {
+		t.value = deref(g.Pop())
+	}
+
+	r.suspended <- t
+}
+
+// wait blocks the fiber until it is resumed. The goroutine exits instead, when the fiber is released
+// or the script has ended
+func (r *fiberRoutine) wait() fiberTransfer {
+	var done <-chan struct{}
+
+	if r.ctx.global.Context != nil {
+		done = r.ctx.global.Done()
+	}
+
+	select {
+	case t, ok := <-r.resumed:
+		if ok {
+			return t
+		}
+	case <-done:
+	}
+
+	runtime.Goexit()
+	return fiberTransfer{}
+}
+
+// release stops the goroutine of fiber, which is collected while suspended
+func (f *Fiber) release() {
+	if f.state == fiberSuspended {
+		close(f.routine.resumed)
+	}
+}
+
+// fiberConstruct => new Fiber($callback)
+func fiberConstruct(ctx Context, args ...Value) Value {
+	f := args[0].(*Fiber)
+	fn := ctx.(*FunctionContext)
+
+	if f.callback = makeCallableClosure(fn, deref(args[1])); f.callback == nil && fn.global.thrown == nil {
+		ctx.Throw(NewTypeError("Fiber::__construct(): Argument #1 ($callback) must be a valid callback"))
+	}
+
+	return Null{}
+}
+
+// start => $fiber->start(...$args)
+func (f *Fiber) start(ctx *FunctionContext, argc int) {
+	if f.state != fiberInit || f.callback == nil {
+		ctx.Throw(newError(fiberErrorClass, "Cannot start a fiber that has already been started"))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	args := slices.Clone(ctx.global.Slice(-argc, 0))
+	ctx.global.MovePointer(-argc)
+
+	r := &fiberRoutine{thread: new(thread), ctx: FunctionContext{Context: ctx.global, global: ctx.global}}
+	r.init()
+	r.resumed, r.suspended = make(chan fiberTransfer), make(chan fiberTransfer)
+	f.routine = r
+	runtime.SetFinalizer(f, (*Fiber).release)
+
+	go r.run(f.callback, args)
+
+	v := f.transfer(ctx, fiberTransfer{})
+	*ctx.global.sp = v
+}
+
+// resume => $fiber->resume($value)
+func (f *Fiber) resume(ctx Context, v Value) Value {
+	if f.state != fiberSuspended {
+		ctx.Throw(newError(fiberErrorClass, "Cannot resume a fiber that is not suspended"))
+		return Null{}
+	}
+
+	return f.transfer(ctx, fiberTransfer{value: v})
+}
+
+// throw => $fiber->throw($exception)
+func (f *Fiber) throw(ctx Context, v Value) Value {
+	obj, ok := object(v)
+
+	if !ok || !obj.class.InstanceOf(throwableInterface) {
+		ctx.Throw(NewTypeError(fmt.Sprintf("Fiber::throw(): Argument #1 ($exception) must be of type Throwable, %s given", DebugType(v))))
+		return Null{}
+	}
+
+	if f.state != fiberSuspended {
+		ctx.Throw(newError(fiberErrorClass, "Cannot resume a fiber that is not suspended"))
+		return Null{}
+	}
+
+	return f.transfer(ctx, fiberTransfer{thrown: &exception{obj}})
+}
+
+// getReturn => $fiber->getReturn()
+func (f *Fiber) getReturn(ctx Context) Value {
+	var reason string
+
+	switch {
+	case f.state == fiberTerminated && f.failed:
+		reason = "The fiber threw an exception"
+	case f.state == fiberTerminated:
+		return f.returned
+	case f.state == fiberInit:
+		reason = "The fiber has not been started"
+	default:
+		reason = "The fiber has not returned"
+	}
+
+	ctx.Throw(newError(fiberErrorClass, "Cannot get fiber return value: "+reason))
+	return Null{}
+}
+
+// fiberSuspend => Fiber::suspend($value)
+func fiberSuspend(ctx Context, args ...Value) Value {
+	f := ctx.Global().fiber
+
+	if f == nil {
+		ctx.Throw(newError(fiberErrorClass, "Cannot suspend outside of fiber"))
+		return Null{}
+	}
+
+	r := f.routine
+	r.suspended <- fiberTransfer{value: deref(args[1])}
+	t := r.wait()
+
+	if t.thrown != nil {
+		ctx.Throw(t.thrown)
+		return Null{}
+	}
+
+	return t.value
+}
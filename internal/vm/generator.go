@@ -0,0 +1,324 @@
+package vm
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+var (
+	traversableInterface = NewInterface("Traversable")
+	generatorClass       = NewClass("Generator")
+)
+
+func init() {
+	generatorClass.AddMethod(&Method{Name: "current", Fn: generatorMethod(func(gen *Generator, ctx Context, _ Value) Value { return gen.Current(ctx) })})
+	generatorClass.AddMethod(&Method{Name: "key", Fn: generatorMethod(func(gen *Generator, ctx Context, _ Value) Value { return gen.Key(ctx) })})
+	generatorClass.AddMethod(&Method{Name: "next", Fn: generatorMethod(func(gen *Generator, ctx Context, _ Value) Value { gen.Next(ctx); return Null{} })})
+	generatorClass.AddMethod(&Method{Name: "valid", Fn: generatorMethod(func(gen *Generator, ctx Context, _ Value) Value { return gen.Valid(ctx) })})
+	generatorClass.AddMethod(&Method{Name: "rewind", Fn: generatorMethod(func(gen *Generator, ctx Context, _ Value) Value { gen.Rewind(ctx); return Null{} })})
+	generatorClass.AddMethod(&Method{Name: "send", Fn: generatorMethod((*Generator).send, Arg{Name: "value"})})
+	generatorClass.AddMethod(&Method{Name: "throw", Fn: generatorMethod((*Generator).throw, Arg{Name: "exception"})})
+	generatorClass.AddMethod(&Method{Name: "getReturn", Fn: generatorMethod((*Generator).getReturn)})
+	generatorClass.Implement(traversableInterface)
+	coreClasses = append(coreClasses, traversableInterface, generatorClass)
+}
+
+// generatorMethod adapts fn to built-in method of Generator, which accepts up to one argument
+func generatorMethod(fn func(*Generator, Context, Value) Value, args ...Arg) Callable {
+	return NewBuiltInFunction(func(ctx Context, args ...Value) Value {
+		var arg Value = Null{}
+
+		if len(args) > 1 {
+			arg = deref(args[1])
+		}
+
+		return fn(args[0].(*Generator), ctx, arg)
+	}, append([]Arg{{Name: "this"}}, args...)...)
+}
+
+type generatorState int
+
+const (
+	generatorCreated generatorState = iota
+	generatorSuspended
+	generatorRunning
+	generatorFinished
+)
+
+// Generator is a function suspended at yield expression. Variables and operands of the function are kept
+// aside of the stack until the generator is resumed
+type Generator struct {
+	*Object
+
+	frame      Frame   // suspended frame with base relative to its frame pointer
+	stack      []Value // variables and operands of suspended frame
+	vars, args int
+	offset     int // position of variables relative to frame pointer
+
+	key, current Value
+	returned     Value    // value returned by the function, nil until the function returns
+	delegate     Iterator // iterator of yield from expression, which values are yielded
+	nextKey      Int      // key of next value yielded without key
+	state        generatorState
+	advanced     bool // generator is resumed after first yield
+}
+
+func (gen *Generator) DebugInfo(Context) string {
+	return fmt.Sprintf("object(Generator)#%d (0) {\n}", gen.id)
+}
+
+// suspend saves frame of generator and returns control to the caller
+func (gen *Generator) suspend(frame *Frame) {
+	g := frame.ctx.global
+	gen.stack = append(gen.stack[:0], g.stack[frame.fp+1:g.TopIndex()+1]...)
+	gen.vars, gen.args = len(frame.ctx.vars), len(frame.ctx.args)
+	gen.offset = int(uintptr(unsafe.Pointer(unsafe.SliceData(frame.ctx.vars)))-uintptr(unsafe.Pointer(&g.stack[0])))/int(unsafe.Sizeof(Value(nil))) - frame.fp
+	gen.frame = *frame
+	gen.frame.base -= frame.fp
+	gen.state = generatorSuspended
+
+	g.PopFrame()
+	g.Sp(frame.fp)
+}
+
+// resume restores frame of generator on top of the stack and executes it until next yield or return.
+// Sent value becomes result of yield expression, thrown exception is raised at its place instead
+func (gen *Generator) resume(ctx Context, sent Value, thrown Throwable) {
+	g := ctx.Global()
+
+	if g.overflow(len(gen.stack) + 1) {
+		return
+	}
+
+	switch gen.state {
+	case generatorRunning:
+		g.Throw(NewError("Cannot resume an already running generator"))
+		return
+	case generatorFinished:
+		return
+	case generatorSuspended:
+		gen.advanced = true
+	}
+
+	fp := g.TopIndex()
+	frame := g.NextFrame()
+	*frame = gen.frame
+	frame.ctx.Context = ctx
+	frame.fp, frame.base = fp, fp+gen.frame.base
+	frame.ctx.vars = g.stack[fp+gen.offset : fp+gen.offset+gen.vars]
+	frame.ctx.args = frame.ctx.vars[:gen.args]
+	g.MovePointer(len(gen.stack))
+	copy(g.stack[fp+1:], gen.stack)
+
+	if gen.state == generatorSuspended && thrown == nil {
+		g.Push(sent)
+	}
+
+	gen.state = generatorRunning
+
+	if thrown != nil {
+		g.Throw(thrown)
+	}
+
+	g.execute(frame)
+}
+
+// advance resumes generator or the generator, which it delegates to by yield from
+func (gen *Generator) advance(ctx Context, sent Value, thrown Throwable) {
+	g := ctx.Global()
+
+	if it := gen.delegate; it != nil {
+		if inner, ok := it.(*Generator); ok {
+			inner.advance(ctx, sent, thrown)
+			thrown = nil
+		} else if thrown == nil {
+			it.Next(ctx)
+		}
+
+		if g.thrown != nil {
+			thrown, g.thrown = g.thrown, nil
+		}
+
+		if thrown != nil {
+			gen.delegate = nil
+		} else if it.Valid(ctx) {
+			gen.current, gen.key = it.Current(ctx), it.Key(ctx)
+			return
+		}
+
+		sent = Null{}
+	}
+
+	gen.resume(ctx, sent, thrown)
+}
+
+// start executes generator until the first yield
+func (gen *Generator) start(ctx Context) {
+	if gen.state == generatorCreated {
+		gen.resume(ctx, nil, nil)
+	}
+}
+
+// finish completes generator, which has returned or thrown an exception
+func (gen *Generator) finish() {
+	gen.state = generatorFinished
+	gen.current, gen.key, gen.delegate = nil, nil, nil
+	gen.stack = nil
+}
+
+func (gen *Generator) Current(ctx Context) Value {
+	gen.start(ctx)
+
+	if gen.state == generatorFinished {
+		return Null{}
+	}
+
+	return gen.current
+}
+
+func (gen *Generator) Key(ctx Context) Value {
+	gen.start(ctx)
+
+	if gen.state == generatorFinished {
+		return Null{}
+	}
+
+	return gen.key
+}
+
+func (gen *Generator) Next(ctx Context) {
+	gen.start(ctx)
+	gen.advance(ctx, Null{}, nil)
+}
+
+func (gen *Generator) Rewind(ctx Context) {
+	gen.start(ctx)
+
+	if gen.advanced {
+		ctx.Throw(newError(exceptionClass, "Cannot rewind a generator that was already run"))
+	}
+}
+
+func (gen *Generator) Valid(ctx Context) Bool {
+	gen.start(ctx)
+	return gen.state != generatorFinished
+}
+
+// send => $generator->send($value)
+func (gen *Generator) send(ctx Context, v Value) Value {
+	gen.start(ctx)
+	gen.advance(ctx, v, nil)
+	return gen.Current(ctx)
+}
+
+// throw => $generator->throw($exception)
+func (gen *Generator) throw(ctx Context, v Value) Value {
+	obj, ok := object(v)
+
+	if !ok || !obj.class.InstanceOf(throwableInterface) {
+		ctx.Throw(NewTypeError(fmt.Sprintf("Generator::throw(): Argument #1 ($exception) must be of type Throwable, %s given", DebugType(v))))
+		return Null{}
+	}
+
+	gen.start(ctx)
+
+	if gen.state == generatorFinished {
+		ctx.Throw(&exception{obj})
+		return Null{}
+	}
+
+	gen.advance(ctx, nil, &exception{obj})
+	return gen.Current(ctx)
+}
+
+// getReturn => $generator->getReturn()
+func (gen *Generator) getReturn(ctx Context, _ Value) Value {
+	if gen.state != generatorFinished || gen.returned == nil {
+		ctx.Throw(newError(exceptionClass, "Cannot get return value of a generator that hasn't returned"))
+		return Null{}
+	}
+
+	return gen.returned
+}
+
+// Generate suspends generator function right after it is called and returns Generator object
+func Generate(ctx *FunctionContext) {
+	frame := ctx.global.frame
+	gen := &Generator{Object: NewObject(ctx, generatorClass)}
+	frame.generator = gen
+	gen.suspend(frame)
+	gen.state = generatorCreated
+	ctx.global.Push(gen)
+}
+
+// Yield => yield $value; yield $key => $value
+func Yield(ctx *FunctionContext) {
+	frame := ctx.global.frame
+	gen := frame.generator
+	gen.current = deref(ctx.global.Pop())
+
+	if ctx.global.r1 > 1 {
+		gen.key = deref(ctx.global.Pop())
+
+		if key, ok := gen.key.(Int); ok && key >= gen.nextKey {
+			gen.nextKey = key + 1
+		}
+	} else {
+		gen.key = gen.nextKey
+		gen.nextKey++
+	}
+
+	gen.suspend(frame)
+}
+
+// YieldFrom => yield from $iterable
+//
+// Instruction is executed again, when generator is resumed after delegate has no more values.
+// Then it replaces sent value with return value of delegated generator
+func YieldFrom(ctx *FunctionContext) {
+	frame := ctx.global.frame
+	gen := frame.generator
+
+	if gen.delegate != nil {
+		var result Value = Null{}
+
+		if inner, ok := gen.delegate.(*Generator); ok && inner.returned != nil {
+			result = inner.returned
+		}
+
+		gen.delegate = nil
+		*ctx.global.sp = result
+		return
+	}
+
+	var it Iterator
+
+	switch v := deref(ctx.global.Pop()).(type) {
+	case Iterator:
+		it = v
+	case IteratorAggregate:
+		it = v.GetIterator(ctx)
+	default:
+		ctx.Throw(NewError("Can use \"yield from\" only with arrays and Traversables"))
+		ctx.global.Push(Null{})
+		return
+	}
+
+	it.Rewind(ctx)
+
+	if ctx.global.thrown != nil || !it.Valid(ctx) {
+		var result Value = Null{}
+
+		if inner, ok := it.(*Generator); ok && inner.returned != nil {
+			result = inner.returned
+		}
+
+		ctx.global.Push(result)
+		return
+	}
+
+	gen.delegate = it
+	gen.current, gen.key = it.Current(ctx), it.Key(ctx)
+	ctx.pc--
+	gen.suspend(frame)
+}
@@ -94,15 +94,41 @@ const (
 	OpArrayAccessPush                  // ARRAY_ACCESS_PUSH
 	OpArrayUnset                       // ARRAY_UNSET
 	OpConcat                           // CONCAT
-	OpUnset                            // UNSET
 	OpForEachInit                      // FE_INIT
 	OpForEachNext                      // FE_NEXT
 	OpForEachValid                     // FE_VALID
 	OpThrow                            // THROW
 	OpCallByName                       // CALL_BY_NAME
+	OpDup                              // DUP
+	OpThis                             // THIS
+	OpPropertyFetch                    // PROP_FETCH
+	OpPropertyWrite                    // PROP_WRITE
+	OpPropertyAssign                   // PROP_ASSIGN
+	OpStaticPropertyFetch              // STATIC_PROP_FETCH
+	OpStaticPropertyWrite              // STATIC_PROP_WRITE
+	OpStaticPropertyAssign             // STATIC_PROP_ASSIGN
+	OpClassConstFetch                  // CLASS_CONST
+	OpInstanceOf                       // INSTANCE_OF
+	OpEndFinally                       // END_FINALLY
+	OpCallable                         // CALLABLE
+	OpMethodCallable                   // METHOD_CALLABLE
+	OpGenerator                        // GENERATOR
+	OpYieldFrom                        // YIELD_FROM
+	OpArrayAccessQuiet                 // ARRAY_ACCESS_QUIET
+	OpPropertyFetchQuiet               // PROP_FETCH_QUIET
+	OpMatchError                       // MATCH_ERROR
+	OpPropertyUnset                    // PROP_UNSET
+	OpArraySpread                      // ARRAY_SPREAD
+	OpPackArgs                         // PACK_ARGS
+	OpClone                            // CLONE
+	OpPropertyBind                     // PROP_BIND
+	OpStaticPropertyBind               // STATIC_PROP_BIND
+	OpSkipArg                          // SKIP_ARG
+	OpPropertyRef                      // PROP_REF
+	OpStaticPropertyRef                // STATIC_PROP_REF
+	OpPropertyIsSet                    // PROP_ISSET
 
 	_opOneOperand      Operator = iota - 1
-	OpAssertType                // ASSERT_TYPE
 	OpAssign                    // ASSIGN
 	OpAssignAdd                 // ASSIGN_ADD
 	OpAssignSub                 // ASSIGN_SUB
@@ -128,15 +154,32 @@ const (
 	OpJumpTrue                  // JUMP_TRUE
 	OpJumpFalse                 // JUMP_FALSE
 	OpCall                      // CALL
+	OpCallMethod                // CALL_METHOD
+	OpCallStatic                // CALL_STATIC
+	OpClosure                   // CLOSURE
+	OpCallDynamic               // CALL_DYNAMIC
+	OpYield                     // YIELD
+	OpJumpTable                 // JUMP_TABLE
+	OpGlobalRef                 // GLOBAL_REF
+	OpStaticRef                 // STATIC_REF
+	OpBind                      // BIND
+	OpUnset                     // UNSET
+	OpLoadQuiet                 // LOAD_QUIET
+	OpConcatN                   // CONCAT_N
+	OpNew                       // NEW
 	OpEcho                      // ECHO
 	OpIsSet                     // ISSET
 	OpForEachKey                // FE_KEY
 	OpForEachValue              // FE_VALUE
 	OpForEachValueRef           // FE_VALUE_REF
+	OpArgPassed                 // ARG_PASSED
+	OpAssertParam               // ASSERT_PARAM
+	OpArrayBind                 // ARRAY_BIND
+	OpJumpOut                   // JUMP_OUT
 )
 
 func assignTryRef(ref *Value, v Value) {
-	if (*ref).IsRef() {
+	if *ref != nil && (*ref).IsRef() {
 		*(*ref).(Ref).Deref() = v
 	} else {
 		*ref = v
@@ -172,6 +215,8 @@ func compare(ctx Context, x, y Value) Int {
 		return -1
 	}
 
+	x, y = numeric(x, y)
+
 	switch Juggle(x.Type(), y.Type()) {
 	case IntType:
 		return intSign(x.AsInt(ctx) - y.AsInt(ctx))
@@ -223,7 +268,14 @@ func NotEqual(ctx *FunctionContext) {
 	*ctx.global.sp = !equal(ctx, left, right)
 }
 
-func equal(ctx *FunctionContext, x, y Value) Bool {
+// LooseEqual reports if x == y, e.g. to evaluate constant expression
+func LooseEqual(ctx Context, x, y Value) Bool { return equal(ctx, x, y) }
+
+// Spaceship returns x <=> y, e.g. to evaluate constant expression
+func Spaceship(ctx Context, x, y Value) Int { return compare(ctx, x, y) }
+
+func equal(ctx Context, x, y Value) Bool {
+	x, y = numeric(x, y)
 	as := Juggle(x.Type(), y.Type())
 
 	if as == ArrayType {
@@ -235,6 +287,38 @@ func equal(ctx *FunctionContext, x, y Value) Bool {
 	return x.Cast(ctx, as) == y.Cast(ctx, as)
 }
 
+// numeric converts numeric strings compared with numbers or numeric strings to numbers, as such operands are
+// compared numerically => "1e1" == "10". Other operands are returned as is
+func numeric(x, y Value) (Value, Value) {
+	if x.Type() != StringType && y.Type() != StringType {
+		return x, y
+	}
+
+	nx, ok := number(x)
+
+	if !ok {
+		return x, y
+	}
+
+	if ny, ok := number(y); ok {
+		return nx, ny
+	}
+
+	return x, y
+}
+
+// number returns integer, float or numeric string v as a number
+func number(v Value) (Value, bool) {
+	switch v := deref(v).(type) {
+	case Int, Float:
+		return v, true
+	case String:
+		return parseNumeric(v)
+	default:
+		return v, false
+	}
+}
+
 // LessOrEqual => $x <= $y
 func LessOrEqual(ctx *FunctionContext) {
 	right := ctx.global.Pop()
@@ -277,12 +361,114 @@ func Const(ctx *FunctionContext) {
 
 // Load => $a
 func Load(ctx *FunctionContext) {
-	ctx.global.Push(ctx.vars[ctx.global.r1])
+	if v := ctx.vars[ctx.global.r1]; v != nil {
+		ctx.global.Push(deref(v))
+	} else {
+		undefinedVariable(ctx)
+		ctx.global.Push(Null{})
+	}
 }
 
-// LoadRef => &$a
+// LoadQuiet => isset($a), $a ?? null. Undefined variable gives null
+func LoadQuiet(ctx *FunctionContext) {
+	if v := ctx.vars[ctx.global.r1]; v != nil {
+		ctx.global.Push(deref(v))
+	} else {
+		ctx.global.Push(Null{})
+	}
+}
+
+// LoadRef => &$a. Variable is moved out of the stack, so the reference outlives the frame
 func LoadRef(ctx *FunctionContext) {
-	ctx.global.Push(NewRef(&ctx.vars[ctx.global.r1]))
+	v := &ctx.vars[ctx.global.r1]
+
+	if *v == nil {
+		*v = Null{}
+	}
+
+	if !(*v).IsRef() {
+		value := *v
+		*v = NewRef(&value)
+	}
+
+	ctx.global.Push(*v)
+}
+
+// GlobalRef => global $a. Global variable becomes a reference, which is bound to local variable
+func GlobalRef(ctx *FunctionContext) {
+	if int(ctx.global.r1) >= len(ctx.global.globals) {
+		var v Value = Null{}
+		ctx.global.Push(NewRef(&v))
+		return
+	}
+
+	v := &ctx.global.globals[ctx.global.r1]
+
+	if *v == nil {
+		*v = Null{}
+	}
+
+	if !(*v).IsRef() {
+		value := *v
+		*v = NewRef(&value)
+	}
+
+	ctx.global.Push(*v)
+}
+
+// StaticRef => static $a. Storage of static variable outlives calls of the function
+func StaticRef(ctx *FunctionContext) {
+	v := &ctx.global.StaticVars[ctx.global.r1]
+
+	if !(*v).IsRef() {
+		value := *v
+		*v = NewRef(&value)
+	}
+
+	ctx.global.Push(*v)
+}
+
+// Bind replaces variable with reference on top of the stack
+func Bind(ctx *FunctionContext) {
+	ctx.vars[ctx.global.r1] = ctx.global.Pop()
+}
+
+// ArgPassed => function f($a = 1). Checks if optional parameter is passed or its default value must be evaluated
+func ArgPassed(ctx *FunctionContext) {
+	ctx.global.Push(Bool(ctx.vars[ctx.global.r1] != nil))
+}
+
+// SkipArg => function f($a = 1) {}; f(). Optional argument, which is not passed, is left undefined, so that
+// the callee evaluates its default value
+func SkipArg(ctx *FunctionContext) {
+	ctx.global.Push(nil)
+}
+
+// Unset => unset($a). Variable becomes undefined, a reference is broken without changing the referenced value
+func Unset(ctx *FunctionContext) {
+	ctx.vars[ctx.global.r1] = nil
+}
+
+// variable returns variable, which is read and written by instruction. Undefined variable is read as null
+func variable(ctx *FunctionContext) *Value {
+	v := &ctx.vars[ctx.global.r1]
+
+	if *v == nil {
+		undefinedVariable(ctx)
+		*v = Null{}
+	}
+
+	return v
+}
+
+func undefinedVariable(ctx *FunctionContext) {
+	var name string
+
+	if i := int(ctx.global.r1); i < len(ctx.names) {
+		name = string(ctx.names[i])
+	}
+
+	ctx.Throw(NewThrowable("Undefined variable "+name, EWarning))
 }
 
 // Assign => $a = 0
@@ -300,7 +486,11 @@ func AssignRef(ctx *FunctionContext) {
 // AssignAdd => $a += 1
 func AssignAdd(ctx *FunctionContext) {
 	right := *ctx.global.sp
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "+", *v, right) {
+		return
+	}
 
 	switch Juggle((*v).Type(), right.Type()) {
 	case ArrayType:
@@ -317,7 +507,11 @@ func AssignAdd(ctx *FunctionContext) {
 // AssignSub => $a -= 1
 func AssignSub(ctx *FunctionContext) {
 	right := *ctx.global.sp
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "-", *v, right) {
+		return
+	}
 
 	switch FloatType {
 	case (*v).Type(), right.Type():
@@ -331,7 +525,11 @@ func AssignSub(ctx *FunctionContext) {
 // AssignMul => $a *= 1
 func AssignMul(ctx *FunctionContext) {
 	right := *ctx.global.sp
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "*", *v, right) {
+		return
+	}
 
 	switch FloatType {
 	case (*v).Type(), right.Type():
@@ -345,7 +543,16 @@ func AssignMul(ctx *FunctionContext) {
 // AssignDiv => $a /= 1
 func AssignDiv(ctx *FunctionContext) {
 	right := *ctx.global.sp
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "/", *v, right) {
+		return
+	}
+
+	if right.AsFloat(ctx) == 0 {
+		ctx.Throw(NewDivisionByZeroError("Division by zero"))
+		return
+	}
 
 	if res := (*v).AsFloat(ctx) / right.AsFloat(ctx); res == Float(int(res)) {
 		assignTryRef(v, res.AsInt(ctx))
@@ -358,7 +565,11 @@ func AssignDiv(ctx *FunctionContext) {
 // AssignPow => $a **= 1
 func AssignPow(ctx *FunctionContext) {
 	right := *ctx.global.sp
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "**", *v, right) {
+		return
+	}
 	as := Juggle((*v).Type(), right.Type())
 
 	var res Value
@@ -376,56 +587,91 @@ func AssignPow(ctx *FunctionContext) {
 
 // AssignBwAnd => $a &= 1
 func AssignBwAnd(ctx *FunctionContext) {
-	right := (*ctx.global.sp).AsInt(ctx)
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*v).AsInt(ctx)&right)
+	right := *ctx.global.sp
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "&", *v, right) {
+		return
+	}
+
+	assignTryRef(v, (*v).AsInt(ctx)&right.AsInt(ctx))
 	*ctx.global.sp = *v
 }
 
 // AssignBwOr => $a |= 1
 func AssignBwOr(ctx *FunctionContext) {
-	right := (*ctx.global.sp).AsInt(ctx)
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*v).AsInt(ctx)|right)
+	right := *ctx.global.sp
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "|", *v, right) {
+		return
+	}
+
+	assignTryRef(v, (*v).AsInt(ctx)|right.AsInt(ctx))
 	*ctx.global.sp = *v
 }
 
 // AssignBwXor => $a ^= 1
 func AssignBwXor(ctx *FunctionContext) {
-	right := (*ctx.global.sp).AsInt(ctx)
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*v).AsInt(ctx)^right)
+	right := *ctx.global.sp
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "^", *v, right) {
+		return
+	}
+
+	assignTryRef(v, (*v).AsInt(ctx)^right.AsInt(ctx))
 	*ctx.global.sp = *v
 }
 
 // AssignConcat => $a .= 1
 func AssignConcat(ctx *FunctionContext) {
+	v := variable(ctx)
 	right := (*ctx.global.sp).AsString(ctx)
-	v := &ctx.vars[ctx.global.r1]
 	assignTryRef(v, (*v).AsString(ctx)+right)
 	*ctx.global.sp = *v
 }
 
 // AssignShiftLeft => $a <<= 1
 func AssignShiftLeft(ctx *FunctionContext) {
-	right := (*ctx.global.sp).AsInt(ctx)
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*v).AsInt(ctx)<<right)
+	right := *ctx.global.sp
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "<<", *v, right) {
+		return
+	}
+
+	assignTryRef(v, (*v).AsInt(ctx)<<right.AsInt(ctx))
 	*ctx.global.sp = *v
 }
 
 // AssignShiftRight => $a >>= 1
 func AssignShiftRight(ctx *FunctionContext) {
-	right := (*ctx.global.sp).AsInt(ctx)
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*v).AsInt(ctx)>>right)
+	right := *ctx.global.sp
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, ">>", *v, right) {
+		return
+	}
+
+	assignTryRef(v, (*v).AsInt(ctx)>>right.AsInt(ctx))
 	*ctx.global.sp = *v
 }
 
 // AssignMod => $a %= 1
 func AssignMod(ctx *FunctionContext) {
+	v := variable(ctx)
+
+	if unsupportedOperands(ctx, "%", *v, *ctx.global.sp) {
+		return
+	}
+
 	right := (*ctx.global.sp).AsFloat(ctx)
-	v := &ctx.vars[ctx.global.r1]
+
+	if Float(right).AsInt(ctx) == 0 {
+		ctx.Throw(NewDivisionByZeroError("Modulo by zero"))
+		return
+	}
 	left := (*v).AsFloat(ctx)
 
 	if res := Float(math.Mod(float64(left), float64(right))); res == Float(int(res)) {
@@ -441,6 +687,43 @@ func Jump(ctx *FunctionContext) {
 	ctx.pc = int(ctx.global.r1) - 1
 }
 
+// JumpOut => break; continue. Jump leaves try blocks, so their finally blocks are executed before the jump
+func JumpOut(ctx *FunctionContext) {
+	frame := ctx.global.frame
+	frame.jump(pendingJump{pc: int(ctx.global.r1), depth: ctx.global.TopIndex() - frame.base})
+}
+
+// JumpTable => switch ($x) { case 1: ... case 2: ... }
+//
+// Table maps values of cases to offsets of their bodies relative to the instruction. Subject stays on the stack.
+// Subject of the same type as cases, which is not in the table, continues to the next instruction, which jumps
+// to default case. Subject of another type and numeric string, which equals numeric strings of other forms,
+// skip it and are compared with the cases one by one
+func JumpTable(ctx *FunctionContext) {
+	table := ctx.global.Constants[ctx.global.r1].(*Array)
+	subject := deref(*ctx.global.sp)
+
+	if s, ok := subject.(String); ok {
+		if _, numeric := parseNumeric(s); numeric {
+			ctx.pc += 2
+			return
+		}
+	}
+
+	if offset, ok := table.access(subject); ok {
+		ctx.pc += int((*offset.Deref()).(Int)) - 2
+		return
+	}
+
+	for key := range table.hash {
+		if key.Type() != subject.Type() {
+			ctx.pc += 2
+		}
+
+		break
+	}
+}
+
 // JumpTrue if (condition)
 func JumpTrue(ctx *FunctionContext) {
 	if ctx.global.Pop().AsBool(ctx) {
@@ -457,7 +740,19 @@ func JumpFalse(ctx *FunctionContext) {
 
 // Call => $b = someFunction($a, $x)
 func Call(ctx *FunctionContext) {
-	ctx.global.Functions[ctx.global.r1].Invoke(ctx)
+	if fn := ctx.global.Functions[ctx.global.r1]; fn != nil {
+		fn.Invoke(ctx)
+		return
+	}
+
+	var name String
+
+	if int(ctx.global.r1) < len(ctx.global.FunctionNames) {
+		name = ctx.global.FunctionNames[ctx.global.r1]
+	}
+
+	ctx.Throw(NewError(fmt.Sprintf("Call to undefined function %s()", string(name))))
+	ctx.global.Push(Null{})
 }
 
 func Pop(ctx *FunctionContext) {
@@ -470,14 +765,38 @@ func Pop2(ctx *FunctionContext) {
 
 // ReturnValue => return 0;
 func ReturnValue(ctx *FunctionContext) {
-	v := *ctx.global.sp
+	v := deref(*ctx.global.sp)
+
+	if ctx.returns != nil && ctx.global.frame.generator == nil {
+		var ok bool
+
+		if v, ok = assertReturn(ctx, v); !ok {
+			return
+		}
+	}
+
+	if ctx.global.frame.finally(pendingReturn{v}) {
+		return
+	}
+
+	if gen := ctx.global.frame.generator; gen != nil {
+		gen.returned = v
+		Return(ctx)
+		return
+	}
+
 	Return(ctx)
 	ctx.global.Push(v)
 }
 
 // Return => return;
 func Return(ctx *FunctionContext) {
-	ctx.global.Sp(ctx.global.PopFrame().fp)
+	frame := ctx.global.PopFrame()
+	ctx.global.Sp(frame.fp)
+
+	if frame.generator != nil {
+		frame.generator.finish()
+	}
 }
 
 // Add => 1 + 2
@@ -485,6 +804,10 @@ func Add(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
 
+	if unsupportedOperands(ctx, "+", left, right) {
+		return
+	}
+
 	switch FloatType {
 	case left.Type(), right.Type():
 		*ctx.global.sp = left.AsFloat(ctx) + right.AsFloat(ctx)
@@ -499,6 +822,22 @@ func Add(ctx *FunctionContext) {
 	}
 }
 
+// unsupportedOperands throws TypeError, if arithmetic or bitwise operator op is applied to an array or an object.
+// Arrays are only added to arrays
+func unsupportedOperands(ctx *FunctionContext, op string, left, right Value) bool {
+	l, r := deref(left).Type(), deref(right).Type()
+
+	switch {
+	case op == "+" && l == ArrayType && r == ArrayType:
+		return false
+	case l == ArrayType, r == ArrayType, l == ObjectType, r == ObjectType:
+		ctx.Throw(NewTypeError(fmt.Sprintf("Unsupported operand types: %s %s %s", DebugType(left), op, DebugType(right))))
+		return true
+	}
+
+	return false
+}
+
 //go:noinline
 func addArray(left, right *Array) *Array {
 	result := maps.Clone(right.hash)
@@ -511,6 +850,10 @@ func Sub(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
 
+	if unsupportedOperands(ctx, "-", left, right) {
+		return
+	}
+
 	switch FloatType {
 	case left.Type(), right.Type():
 		*ctx.global.sp = left.AsFloat(ctx) - right.AsFloat(ctx)
@@ -524,6 +867,10 @@ func Mul(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
 
+	if unsupportedOperands(ctx, "*", left, right) {
+		return
+	}
+
 	switch FloatType {
 	case left.Type(), right.Type():
 		*ctx.global.sp = left.AsFloat(ctx) * right.AsFloat(ctx)
@@ -536,6 +883,16 @@ func Mul(ctx *FunctionContext) {
 func Div(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
+
+	if unsupportedOperands(ctx, "/", left, right) {
+		return
+	}
+
+	if right.AsFloat(ctx) == 0 {
+		ctx.Throw(NewDivisionByZeroError("Division by zero"))
+		return
+	}
+
 	res := left.AsFloat(ctx) / right.AsFloat(ctx)
 
 	switch FloatType {
@@ -554,13 +911,19 @@ func Div(ctx *FunctionContext) {
 func Mod(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
+
+	if unsupportedOperands(ctx, "%", left, right) {
+		return
+	}
 	as := Juggle(left.Type(), right.Type())
 
+	if right.AsInt(ctx) == 0 {
+		ctx.Throw(NewDivisionByZeroError("Modulo by zero"))
+		return
+	}
+
 	switch as {
 	case BoolType:
-		if !right.AsBool(ctx) {
-			panic("modulo by zero error")
-		}
 		*ctx.global.sp = Int(0)
 	default:
 		*ctx.global.sp = Float(math.Mod(float64(left.AsFloat(ctx)), float64(right.AsFloat(ctx)))).Cast(ctx, as)
@@ -571,6 +934,10 @@ func Mod(ctx *FunctionContext) {
 func Pow(ctx *FunctionContext) {
 	right := ctx.global.Pop()
 	left := *ctx.global.sp
+
+	if unsupportedOperands(ctx, "**", left, right) {
+		return
+	}
 	as := Juggle(left.Type(), right.Type())
 
 	switch as {
@@ -583,23 +950,35 @@ func Pow(ctx *FunctionContext) {
 
 // BwAnd => 1 & 2
 func BwAnd(ctx *FunctionContext) {
-	right := ctx.global.Pop().AsInt(ctx)
-	left := (*ctx.global.sp).AsInt(ctx)
-	*ctx.global.sp = left & right
+	right := ctx.global.Pop()
+
+	if unsupportedOperands(ctx, "&", *ctx.global.sp, right) {
+		return
+	}
+
+	*ctx.global.sp = (*ctx.global.sp).AsInt(ctx) & right.AsInt(ctx)
 }
 
 // BwOr => 1 | 2
 func BwOr(ctx *FunctionContext) {
-	right := ctx.global.Pop().AsInt(ctx)
-	left := (*ctx.global.sp).AsInt(ctx)
-	*ctx.global.sp = left | right
+	right := ctx.global.Pop()
+
+	if unsupportedOperands(ctx, "|", *ctx.global.sp, right) {
+		return
+	}
+
+	*ctx.global.sp = (*ctx.global.sp).AsInt(ctx) | right.AsInt(ctx)
 }
 
 // BwXor => 1 ^ 2
 func BwXor(ctx *FunctionContext) {
-	right := ctx.global.Pop().AsInt(ctx)
-	left := (*ctx.global.sp).AsInt(ctx)
-	*ctx.global.sp = left ^ right
+	right := ctx.global.Pop()
+
+	if unsupportedOperands(ctx, "^", *ctx.global.sp, right) {
+		return
+	}
+
+	*ctx.global.sp = (*ctx.global.sp).AsInt(ctx) ^ right.AsInt(ctx)
 }
 
 // BwNot => ~1
@@ -610,16 +989,24 @@ func BwNot(ctx *FunctionContext) {
 
 // ShiftLeft => 1 << 2
 func ShiftLeft(ctx *FunctionContext) {
-	right := ctx.global.Pop().AsInt(ctx)
-	left := (*ctx.global.sp).AsInt(ctx)
-	*ctx.global.sp = left << right
+	right := ctx.global.Pop()
+
+	if unsupportedOperands(ctx, "<<", *ctx.global.sp, right) {
+		return
+	}
+
+	*ctx.global.sp = (*ctx.global.sp).AsInt(ctx) << right.AsInt(ctx)
 }
 
 // ShiftRight => 1 >> 2
 func ShiftRight(ctx *FunctionContext) {
-	right := ctx.global.Pop().AsInt(ctx)
-	left := (*ctx.global.sp).AsInt(ctx)
-	*ctx.global.sp = left >> right
+	right := ctx.global.Pop()
+
+	if unsupportedOperands(ctx, ">>", *ctx.global.sp, right) {
+		return
+	}
+
+	*ctx.global.sp = (*ctx.global.sp).AsInt(ctx) >> right.AsInt(ctx)
 }
 
 // Cast => (_type_)$x
@@ -629,7 +1016,7 @@ func Cast(ctx *FunctionContext) {
 
 // PreIncrement => ++$x
 func PreIncrement(ctx *FunctionContext) {
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
 
 	if (*v).IsRef() {
 		v = (*v).(Ref).Deref()
@@ -647,7 +1034,7 @@ func PreIncrement(ctx *FunctionContext) {
 
 // PreDecrement => --$x
 func PreDecrement(ctx *FunctionContext) {
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
 
 	if (*v).IsRef() {
 		v = (*v).(Ref).Deref()
@@ -665,7 +1052,7 @@ func PreDecrement(ctx *FunctionContext) {
 
 // PostIncrement => $x++
 func PostIncrement(ctx *FunctionContext) {
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
 
 	if (*v).IsRef() {
 		v = (*v).(Ref).Deref()
@@ -683,7 +1070,7 @@ func PostIncrement(ctx *FunctionContext) {
 
 // PostDecrement => $x--
 func PostDecrement(ctx *FunctionContext) {
-	v := &ctx.vars[ctx.global.r1]
+	v := variable(ctx)
 
 	if (*v).IsRef() {
 		v = (*v).(Ref).Deref()
@@ -706,10 +1093,17 @@ func Concat(ctx *FunctionContext) {
 	*ctx.global.sp = left + right
 }
 
-// AssertType => fn(int $a)
-func AssertType(ctx *FunctionContext) {
-	// TODO: check if strict types
-	*ctx.global.sp = (*ctx.global.sp).Cast(ctx, Type(ctx.global.r1))
+// ConcatN => "$a and $b". Concatenates r1 values on top of the stack at once
+func ConcatN(ctx *FunctionContext) {
+	var str strings.Builder
+	n := int(ctx.global.r1)
+
+	for _, v := range ctx.global.Slice(-n, 0) {
+		str.WriteString(string(v.AsString(ctx)))
+	}
+
+	ctx.global.MovePointer(1 - n)
+	*ctx.global.sp = String(str.String())
 }
 
 // Echo => echo $x, $y;
@@ -724,12 +1118,31 @@ func Echo(ctx *FunctionContext) {
 	}
 
 	fmt.Fprint(ctx.Output(), values...)
+	ctx.global.MovePointer(-int(count))
 }
 
 // IsSet => isset($x)
 func IsSet(ctx *FunctionContext) {
-	v := *ctx.global.sp
-	*ctx.global.sp = Bool(v != nil && v != Null{})
+	set := true
+
+	for _, v := range ctx.global.Slice(-int(ctx.global.r1), 0) {
+		set = set && v != nil && v != Null{}
+	}
+
+	ctx.global.MovePointer(1 - int(ctx.global.r1))
+	*ctx.global.sp = Bool(set)
+}
+
+// MatchError => match ($x) {}, when no arm matches the subject
+func MatchError(ctx *FunctionContext) {
+	switch v := deref(*ctx.global.sp).(type) {
+	case Int:
+		ctx.Throw(newError(unhandledMatchError, fmt.Sprintf("Unhandled match case %d", v)))
+	case String:
+		ctx.Throw(newError(unhandledMatchError, fmt.Sprintf("Unhandled match case '%s'", strings.ReplaceAll(string(v), "'", "\\'"))))
+	default:
+		ctx.Throw(newError(unhandledMatchError, "Unhandled match case of type "+DebugType(v)))
+	}
 }
 
 // ArrayNew => $x = [];
@@ -749,6 +1162,20 @@ func ArrayAccessRead(ctx *FunctionContext) {
 	}
 }
 
+// ArrayAccessQuiet => isset($x['test']), $x['test'] ?? null. Missing keys and values, which are not arrays, give null
+func ArrayAccessQuiet(ctx *FunctionContext) {
+	key := ctx.global.Pop()
+
+	if arr, ok := deref(*ctx.global.sp).(*Array); ok {
+		if v, ok := arr.access(key); ok {
+			*ctx.global.sp = *v.Deref()
+			return
+		}
+	}
+
+	*ctx.global.sp = Null{}
+}
+
 // ArrayAccessWrite => $x['test'] = 1
 func ArrayAccessWrite(ctx *FunctionContext) {
 	key := ctx.global.Pop()
@@ -756,7 +1183,7 @@ func ArrayAccessWrite(ctx *FunctionContext) {
 	var arr *Array
 
 	if (*ctx.global.sp).IsRef() {
-		arr = ctx.global.Pop().AsArray(ctx)
+		arr = autoVivify(ctx, ctx.global.Pop().(Ref))
 	} else {
 		arr = (*ctx.global.sp).AsArray(ctx)
 	}
@@ -764,12 +1191,34 @@ func ArrayAccessWrite(ctx *FunctionContext) {
 	ctx.global.Push(arr.assign(ctx, key))
 }
 
+// ArrayBind => [&$x['test']] = $y. Element of array is bound to reference on top of the stack.
+// Operand is 0, if the element is appended => [&$x[]] = $y
+func ArrayBind(ctx *FunctionContext) {
+	ref := ctx.global.Pop().(Ref)
+
+	var key Value
+
+	if ctx.global.r1 != 0 {
+		key = ctx.global.Pop()
+	}
+
+	var arr *Array
+
+	if (*ctx.global.sp).IsRef() {
+		arr = autoVivify(ctx, ctx.global.Pop().(Ref))
+	} else {
+		arr = ctx.global.Pop().AsArray(ctx)
+	}
+
+	arr.bind(ctx, key, ref)
+}
+
 // ArrayAccessPush => $x[] = 1
 func ArrayAccessPush(ctx *FunctionContext) {
 	var arr *Array
 
 	if (*ctx.global.sp).IsRef() {
-		arr = ctx.global.Pop().AsArray(ctx)
+		arr = autoVivify(ctx, ctx.global.Pop().(Ref))
 	} else {
 		arr = (*ctx.global.sp).AsArray(ctx)
 	}
@@ -777,12 +1226,69 @@ func ArrayAccessPush(ctx *FunctionContext) {
 	ctx.global.Push(arr.assign(ctx, nil))
 }
 
-// ArrayUnset => unset($x['test'])
+// ArraySpread => [...$a, ...$b]
+func ArraySpread(ctx *FunctionContext) {
+	src := deref(ctx.global.Pop())
+	arr := (*ctx.global.sp).(*Array)
+
+	spread := func(key, value Value) {
+		if _, ok := key.(String); !ok {
+			key = nil
+		}
+
+		*arr.assign(ctx, key).Deref() = deref(value)
+	}
+
+	switch src := src.(type) {
+	case *Array:
+		for _, key := range src.Keys(ctx) {
+			spread(key, *src.hash[key].Deref())
+		}
+	case Iterator, IteratorAggregate:
+		it, ok := src.(Iterator)
+
+		if !ok {
+			it = src.(IteratorAggregate).GetIterator(ctx)
+		}
+
+		for it.Rewind(ctx); ctx.global.thrown == nil && it.Valid(ctx); it.Next(ctx) {
+			spread(it.Key(ctx), it.Current(ctx))
+		}
+	default:
+		ctx.Throw(NewError("Only arrays and Traversables can be unpacked"))
+	}
+}
+
+// PackArgs => f(...$args); f(name: $x)
+func PackArgs(ctx *FunctionContext) {
+	*ctx.global.sp = packedArgs{(*ctx.global.sp).(*Array)}
+}
+
+// autoVivify creates an array in place of null referenced by ref => $x['a']['b'] = 1
+func autoVivify(ctx *FunctionContext, ref Ref) *Array {
+	v := ref.Deref()
+
+	for (*v).IsRef() {
+		v = (*v).(Ref).Deref()
+	}
+
+	if _, ok := (*v).(Null); ok {
+		*v = NewArray(nil)
+	}
+
+	return (*v).AsArray(ctx)
+}
+
+// ArrayUnset => unset($x['test']). Unset of missing key or key of value, which is not an array, is ignored
 func ArrayUnset(ctx *FunctionContext) {
 	key := ctx.global.Pop()
-	arr := ctx.global.Pop().AsArray(ctx)
-	arr.delete(key)
-	ctx.global.Push(arr)
+
+	switch v := deref(ctx.global.Pop()).(type) {
+	case *Array:
+		v.delete(key)
+	case String:
+		ctx.Throw(NewError("Cannot unset string offsets"))
+	}
 }
 
 // ForEachInit => foreach([1,2] as ...)
@@ -793,7 +1299,7 @@ func ForEachInit(ctx *FunctionContext) {
 	case IteratorAggregate:
 		iterable = iterable.(IteratorAggregate).GetIterator(ctx)
 	default:
-		ctx.Throw(NewThrowable("not iterable", EError))
+		ctx.Throw(NewError("not iterable"))
 		return
 	}
 	iterable.(Iterator).Rewind(ctx)
@@ -816,10 +1322,16 @@ func ForEachValue(ctx *FunctionContext) {
 	assignTryRef(variable, value)
 }
 
-// ForEachValueRef => foreach(... as &$value)
+// ForEachValueRef => foreach(... as &$value). Variable is bound to the element, so the reference of previous
+// element is broken
 func ForEachValueRef(ctx *FunctionContext) {
-	v := &ctx.vars[ctx.global.r1]
-	assignTryRef(v, (*ctx.global.sp).(Iterator).Current(ctx))
+	v := (*ctx.global.sp).(Iterator).Current(ctx)
+
+	if !v.IsRef() {
+		v = NewRef(&v)
+	}
+
+	ctx.vars[ctx.global.r1] = v
 }
 
 func ForEachNext(ctx *FunctionContext) {
@@ -832,9 +1344,475 @@ func ForEachValid(ctx *FunctionContext) {
 }
 
 // Throw => throw new Exception();
-func Throw(ctx *FunctionContext) {}
+// Throw => throw $e
+func Throw(ctx *FunctionContext) {
+	switch v := deref(ctx.global.Pop()).(type) {
+	case *Object:
+		if !v.class.InstanceOf(throwableInterface) {
+			ctx.Throw(NewError("Cannot throw objects that do not implement Throwable"))
+			return
+		}
+
+		ctx.Throw(&exception{v})
+	default:
+		ctx.Throw(NewError("Can only throw objects"))
+	}
+}
+
+// EndFinally completes finally block rethrowing pending exception, returning pending value or making pending jump
+func EndFinally(ctx *FunctionContext) {
+	switch v := ctx.global.Pop().(type) {
+	case pendingThrow:
+		ctx.Throw(&exception{v.Object})
+	case pendingReturn:
+		ctx.global.Push(v.Value)
+		ReturnValue(ctx)
+	case pendingJump:
+		ctx.global.frame.jump(v)
+	}
+}
 
 // CallByName => $func = "func_name"; $func();
 func CallByName(ctx *FunctionContext) {
-	ctx.global.FunctionByName(ctx.global.Pop().AsString(ctx)).Invoke(ctx)
+	if fn := ctx.global.FunctionByName(ctx.global.Pop().AsString(ctx)); fn != nil {
+		fn.Invoke(ctx)
+	}
+}
+
+// Dup => duplicates value on top of the stack
+func Dup(ctx *FunctionContext) {
+	ctx.global.Push(*ctx.global.sp)
+}
+
+// This => $this
+func This(ctx *FunctionContext) {
+	if ctx.this == nil {
+		ctx.Throw(NewError("Using $this when not in object context"))
+		ctx.global.Push(Null{})
+		return
+	}
+
+	ctx.global.Push(ctx.this)
+}
+
+// PropertyFetch => $x->prop
+func PropertyFetch(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	switch obj := deref(*ctx.global.sp).(type) {
+	case *Object:
+		*ctx.global.sp = obj.fetch(ctx, name)
+	case *Enum:
+		*ctx.global.sp = obj.fetch(ctx, name)
+	default:
+		ctx.Throw(NewThrowable(fmt.Sprintf("Attempt to read property \"%s\" on %s", string(name), DebugType(obj)), EWarning))
+		*ctx.global.sp = Null{}
+	}
+}
+
+// PropertyFetchQuiet => isset($x->prop['k']), $x->prop ?? null. Undefined and inaccessible properties give null
+func PropertyFetchQuiet(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	switch obj := deref(*ctx.global.sp).(type) {
+	case *Object:
+		*ctx.global.sp = obj.quiet(ctx, name)
+		return
+	case *Enum:
+		if name == "name" || name == "value" && obj.Value != nil {
+			*ctx.global.sp = obj.fetch(ctx, name)
+			return
+		}
+	}
+
+	*ctx.global.sp = Null{}
+}
+
+// PropertyUnset => unset($x->prop). Unset of undefined property is ignored
+func PropertyUnset(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	switch obj := deref(ctx.global.Pop()).(type) {
+	case *Object:
+		obj.remove(ctx, name)
+	case *Enum:
+		ctx.Throw(NewError(fmt.Sprintf("Cannot modify readonly property %s::$%s", string(obj.class.Name), string(name))))
+	}
+}
+
+// PropertyWrite => $x->prop['test'] = 1
+func PropertyWrite(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	var v Value
+
+	if (*ctx.global.sp).IsRef() {
+		v = deref(ctx.global.Pop())
+	} else {
+		v = *ctx.global.sp
+	}
+
+	switch obj := v.(type) {
+	case *Object:
+		ctx.global.Push(obj.write(ctx, name))
+	case *Enum:
+		ctx.Throw(obj.readonly(name))
+		ctx.global.Push(NewRef(nil))
+	default:
+		ctx.Throw(NewError(fmt.Sprintf("Attempt to modify property \"%s\" on %s", string(name), DebugType(obj))))
+		ctx.global.Push(NewRef(nil))
+	}
+}
+
+// PropertyRef => $x = &$y->prop. Object and name of property are replaced with reference to the property
+func PropertyRef(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	switch obj := deref(*ctx.global.sp).(type) {
+	case *Object:
+		*ctx.global.sp = obj.write(ctx, name)
+	case *Enum:
+		ctx.Throw(obj.readonly(name))
+		*ctx.global.sp = NewRef(nil)
+	default:
+		ctx.Throw(NewError(fmt.Sprintf("Attempt to modify property \"%s\" on %s", string(name), DebugType(obj))))
+		*ctx.global.sp = NewRef(nil)
+	}
+}
+
+// PropertyIsSet => isset($x->prop). Object and name of property are replaced with true, if the property is set,
+// and with null otherwise, so that ISSET tests it as the other operands. Unlike ?? operator, __get() is not called
+func PropertyIsSet(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	switch obj := deref(*ctx.global.sp).(type) {
+	case *Object:
+		if obj.isset(ctx, name) {
+			*ctx.global.sp = Bool(true)
+			return
+		}
+	case *Enum:
+		if name == "name" || name == "value" && obj.Value != nil {
+			*ctx.global.sp = Bool(true)
+			return
+		}
+	}
+
+	*ctx.global.sp = Null{}
+}
+
+// PropertyBind => [&$x->prop] = $y. Property is bound to reference below its name
+func PropertyBind(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+	ref := ctx.global.Pop().(Ref)
+
+	switch obj := deref(ctx.global.Pop()).(type) {
+	case *Object:
+		obj.bind(ctx, name, ref)
+	case *Enum:
+		ctx.Throw(obj.readonly(name))
+	default:
+		ctx.Throw(NewError(fmt.Sprintf("Attempt to assign property \"%s\" on %s", string(name), DebugType(obj))))
+	}
+}
+
+// PropertyAssign => $x->prop = 1
+func PropertyAssign(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+	value := deref(ctx.global.Pop())
+
+	switch obj := deref(*ctx.global.sp).(type) {
+	case *Object:
+		value = obj.assign(ctx, name, value)
+	case *Enum:
+		ctx.Throw(obj.readonly(name))
+	default:
+		ctx.Throw(NewError(fmt.Sprintf("Attempt to assign property \"%s\" on %s", string(name), DebugType(obj))))
+	}
+
+	*ctx.global.sp = value
+}
+
+// New => new Foo($x)
+func New(ctx *FunctionContext) {
+	argc := int(ctx.global.r1)
+	slot := &ctx.global.Slice(-argc-1, -argc)[0]
+
+	class, _ := resolveClass(ctx, *slot)
+
+	if class == nil {
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		ctx.global.Push(Null{})
+		return
+	}
+
+	if class == closureClass {
+		ctx.Throw(NewError("Instantiation of class Closure is not allowed"))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		ctx.global.Push(Null{})
+		return
+	}
+
+	if class.Abstract || class.Interface || class.Trait || class.Enum {
+		kind := "abstract class"
+
+		switch {
+		case class.Interface:
+			kind = "interface"
+		case class.Trait:
+			kind = "trait"
+		case class.Enum:
+			kind = "enum"
+		}
+
+		ctx.Throw(NewError(fmt.Sprintf("Cannot instantiate %s %s", kind, string(class.Name))))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		ctx.global.Push(Null{})
+		return
+	}
+
+	var obj Value = class.NewInstance(ctx)
+
+	if class == fiberClass {
+		obj = &Fiber{Object: obj.(*Object)}
+	}
+
+	*slot = obj
+	ctor, ok := class.Method("__construct")
+
+	if !ok {
+		ctx.global.MovePointer(-argc)
+		ctx.global.Push(Null{})
+		return
+	}
+
+	if !canAccess(ctx.class, ctor.Class, ctor.Visibility) {
+		ctx.Throw(NewError(fmt.Sprintf("Call to %s %s::__construct() from %s", ctor.Visibility, string(class.Name), scopeName(ctx.class))))
+		ctx.global.MovePointer(-argc)
+		ctx.global.Push(Null{})
+		return
+	}
+
+	// Object is duplicated under the arguments, so that constructor replaces the copy with its result
+	ctx.global.Push(nil)
+	args := ctx.global.Slice(-argc-1, 0)
+	copy(args[1:], args[:argc])
+	args[0] = obj
+	callMethod(ctx, ctor, obj, class, argc)
+}
+
+// CallMethod => $x->method($y)
+func CallMethod(ctx *FunctionContext) {
+	argc := int(ctx.global.r1)
+	name := ctx.global.Pop().AsString(ctx)
+	slot := &ctx.global.Slice(-argc-1, -argc)[0]
+	obj, ok := object(*slot)
+
+	if !ok {
+		ctx.Throw(NewError(fmt.Sprintf("Call to a member function %s() on %s", string(name), DebugType(*slot))))
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	m, err := findMethod(ctx.class, obj.class, name)
+	*slot = deref(*slot)
+
+	if err != nil {
+		if callFallback(ctx, obj.class, *slot, obj.class, name, argc) {
+			return
+		}
+
+		ctx.Throw(err)
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	callMethod(ctx, m, *slot, obj.class, argc)
+}
+
+// StaticPropertyFetch => Foo::$prop
+func StaticPropertyFetch(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	if prop := staticProperty(ctx, *ctx.global.sp, name); prop != nil {
+		*ctx.global.sp = deref(ctx.global.static(prop))
+	} else {
+		*ctx.global.sp = Null{}
+	}
+}
+
+// StaticPropertyWrite => Foo::$prop['test'] = 1
+func StaticPropertyWrite(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	if prop := staticProperty(ctx, *ctx.global.sp, name); prop != nil {
+		ctx.global.Push(ctx.global.static(prop))
+	} else {
+		ctx.global.Push(NewRef(nil))
+	}
+}
+
+// StaticPropertyAssign => Foo::$prop = 1
+func StaticPropertyAssign(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+	value := deref(ctx.global.Pop())
+
+	if prop := staticProperty(ctx, *ctx.global.sp, name); prop != nil {
+		if v, ok := prop.coerce(ctx, value); ok {
+			value = v
+			assignTryRef(ctx.global.static(prop).Deref(), value)
+		}
+	}
+
+	*ctx.global.sp = value
+}
+
+// StaticPropertyRef => $x = &Foo::$prop. Class and name of static property are replaced with reference to it
+func StaticPropertyRef(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+
+	if prop := staticProperty(ctx, *ctx.global.sp, name); prop != nil {
+		*ctx.global.sp = ctx.global.static(prop)
+	} else {
+		*ctx.global.sp = NewRef(nil)
+	}
+}
+
+// StaticPropertyBind => [&Foo::$prop] = $y. Static property is bound to reference below its name
+func StaticPropertyBind(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+	ref := ctx.global.Pop().(Ref)
+
+	if prop := staticProperty(ctx, ctx.global.Pop(), name); prop != nil {
+		if v, ok := prop.coerce(ctx, deref(ref)); ok {
+			*ref.Deref() = v
+			ctx.global.static(prop)
+			ctx.global.statics[prop] = ref
+		}
+	}
+}
+
+func staticProperty(ctx *FunctionContext, class Value, name String) *Property {
+	c, _ := resolveClass(ctx, class)
+
+	if c == nil {
+		return nil
+	}
+
+	prop, err := findStatic(ctx.class, c, name)
+
+	if err != nil {
+		ctx.Throw(err)
+		return nil
+	}
+
+	return prop
+}
+
+// ClassConstFetch => Foo::BAR
+func ClassConstFetch(ctx *FunctionContext) {
+	name := ctx.global.Pop().AsString(ctx)
+	class, _ := resolveClass(ctx, *ctx.global.sp)
+
+	switch {
+	case class == nil:
+		*ctx.global.sp = Null{}
+	case name == "class":
+		*ctx.global.sp = class.Name
+	default:
+		constant, err := findConstant(ctx.class, class, name)
+
+		if err != nil {
+			ctx.Throw(err)
+			*ctx.global.sp = Null{}
+			return
+		}
+
+		*ctx.global.sp = constant.Value
+	}
+}
+
+// CallStatic => Foo::method($x)
+func CallStatic(ctx *FunctionContext) {
+	argc := int(ctx.global.r1)
+	name := ctx.global.Pop().AsString(ctx)
+	slot := &ctx.global.Slice(-argc-1, -argc)[0]
+	class, forward := resolveClass(ctx, *slot)
+
+	if class == nil {
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	m, err := findMethod(ctx.class, class, name)
+	static := class
+
+	if forward && ctx.static != nil {
+		static = ctx.static
+	}
+
+	if err != nil {
+		if callFallback(ctx, class, contextThis(ctx, class), static, name, argc) {
+			return
+		}
+
+		ctx.Throw(err)
+		ctx.global.MovePointer(-argc)
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	var this Value
+
+	if !m.Static {
+		obj, ok := object(ctx.this)
+
+		if !ok || !obj.class.InstanceOf(m.Class) {
+			ctx.Throw(NewError(fmt.Sprintf("Non-static method %s::%s() cannot be called statically", string(m.Class.Name), string(m.Name))))
+			ctx.global.MovePointer(-argc)
+			*ctx.global.sp = Null{}
+			return
+		}
+
+		this, static = ctx.this, obj.class
+	}
+
+	callMethod(ctx, m, this, static, argc)
+}
+
+// InstanceOf => $x instanceof Foo
+func InstanceOf(ctx *FunctionContext) {
+	ref := ctx.global.Pop()
+	obj, ok := object(*ctx.global.sp)
+
+	if !ok {
+		*ctx.global.sp = Bool(false)
+		return
+	}
+
+	var class *Class
+
+	switch ref := deref(ref).(type) {
+	case *Object:
+		class = ref.class
+	case *Enum:
+		class = ref.class
+	case String:
+		switch strings.ToLower(string(ref)) {
+		case "self", "parent", "static":
+			class, _ = resolveClass(ctx, ref)
+		default:
+			class = ctx.global.ClassByName(ref)
+		}
+	default:
+		ctx.Throw(NewError("Class name must be a valid object or a string"))
+	}
+
+	*ctx.global.sp = Bool(class != nil && obj.class.InstanceOf(class))
 }
@@ -73,6 +73,13 @@ func TestEqual(t *testing.T) {
 		{"\"1\" == []", String("1"), NewArray(nil), Bool(false)},
 		{"\"1\" == \"\"", String("1"), String(""), Bool(false)},
 		{"\"1\" == \"php\"", String("1"), String("php"), Bool(false)},
+		{"\"1\" == \"01\"", String("1"), String("01"), Bool(true)},
+		{"\"10\" == \"1e1\"", String("10"), String("1e1"), Bool(true)},
+		{"\"1\" == \" 1\"", String("1"), String(" 1"), Bool(true)},
+		{"\"1\" == \"1x\"", String("1"), String("1x"), Bool(false)},
+		{"10 == \"1e1\"", Int(10), String("1e1"), Bool(true)},
+		{"1.5 == \"1.50\"", Float(1.5), String("1.50"), Bool(true)},
+		{"0 == \"php\"", Int(0), String("php"), Bool(false)},
 
 		{"\"0\" == \"0\"", String("0"), String("0"), Bool(true)},
 		{"\"0\" == \"-1\"", String("0"), String("-1"), Bool(false)},
@@ -196,6 +203,9 @@ func TestCompare(t *testing.T) {
 		{"\"0\" <=> \"0\"", String("0"), String("0"), 0},
 		{"\"1\" <=> \"1\"", String("1"), String("1"), 0},
 		{"\"1\" <=> \"2\"", String("1"), String("2"), -1},
+		{"\"10\" <=> \"9\"", String("10"), String("9"), 1},
+		{"\"1e1\" <=> \"10\"", String("1e1"), String("10"), 0},
+		{"\"abc\" <=> \"10\"", String("abc"), String("10"), 1},
 		{"\"0\" <=> []", String("0"), NewArray(nil), -1},
 		{"\"1\" <=> []", String("1"), NewArray(nil), -1},
 	}
@@ -0,0 +1,221 @@
+package vm
+
+import "fmt"
+
+// magicGuard is a magic method accessing a property, which is running. While the method runs for a property,
+// accesses to the property inside the method are not intercepted by the same method again
+type magicGuard uint8
+
+const (
+	guardGet magicGuard = 1 << iota
+	guardSet
+	guardIsSet
+	guardUnset
+)
+
+// invoke calls method m of this with args and runs it to completion. It lets instructions call user code,
+// e.g. __toString() when an object is converted to string. Exception thrown by the method is left pending
+// and null is returned. Operand of the calling instruction is preserved, as the method runs other instructions
+func invoke(ctx *FunctionContext, m *Method, this Value, static *Class, args ...Value) Value {
+	g := ctx.global
+	top, frame := g.TopIndex(), g.frame
+	defer func(r1 uint64) { g.r1 = r1 }(g.r1)
+	g.Push(this)
+
+	for _, arg := range args {
+		g.Push(arg)
+	}
+
+	callMethod(ctx, m, this, static, len(args))
+
+	if g.frame != frame {
+		g.execute(g.frame)
+	}
+
+	if g.thrown != nil {
+		g.Sp(top)
+		return Null{}
+	}
+
+	return deref(g.Pop())
+}
+
+// functionContext returns context of the function, which is executed when ctx is used
+func functionContext(ctx Context) *FunctionContext {
+	if fctx, ok := ctx.(*FunctionContext); ok {
+		return fctx
+	}
+
+	return &ctx.Global().frame.ctx
+}
+
+// callMagic calls magic method name of object o with args. ok is false, if class of o does not declare the method
+func (o *Object) callMagic(ctx Context, name String, args ...Value) (v Value, ok bool) {
+	m, ok := o.class.Method(name)
+
+	if !ok || m.Abstract {
+		return nil, false
+	}
+
+	return invoke(functionContext(ctx), m, o, o.class, args...), true
+}
+
+// magic calls magic method name of o, which intercepts access to property prop, unless the method is already
+// running for the property
+func (o *Object) magic(ctx Context, guard magicGuard, name, prop String, args ...Value) (Value, bool) {
+	if o.guards[prop]&guard != 0 {
+		return nil, false
+	}
+
+	if _, ok := o.class.Method(name); !ok {
+		return nil, false
+	}
+
+	if o.guards == nil {
+		o.guards = make(map[String]magicGuard)
+	}
+
+	o.guards[prop] |= guard
+	v, ok := o.callMagic(ctx, name, append([]Value{prop}, args...)...)
+
+	if o.guards[prop] &^= guard; o.guards[prop] == 0 {
+		delete(o.guards, prop)
+	}
+
+	return v, ok
+}
+
+// toString converts o to string by __toString(), which implicitly declares string return type
+func (o *Object) toString(ctx Context) (String, bool) {
+	v, ok := o.callMagic(ctx, "__toString")
+
+	if !ok || ctx.Global().thrown != nil {
+		return "", ok
+	}
+
+	m, _ := o.class.Method("__toString")
+	fn, strict := m.Fn.(CompiledFunction)
+
+	if s, accepted := (&TypeDecl{Types: StringType}).accept(functionContext(ctx), v, strict && fn.Strict); accepted {
+		return s.(String), true
+	}
+
+	ctx.Throw(NewTypeError(fmt.Sprintf("%s::__toString(): Return value must be of type string, %s returned", string(o.class.Name), DebugType(v))))
+	return "", true
+}
+
+// stringable reports whether v is an object, which can be converted to string
+func stringable(v Value) bool {
+	if obj, ok := deref(v).(*Object); ok {
+		_, ok = obj.class.Method("__toString")
+		return ok
+	}
+
+	return false
+}
+
+// forwardCall replaces argc arguments on top of the stack with name of called method and an array of the arguments,
+// which are passed to __call() or __callStatic(). Named arguments are kept under their names
+func forwardCall(ctx *FunctionContext, name String, argc int) {
+	argc, packed, named := unpackArgs(ctx, argc)
+	args := NewArray(nil)
+
+	for _, v := range ctx.global.Slice(-argc, 0) {
+		*args.assign(ctx, nil).Deref() = deref(v)
+	}
+
+	for _, key := range named {
+		*args.assign(ctx, key).Deref() = *packed.hash[key].Deref()
+	}
+
+	ctx.global.MovePointer(-argc)
+	ctx.global.Push(name)
+	ctx.global.Push(args)
+}
+
+// Clone => clone $x. Properties are copied to a new object, then __clone() of the copy is called
+func Clone(ctx *FunctionContext) {
+	var obj *Object
+
+	switch v := deref(*ctx.global.sp).(type) {
+	case *Object:
+		obj = v
+	case *Closure:
+		c := *v
+		c.Object = v.Object.clone(ctx)
+		*ctx.global.sp = &c
+		return
+	case *Enum, *Generator, *Fiber:
+		o, _ := object(v)
+		ctx.Throw(NewError(fmt.Sprintf("Trying to clone an uncloneable object of class %s", string(o.class.Name))))
+		*ctx.global.sp = Null{}
+		return
+	default:
+		ctx.Throw(NewError("__clone method called on non-object"))
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	if m, ok := obj.class.Method("__clone"); ok && !canAccess(ctx.class, m.Class, m.Visibility) {
+		ctx.Throw(NewError(fmt.Sprintf("Call to %s %s::__clone() from %s", m.Visibility, string(obj.class.Name), scopeName(ctx.class))))
+		*ctx.global.sp = Null{}
+		return
+	}
+
+	c := obj.clone(ctx)
+	*ctx.global.sp = c
+	c.callMagic(ctx, "__clone")
+}
+
+// clone returns shallow copy of o with a new identity
+func (o *Object) clone(ctx Context) *Object {
+	c := NewObject(ctx, o.class)
+
+	for _, key := range o.keys {
+		v := *o.props[key].Deref()
+
+		if arr, ok := v.(*Array); ok {
+			v = arr.Copy()
+		}
+
+		c.set(key, v)
+	}
+
+	return c
+}
+
+// callFallback calls __call() of this or __callStatic() of class instead of method name, which does not exist
+// or is not accessible. Arguments are forwarded as an array. It reports false, if neither method is declared
+func callFallback(ctx *FunctionContext, class *Class, this Value, static *Class, name String, argc int) bool {
+	m, this, ok := fallback(class, this)
+
+	if !ok {
+		return false
+	}
+
+	forwardCall(ctx, name, argc)
+	callMethod(ctx, m, this, static, 2)
+
+	return true
+}
+
+// fallback returns __call(), if this is given, otherwise __callStatic() of class
+func fallback(class *Class, this Value) (*Method, Value, bool) {
+	if this != nil {
+		if m, ok := class.Method("__call"); ok {
+			return m, this, true
+		}
+	}
+
+	m, ok := class.Method("__callStatic")
+	return m, nil, ok
+}
+
+// invokable returns __invoke() of v, if v is an object, which can be called as a function
+func invokable(v Value) (*Method, bool) {
+	if obj, ok := v.(*Object); ok {
+		return obj.class.Method("__invoke")
+	}
+
+	return nil, false
+}
@@ -42,49 +42,92 @@ func _() {
 	_ = x[OpArrayAccessPush-31]
 	_ = x[OpArrayUnset-32]
 	_ = x[OpConcat-33]
-	_ = x[OpUnset-34]
-	_ = x[OpForEachInit-35]
-	_ = x[OpForEachNext-36]
-	_ = x[OpForEachValid-37]
-	_ = x[OpThrow-38]
-	_ = x[OpCallByName-39]
-	_ = x[_opOneOperand-39]
-	_ = x[OpAssertType-40]
-	_ = x[OpAssign-41]
-	_ = x[OpAssignAdd-42]
-	_ = x[OpAssignSub-43]
-	_ = x[OpAssignMul-44]
-	_ = x[OpAssignDiv-45]
-	_ = x[OpAssignMod-46]
-	_ = x[OpAssignPow-47]
-	_ = x[OpAssignBwAnd-48]
-	_ = x[OpAssignBwOr-49]
-	_ = x[OpAssignBwXor-50]
-	_ = x[OpAssignConcat-51]
-	_ = x[OpAssignShiftLeft-52]
-	_ = x[OpAssignShiftRight-53]
-	_ = x[OpCast-54]
-	_ = x[OpPreIncrement-55]
-	_ = x[OpPostIncrement-56]
-	_ = x[OpPreDecrement-57]
-	_ = x[OpPostDecrement-58]
-	_ = x[OpLoad-59]
-	_ = x[OpLoadRef-60]
-	_ = x[OpConst-61]
-	_ = x[OpJump-62]
-	_ = x[OpJumpTrue-63]
-	_ = x[OpJumpFalse-64]
-	_ = x[OpCall-65]
-	_ = x[OpEcho-66]
-	_ = x[OpIsSet-67]
-	_ = x[OpForEachKey-68]
-	_ = x[OpForEachValue-69]
-	_ = x[OpForEachValueRef-70]
+	_ = x[OpForEachInit-34]
+	_ = x[OpForEachNext-35]
+	_ = x[OpForEachValid-36]
+	_ = x[OpThrow-37]
+	_ = x[OpCallByName-38]
+	_ = x[OpDup-39]
+	_ = x[OpThis-40]
+	_ = x[OpPropertyFetch-41]
+	_ = x[OpPropertyWrite-42]
+	_ = x[OpPropertyAssign-43]
+	_ = x[OpStaticPropertyFetch-44]
+	_ = x[OpStaticPropertyWrite-45]
+	_ = x[OpStaticPropertyAssign-46]
+	_ = x[OpClassConstFetch-47]
+	_ = x[OpInstanceOf-48]
+	_ = x[OpEndFinally-49]
+	_ = x[OpCallable-50]
+	_ = x[OpMethodCallable-51]
+	_ = x[OpGenerator-52]
+	_ = x[OpYieldFrom-53]
+	_ = x[OpArrayAccessQuiet-54]
+	_ = x[OpPropertyFetchQuiet-55]
+	_ = x[OpMatchError-56]
+	_ = x[OpPropertyUnset-57]
+	_ = x[OpArraySpread-58]
+	_ = x[OpPackArgs-59]
+	_ = x[OpClone-60]
+	_ = x[OpPropertyBind-61]
+	_ = x[OpStaticPropertyBind-62]
+	_ = x[OpSkipArg-63]
+	_ = x[OpPropertyRef-64]
+	_ = x[OpStaticPropertyRef-65]
+	_ = x[OpPropertyIsSet-66]
+	_ = x[_opOneOperand-66]
+	_ = x[OpAssign-67]
+	_ = x[OpAssignAdd-68]
+	_ = x[OpAssignSub-69]
+	_ = x[OpAssignMul-70]
+	_ = x[OpAssignDiv-71]
+	_ = x[OpAssignMod-72]
+	_ = x[OpAssignPow-73]
+	_ = x[OpAssignBwAnd-74]
+	_ = x[OpAssignBwOr-75]
+	_ = x[OpAssignBwXor-76]
+	_ = x[OpAssignConcat-77]
+	_ = x[OpAssignShiftLeft-78]
+	_ = x[OpAssignShiftRight-79]
+	_ = x[OpCast-80]
+	_ = x[OpPreIncrement-81]
+	_ = x[OpPostIncrement-82]
+	_ = x[OpPreDecrement-83]
+	_ = x[OpPostDecrement-84]
+	_ = x[OpLoad-85]
+	_ = x[OpLoadRef-86]
+	_ = x[OpConst-87]
+	_ = x[OpJump-88]
+	_ = x[OpJumpTrue-89]
+	_ = x[OpJumpFalse-90]
+	_ = x[OpCall-91]
+	_ = x[OpCallMethod-92]
+	_ = x[OpCallStatic-93]
+	_ = x[OpClosure-94]
+	_ = x[OpCallDynamic-95]
+	_ = x[OpYield-96]
+	_ = x[OpJumpTable-97]
+	_ = x[OpGlobalRef-98]
+	_ = x[OpStaticRef-99]
+	_ = x[OpBind-100]
+	_ = x[OpUnset-101]
+	_ = x[OpLoadQuiet-102]
+	_ = x[OpConcatN-103]
+	_ = x[OpNew-104]
+	_ = x[OpEcho-105]
+	_ = x[OpIsSet-106]
+	_ = x[OpForEachKey-107]
+	_ = x[OpForEachValue-108]
+	_ = x[OpForEachValueRef-109]
+	_ = x[OpArgPassed-110]
+	_ = x[OpAssertParam-111]
+	_ = x[OpArrayBind-112]
+	_ = x[OpJumpOut-113]
 }
 
-const _Operator_name = "NOOPPOPPOP2RETURNRETURN_VALADDSUBMULDIVMODPOWBW_ANDBW_ORBW_XORBW_NOTLSHIFTRSHIFTEQUALNOT_EQUALIDENTICALNOT_IDENTICALNOTGTLTGTELTECOMPAREASSIGN_REFARRAY_NEWARRAY_ACCESS_READARRAY_ACCESS_WRITEARRAY_ACCESS_PUSHARRAY_UNSETCONCATUNSETFE_INITFE_NEXTFE_VALIDTHROWCALL_BY_NAMEASSERT_TYPEASSIGNASSIGN_ADDASSIGN_SUBASSIGN_MULASSIGN_DIVASSIGN_MODASSIGN_POWASSIGN_BW_ANDASSIGN_BW_ORASSIGN_BW_XORASSIGN_CONCATASSIGN_LSHIFTASSIGN_RSHIFTCASTPRE_INCPOST_INCPRE_DECPOST_DECLOADLOAD_REFCONSTJUMPJUMP_TRUEJUMP_FALSECALLECHOISSETFE_KEYFE_VALUEFE_VALUE_REF"
+const _Operator_name = "NOOPPOPPOP2RETURNRETURN_VALADDSUBMULDIVMODPOWBW_ANDBW_ORBW_XORBW_NOTLSHIFTRSHIFTEQUALNOT_EQUALIDENTICALNOT_IDENTICALNOTGTLTGTELTECOMPAREASSIGN_REFARRAY_NEWARRAY_ACCESS_READARRAY_ACCESS_WRITEARRAY_ACCESS_PUSHARRAY_UNSETCONCATFE_INITFE_NEXTFE_VALIDTHROWCALL_BY_NAMEDUPTHISPROP_FETCHPROP_WRITEPROP_ASSIGNSTATIC_PROP_FETCHSTATIC_PROP_WRITESTATIC_PROP_ASSIGNCLASS_CONSTINSTANCE_OFEND_FINALLYCALLABLEMETHOD_CALLABLEGENERATORYIELD_FROMARRAY_ACCESS_QUIETPROP_FETCH_QUIETMATCH_ERRORPROP_UNSETARRAY_SPREADPACK_ARGSCLONEPROP_BINDSTATIC_PROP_BINDSKIP_ARGPROP_REFSTATIC_PROP_REFPROP_ISSETASSIGNASSIGN_ADDASSIGN_SUBASSIGN_MULASSIGN_DIVASSIGN_MODASSIGN_POWASSIGN_BW_ANDASSIGN_BW_ORASSIGN_BW_XORASSIGN_CONCATASSIGN_LSHIFTASSIGN_RSHIFTCASTPRE_INCPOST_INCPRE_DECPOST_DECLOADLOAD_REFCONSTJUMPJUMP_TRUEJUMP_FALSECALLCALL_METHODCALL_STATICCLOSURECALL_DYNAMICYIELDJUMP_TABLEGLOBAL_REFSTATIC_REFBINDUNSETLOAD_QUIETCONCAT_NNEWECHOISSETFE_KEYFE_VALUEFE_VALUE_REFARG_PASSEDASSERT_PARAMARRAY_BINDJUMP_OUT"
 
-var _Operator_index = [...]uint16{0, 4, 7, 11, 17, 27, 30, 33, 36, 39, 42, 45, 51, 56, 62, 68, 74, 80, 85, 94, 103, 116, 119, 121, 123, 126, 129, 136, 146, 155, 172, 190, 207, 218, 224, 229, 236, 243, 251, 256, 268, 279, 285, 295, 305, 315, 325, 335, 345, 358, 370, 383, 396, 409, 422, 426, 433, 441, 448, 456, 460, 468, 473, 477, 486, 496, 500, 504, 509, 515, 523, 535}
+var _Operator_index = [...]uint16{0, 4, 7, 11, 17, 27, 30, 33, 36, 39, 42, 45, 51, 56, 62, 68, 74, 80, 85, 94, 103, 116, 119, 121, 123, 126, 129, 136, 146, 155, 172, 190, 207, 218, 224, 231, 238, 246, 251, 263, 266, 270, 280, 290, 301, 318, 335, 353, 364, 375, 386, 394, 409, 418, 428, 446, 462, 473, 483, 495, 504, 509, 518, 534, 542, 550, 565, 575, 581, 591, 601, 611, 621, 631, 641, 654, 666, 679, 692, 705, 718, 722, 729, 737, 744, 752, 756, 764, 769, 773, 782, 792, 796, 807, 818, 825, 837, 842, 852, 862, 872, 876, 881, 891, 899, 902, 906, 911, 917, 925, 937, 947, 959, 969, 977}
 
 func (i Operator) String() string {
 	if i >= Operator(len(_Operator_index)-1) {
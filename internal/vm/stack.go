@@ -18,7 +18,7 @@ type stackIface[T any] interface {
 
 type Stack[T any] struct {
 	sp    *T
-	stack [stackSize]T
+	stack []T
 }
 
 func (s *Stack[T]) Init() {
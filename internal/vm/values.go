@@ -2,7 +2,6 @@ package vm
 
 import (
 	"fmt"
-	"maps"
 	"math"
 	"slices"
 	"strconv"
@@ -24,6 +23,148 @@ const (
 
 func Juggle(x, y Type) Type { return max(x, y) }
 
+// invalidCast raises TypeError for conversion of v to type t, which is not supported
+func invalidCast(ctx Context, v Value, t Type) Value {
+	ctx.Throw(NewTypeError(fmt.Sprintf("Cannot convert %s to %s", DebugType(v), typeName(t))))
+	return v
+}
+
+// coerce converts v to scalar type t as function arguments are converted in coercive typing mode.
+// Arrays and objects are not converted to scalars and non-numeric strings are not converted to numbers
+func coerce(ctx Context, v Value, t Type) (Value, bool) {
+	v = deref(v)
+
+	switch {
+	case v.Type() == t:
+		return v, true
+	case t == StringType && stringable(v):
+		return v.AsString(ctx), true
+	case t == ArrayType, t == ObjectType, v.Type() == ArrayType, v.Type() == ObjectType:
+		return v, false
+	case v.Type() == StringType && (t == IntType || t == FloatType):
+		n, ok := parseNumeric(v.(String))
+
+		if !ok {
+			return v, false
+		}
+
+		if f, ok := n.(Float); ok && t == IntType {
+			if f != Float(math.Trunc(float64(f))) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return v, false
+			}
+		}
+
+		return n.Cast(ctx, t), true
+	default:
+		return v.Cast(ctx, t), true
+	}
+}
+
+// parseNumeric converts numeric string s to Int or Float as PHP does. Leading and trailing whitespaces are
+// allowed, hexadecimal, infinite and NaN notations are not. Integers, which overflow, are converted to Float
+func parseNumeric(s String) (Value, bool) {
+	str := strings.Trim(string(s), " \t\n\r\v\f")
+	i, digits, integer := 0, 0, true
+
+	if i < len(str) && (str[i] == '+' || str[i] == '-') {
+		i++
+	}
+
+	for ; i < len(str) && str[i] >= '0' && str[i] <= '9'; i++ {
+		digits++
+	}
+
+	if i < len(str) && str[i] == '.' {
+		integer = false
+
+		for i++; i < len(str) && str[i] >= '0' && str[i] <= '9'; i++ {
+			digits++
+		}
+	}
+
+	if digits == 0 {
+		return nil, false
+	}
+
+	if i < len(str) && (str[i] == 'e' || str[i] == 'E') {
+		integer = false
+		i++
+
+		if i < len(str) && (str[i] == '+' || str[i] == '-') {
+			i++
+		}
+
+		if i == len(str) || str[i] < '0' || str[i] > '9' {
+			return nil, false
+		}
+
+		for ; i < len(str) && str[i] >= '0' && str[i] <= '9'; i++ {
+		}
+	}
+
+	if i != len(str) {
+		return nil, false
+	}
+
+	if integer {
+		if v, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return Int(v), true
+		}
+	}
+
+	v, _ := strconv.ParseFloat(str, 64)
+	return Float(v), true
+}
+
+// typeName returns name of type t as it is used in type declarations
+func typeName(t Type) string {
+	switch t {
+	case IntType:
+		return "int"
+	case BoolType:
+		return "bool"
+	default:
+		return t.String()
+	}
+}
+
+// deref returns value referenced by v, if v is a reference
+func deref(v Value) Value {
+	for v != nil && v.IsRef() {
+		v = *v.(Ref).Deref()
+	}
+
+	return v
+}
+
+// DebugType returns type name of v as it is shown in error messages
+func DebugType(v Value) string {
+	switch v := deref(v).(type) {
+	case nil, Null:
+		return "null"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case *Object:
+		// name of anonymous class is cut at NUL byte, which precedes its position
+		name, _, _ := strings.Cut(string(v.class.Name), "\x00")
+		return name
+	case *Enum:
+		return string(v.class.Name)
+	case *Closure:
+		return string(v.class.Name)
+	case *Generator:
+		return string(v.class.Name)
+	case *Fiber:
+		return string(v.class.Name)
+	default:
+		return v.Type().String()
+	}
+}
+
 type Countable interface {
 	Count(Context) Int
 }
@@ -51,15 +192,15 @@ type Value interface {
 
 type Int int
 
-func (i Int) IsRef() bool              { return false }
-func (i Int) Type() Type               { return IntType }
-func (i Int) AsInt(Context) Int        { return i }
-func (i Int) AsFloat(Context) Float    { return Float(i) }
-func (i Int) AsBool(Context) Bool      { return i != 0 }
-func (i Int) AsString(Context) String  { return String(strconv.Itoa(int(i))) }
-func (i Int) AsNull(Context) Null      { return Null{} }
-func (i Int) AsArray(Context) *Array   { return NewArray(map[Value]Value{String("scalar"): i}) }
-func (i Int) AsObject(Context) *Object { return &Object{props: map[String]Value{"scalar": i}} }
+func (i Int) IsRef() bool                  { return false }
+func (i Int) Type() Type                   { return IntType }
+func (i Int) AsInt(Context) Int            { return i }
+func (i Int) AsFloat(Context) Float        { return Float(i) }
+func (i Int) AsBool(Context) Bool          { return i != 0 }
+func (i Int) AsString(Context) String      { return String(strconv.Itoa(int(i))) }
+func (i Int) AsNull(Context) Null          { return Null{} }
+func (i Int) AsArray(Context) *Array       { return NewArray(map[Value]Value{String("scalar"): i}) }
+func (i Int) AsObject(ctx Context) *Object { return scalarObject(ctx, i) }
 func (i Int) Cast(ctx Context, t Type) Value {
 	switch t {
 	case IntType:
@@ -77,22 +218,22 @@ func (i Int) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return i.AsObject(ctx)
 	default:
-		panic(fmt.Errorf("cannot cast %s to %s", i.Type().String(), t.String()))
+		return invalidCast(ctx, i, t)
 	}
 }
 func (i Int) DebugInfo(Context) string { return fmt.Sprintf("int(%d)", i) }
 
 type Float float64
 
-func (f Float) IsRef() bool              { return false }
-func (f Float) Type() Type               { return FloatType }
-func (f Float) AsInt(Context) Int        { return Int(f) }
-func (f Float) AsFloat(Context) Float    { return f }
-func (f Float) AsBool(Context) Bool      { return f != 0 }
-func (f Float) AsString(Context) String  { return String(strconv.FormatFloat(float64(f), 'g', -1, 64)) }
-func (f Float) AsNull(Context) Null      { return Null{} }
-func (f Float) AsArray(Context) *Array   { return NewArray(map[Value]Value{String("scalar"): f}) }
-func (f Float) AsObject(Context) *Object { return &Object{props: map[String]Value{"scalar": f}} }
+func (f Float) IsRef() bool                  { return false }
+func (f Float) Type() Type                   { return FloatType }
+func (f Float) AsInt(Context) Int            { return Int(f) }
+func (f Float) AsFloat(Context) Float        { return f }
+func (f Float) AsBool(Context) Bool          { return f != 0 }
+func (f Float) AsString(Context) String      { return String(strconv.FormatFloat(float64(f), 'g', -1, 64)) }
+func (f Float) AsNull(Context) Null          { return Null{} }
+func (f Float) AsArray(Context) *Array       { return NewArray(map[Value]Value{String("scalar"): f}) }
+func (f Float) AsObject(ctx Context) *Object { return scalarObject(ctx, f) }
 func (f Float) Cast(ctx Context, t Type) Value {
 	switch t {
 	case IntType:
@@ -110,7 +251,7 @@ func (f Float) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return f.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", f.Type().String(), t.String()))
+		return invalidCast(ctx, f, t)
 	}
 }
 func (f Float) DebugInfo(Context) string { return fmt.Sprintf("float(%g)", f) }
@@ -133,11 +274,11 @@ func (b Bool) AsFloat(Context) Float {
 
 	return 0
 }
-func (b Bool) AsBool(Context) Bool      { return b }
-func (b Bool) AsString(Context) String  { return String(strconv.FormatBool(bool(b))) }
-func (b Bool) AsNull(Context) Null      { return Null{} }
-func (b Bool) AsArray(Context) *Array   { return NewArray(map[Value]Value{String("scalar"): b}) }
-func (b Bool) AsObject(Context) *Object { return &Object{props: map[String]Value{"scalar": b}} }
+func (b Bool) AsBool(Context) Bool          { return b }
+func (b Bool) AsString(Context) String      { return String(strconv.FormatBool(bool(b))) }
+func (b Bool) AsNull(Context) Null          { return Null{} }
+func (b Bool) AsArray(Context) *Array       { return NewArray(map[Value]Value{String("scalar"): b}) }
+func (b Bool) AsObject(ctx Context) *Object { return scalarObject(ctx, b) }
 func (b Bool) Cast(ctx Context, t Type) Value {
 	switch t {
 	case IntType:
@@ -155,7 +296,7 @@ func (b Bool) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return b.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", b.Type().String(), t.String()))
+		return invalidCast(ctx, b, t)
 	}
 }
 func (b Bool) DebugInfo(Context) string { return fmt.Sprintf("bool(%t)", b) }
@@ -186,11 +327,11 @@ func (s String) AsFloat(Context) Float {
 
 	return Float(v)
 }
-func (s String) AsBool(Context) Bool      { return len(s) > 0 && s != "0" }
-func (s String) AsString(Context) String  { return s }
-func (s String) AsNull(Context) Null      { return Null{} }
-func (s String) AsArray(Context) *Array   { return NewArray(map[Value]Value{String("scalar"): s}) }
-func (s String) AsObject(Context) *Object { return &Object{props: map[String]Value{"scalar": s}} }
+func (s String) AsBool(Context) Bool          { return len(s) > 0 && s != "0" }
+func (s String) AsString(Context) String      { return s }
+func (s String) AsNull(Context) Null          { return Null{} }
+func (s String) AsArray(Context) *Array       { return NewArray(map[Value]Value{String("scalar"): s}) }
+func (s String) AsObject(ctx Context) *Object { return scalarObject(ctx, s) }
 func (s String) Cast(ctx Context, t Type) Value {
 	switch t {
 	case IntType:
@@ -208,7 +349,7 @@ func (s String) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return s.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", s.Type().String(), t.String()))
+		return invalidCast(ctx, s, t)
 	}
 }
 func (s String) String() string           { return strconv.Quote(string(s)) }
@@ -216,15 +357,15 @@ func (s String) DebugInfo(Context) string { return fmt.Sprintf("string(%s)", s)
 
 type Null struct{}
 
-func (n Null) IsRef() bool              { return false }
-func (n Null) Type() Type               { return NullType }
-func (n Null) AsInt(Context) Int        { return 0 }
-func (n Null) AsFloat(Context) Float    { return 0 }
-func (n Null) AsBool(Context) Bool      { return false }
-func (n Null) AsString(Context) String  { return "" }
-func (n Null) AsNull(Context) Null      { return n }
-func (n Null) AsArray(Context) *Array   { return NewArray(nil) }
-func (n Null) AsObject(Context) *Object { return &Object{props: map[String]Value{}} }
+func (n Null) IsRef() bool                  { return false }
+func (n Null) Type() Type                   { return NullType }
+func (n Null) AsInt(Context) Int            { return 0 }
+func (n Null) AsFloat(Context) Float        { return 0 }
+func (n Null) AsBool(Context) Bool          { return false }
+func (n Null) AsString(Context) String      { return "" }
+func (n Null) AsNull(Context) Null          { return n }
+func (n Null) AsArray(Context) *Array       { return NewArray(nil) }
+func (n Null) AsObject(ctx Context) *Object { return NewObject(ctx, nil) }
 func (n Null) Cast(ctx Context, t Type) Value {
 	switch t {
 	case IntType:
@@ -242,7 +383,7 @@ func (n Null) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return n.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", n.Type().String(), t.String()))
+		return invalidCast(ctx, n, t)
 	}
 }
 func (n Null) DebugInfo(Context) string { return "NULL" }
@@ -279,10 +420,19 @@ func (a *Array) GetIterator(ctx Context) Iterator {
 func (a *Array) Count(Context) Int { return Int(len(a.hash)) }
 
 func (a *Array) Copy() *Array {
-	return &Array{
-		hash: maps.Clone(a.hash),
-		next: a.next,
+	hash := make(map[Value]Ref, len(a.hash))
+
+	for k, ref := range a.hash {
+		v := *ref.Deref()
+
+		if arr, ok := v.(*Array); ok {
+			v = arr.Copy()
+		}
+
+		hash[k] = NewRef(&v)
 	}
+
+	return &Array{hash: hash, next: a.next}
 }
 func (a *Array) access(key Value) (v Ref, ok bool) {
 	v, ok = a.hash[key]
@@ -312,6 +462,22 @@ func (a *Array) assign(ctx Context, key Value) Ref {
 }
 func (a *Array) delete(key Value) { delete(a.hash, key) }
 
+// bind makes element key of a the reference ref. Element is appended, if key is nil
+func (a *Array) bind(ctx Context, key Value, ref Ref) {
+	if key == nil {
+		key = a.NextKey()
+	}
+
+	a.assign(ctx, key)
+
+	switch key.Type() {
+	case IntType, FloatType:
+		key = key.AsInt(ctx)
+	}
+
+	a.hash[key] = ref
+}
+
 func NewArray(init map[Value]Value, next ...Int) *Array {
 	if len(next) == 0 {
 		next = []Int{math.MinInt}
@@ -364,13 +530,13 @@ func (a *Array) AsString(ctx Context) String {
 func (a *Array) AsNull(Context) Null    { return Null{} }
 func (a *Array) AsArray(Context) *Array { return a }
 func (a *Array) AsObject(ctx Context) *Object {
-	props := make(map[String]Value, len(a.hash))
+	obj := NewObject(ctx, nil)
 
-	for k, v := range a.hash {
-		props[k.AsString(ctx)] = v
+	for _, k := range a.Keys(ctx) {
+		obj.set(k.AsString(ctx), *a.hash[k].Deref())
 	}
 
-	return &Object{props: props}
+	return obj
 }
 func (a *Array) Cast(ctx Context, t Type) Value {
 	switch t {
@@ -389,7 +555,7 @@ func (a *Array) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return a.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", a.Type().String(), t.String()))
+		return invalidCast(ctx, a, t)
 	}
 }
 func (a *Array) NextKey() Value {
@@ -458,27 +624,59 @@ func (r Ref) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return r.AsObject(ctx)
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", r.Type().String(), t.String()))
+		return invalidCast(ctx, r, t)
 	}
 }
 func (r Ref) DebugInfo(ctx Context) string { return fmt.Sprintf("&%s", (*r.Deref()).DebugInfo(ctx)) }
 
 type Object struct {
-	props map[String]Value
+	class  *Class
+	props  map[String]Ref
+	keys   []String // properties in declaration order
+	id     int
+	guards map[String]magicGuard // magic methods running for properties
+}
+
+func NewObject(ctx Context, class *Class) *Object {
+	if class == nil {
+		class = stdClass
+	}
+
+	obj := &Object{class: class, props: make(map[String]Ref)}
+
+	if ctx != nil {
+		obj.id = ctx.Global().nextObjectId()
+	}
+
+	return obj
+}
+
+func scalarObject(ctx Context, v Value) *Object {
+	obj := NewObject(ctx, nil)
+	obj.set("scalar", v)
+	return obj
 }
 
-func (o *Object) IsRef() bool             { return false }
-func (o *Object) AsInt(Context) Int       { return 1 }
-func (o *Object) AsFloat(Context) Float   { return 1 }
-func (o *Object) AsBool(Context) Bool     { return true }
-func (o *Object) AsString(Context) String { panic("cannot be converted to string") }
-func (o *Object) AsNull(Context) Null     { return Null{} }
-func (o *Object) Type() Type              { return ObjectType }
+func (o *Object) Class() *Class         { return o.class }
+func (o *Object) IsRef() bool           { return false }
+func (o *Object) AsInt(Context) Int     { return 1 }
+func (o *Object) AsFloat(Context) Float { return 1 }
+func (o *Object) AsBool(Context) Bool   { return true }
+func (o *Object) AsString(ctx Context) String {
+	if s, ok := o.toString(ctx); ok {
+		return s
+	}
+
+	ctx.Throw(NewError(fmt.Sprintf("Object of class %s could not be converted to string", string(o.class.Name))))
+	return ""
+}
+func (o *Object) AsNull(Context) Null { return Null{} }
+func (o *Object) Type() Type          { return ObjectType }
 func (o *Object) AsArray(Context) *Array {
-	arr := make(map[Value]Value, len(o.props))
+	arr := make(map[Value]Value, len(o.keys))
 
-	for k, v := range o.props {
-		arr[k] = v
+	for _, k := range o.keys {
+		arr[k] = *o.props[k].Deref()
 	}
 
 	return NewArray(arr)
@@ -501,22 +699,50 @@ func (o *Object) Cast(ctx Context, t Type) Value {
 	case ObjectType:
 		return o
 	default:
-		panic(fmt.Sprintf("cannot cast %s to %s", o.Type().String(), t.String()))
+		return invalidCast(ctx, o, t)
 	}
 }
-func (o *Object) Keys() []String {
-	keys := make([]String, 0, len(o.props))
-	for k := range o.props {
-		keys = append(keys, k)
+func (o *Object) Keys() []String { return slices.Clone(o.keys) }
+func (o *Object) get(name String) (Ref, bool) {
+	ref, ok := o.props[name]
+	return ref, ok
+}
+func (o *Object) set(name String, v Value) Ref {
+	if ref, ok := o.props[name]; ok {
+		*ref.Deref() = v
+		return ref
+	}
+
+	ref := NewRef(&v)
+	o.props[name] = ref
+	o.keys = append(o.keys, name)
+	return ref
+}
+func (o *Object) unset(name String) {
+	if _, ok := o.props[name]; ok {
+		delete(o.props, name)
+		o.keys = slices.DeleteFunc(o.keys, func(key String) bool { return key == name })
 	}
-	return keys
 }
 func (o *Object) DebugInfo(ctx Context) string {
 	var str strings.Builder
-	str.WriteString(fmt.Sprintf("object(stdClass)#%d (%d) {", 1, len(o.props)))
+	str.WriteString(fmt.Sprintf("object(%s)#%d (%d) {", string(o.class.Name), o.id, len(o.keys)))
+
+	for _, key := range o.keys {
+		name := fmt.Sprintf("%v", key)
+
+		if i := slices.IndexFunc(o.class.Hidden, func(prop *Property) bool { return prop.hiddenKey() == key }); i >= 0 {
+			name = fmt.Sprintf("%v:%v:private", o.class.Hidden[i].Name, o.class.Hidden[i].Class.Name)
+		} else if prop, ok := o.class.Property(key); ok {
+			switch prop.Visibility {
+			case Protected:
+				name += ":protected"
+			case Private:
+				name += fmt.Sprintf(":%v:private", prop.Class.Name)
+			}
+		}
 
-	for _, key := range o.Keys() {
-		str.WriteString(stringIndent(fmt.Sprintf("\n[%v]=>\n%s\n", key, o.props[key].DebugInfo(ctx)), 2))
+		str.WriteString(stringIndent(fmt.Sprintf("\n[%s]=>\n%s", name, (*o.props[key].Deref()).DebugInfo(ctx)), 2))
 	}
 
 	str.WriteString("\n}")
@@ -524,6 +750,54 @@ func (o *Object) DebugInfo(ctx Context) string {
 	return str.String()
 }
 
+// object returns object of v, which is either an object or a case of enumeration
+func object(v Value) (*Object, bool) {
+	switch v := deref(v).(type) {
+	case *Object:
+		return v, true
+	case *Enum:
+		return v.Object, true
+	case *Closure:
+		return v.Object, true
+	case *Generator:
+		return v.Object, true
+	case *Fiber:
+		return v.Object, true
+	default:
+		return nil, false
+	}
+}
+
+// Enum is a case of enumeration. Every case is a single instance, so cases are compared by identity
+type Enum struct {
+	*Object
+
+	Case  String
+	Value Value // backing value, nil for cases of pure enumeration
+}
+
+func (e *Enum) AsString(ctx Context) String { return e.Object.AsString(ctx) }
+func (e *Enum) AsArray(Context) *Array {
+	arr := NewArray(nil)
+	arr.OffsetSet(nil, String("name"), e.Case)
+
+	if e.Value != nil {
+		arr.OffsetSet(nil, String("value"), e.Value)
+	}
+
+	return arr
+}
+func (e *Enum) Cast(ctx Context, t Type) Value {
+	if t == ObjectType {
+		return e
+	}
+
+	return e.Object.Cast(ctx, t)
+}
+func (e *Enum) DebugInfo(Context) string {
+	return fmt.Sprintf("enum(%s::%s)", string(e.class.Name), string(e.Case))
+}
+
 func stringIndent(str string, count int) string {
 	return strings.ReplaceAll(str, "\n", "\n"+strings.Repeat(" ", count))
 }
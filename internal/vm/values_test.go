@@ -141,6 +141,39 @@ func (t *StringTest) TestAsArray() {
 }
 func (t *StringTest) TestAsObject()  {}
 func (t *StringTest) TestDebugInfo() { t.Equal("string(\"\")", String("").DebugInfo(nil)) }
+func (t *StringTest) TestParseNumeric() {
+	cases := [...]struct {
+		value    String
+		expected Value
+	}{
+		{"5", Int(5)},
+		{" \t5\n", Int(5)},
+		{"-12", Int(-12)},
+		{"+.5", Float(.5)},
+		{"5.", Float(5)},
+		{"1e2", Float(100)},
+		{"1E-1 ", Float(.1)},
+		{"99999999999999999999", Float(1e20)},
+		{"", nil},
+		{" ", nil},
+		{".", nil},
+		{"1e", nil},
+		{"5x", nil},
+		{"5 5", nil},
+		{"0x1A", nil},
+		{"inf", nil},
+		{"NAN", nil},
+	}
+
+	for _, c := range cases {
+		v, ok := parseNumeric(c.value)
+		t.Equal(c.expected != nil, ok, c.value)
+
+		if ok {
+			t.Equal(c.expected, v, c.value)
+		}
+	}
+}
 
 type NullTest struct{ suite.Suite }
 
@@ -6,16 +6,26 @@ import (
 	"github.com/spf13/cobra"
 	"io"
 	"os"
+	"path/filepath"
 	"php-vm/internal/app"
 	"php-vm/internal/compiler"
 	"php-vm/internal/vm"
+	"slices"
 )
 
+// phpVersion is version of PHP targeted by the script, which is set by --php-version option
+var phpVersion string
+
 func init() {
-	app.App().AddCommand(&cobra.Command{
+	run := &cobra.Command{
 		Use: "run",
 		Run: func(cmd *cobra.Command, args []string) {
 			comp := app.App().Get((*compiler.Compiler)(nil)).(*compiler.Compiler)
+
+			if err := comp.SetVersion(phpVersion); err != nil {
+				panic(err)
+			}
+
 			file, err := os.Open(args[0])
 
 			if err != nil {
@@ -26,17 +36,29 @@ func init() {
 
 			parent, cancel := context.WithCancel(context.Background())
 			ctx := vm.NewGlobalContext(parent, cmd.InOrStdin(), cmd.OutOrStdout())
+			path, _ := filepath.Abs(args[0])
+			ctx.File = vm.String(path)
 			input, _ := io.ReadAll(file)
 			fn := comp.Compile(input, &ctx)
-			ctx.Run(fn)
+			err = ctx.Run(fn)
 			cancel()
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "PHP Fatal error:  %s\n", err.Error())
+				os.Exit(255)
+			}
 		},
-	})
+	}
 
-	app.App().AddCommand(&cobra.Command{
+	dump := &cobra.Command{
 		Use: "dump",
 		Run: func(cmd *cobra.Command, args []string) {
 			comp := app.App().Get((*compiler.Compiler)(nil)).(*compiler.Compiler)
+
+			if err := comp.SetVersion(phpVersion); err != nil {
+				panic(err)
+			}
+
 			file, err := os.Open(args[0])
 
 			if err != nil {
@@ -61,6 +83,31 @@ func init() {
 					fmt.Println(f.Instructions.String())
 				}
 			}
+
+			for _, class := range ctx.Classes {
+				names := make([]vm.String, 0, len(class.Methods))
+
+				for name, method := range class.Methods {
+					if method.Class == class {
+						names = append(names, name)
+					}
+				}
+
+				slices.Sort(names)
+
+				for _, name := range names {
+					switch f := class.Methods[name].Fn.(type) {
+					case vm.CompiledFunction:
+						fmt.Printf("%s::%s(args=%d, vars=%d)", string(class.Name), string(class.Methods[name].Name), f.Args, f.Vars)
+						fmt.Println(f.Instructions.String())
+					}
+				}
+			}
 		},
-	})
+	}
+
+	for _, cmd := range [...]*cobra.Command{run, dump} {
+		cmd.Flags().StringVar(&phpVersion, "php-version", compiler.LatestVersion, "version of PHP targeted by the script")
+		app.App().AddCommand(cmd)
+	}
 }
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/fcgi"
 	"os"
+	"path/filepath"
 	"php-vm/internal/app"
 	"php-vm/internal/compiler"
 	"php-vm/internal/vm"
@@ -50,12 +51,33 @@ func init() {
 
 				parent, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer cancel()
-				ctx := vm.NewGlobalContext(parent, nil, w)
+				out := &response{ResponseWriter: w}
+				ctx := vm.NewGlobalContext(parent, nil, out)
+				path, _ := filepath.Abs(file.Name())
+				ctx.File = vm.String(path)
 				fn := comp.Compile(input, &ctx)
-				ctx.Run(fn)
+
+				if err := ctx.Run(fn); err != nil {
+					fmt.Fprintf(os.Stderr, "PHP Fatal error:  %s\n", err.Error())
+					// status can be sent only before the output, which is not buffered
+					if !out.written {
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+				}
 			}))
 		},
 	}
 	cmd.PersistentFlags().String("addr", ":9000", "")
 	app.App().AddCommand(cmd)
 }
+
+// response records if the script has written its output, after which status of the response is already sent
+type response struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (r *response) Write(p []byte) (int, error) {
+	r.written = true
+	return r.ResponseWriter.Write(p)
+}
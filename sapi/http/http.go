@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"php-vm/internal/app"
 	"php-vm/internal/compiler"
 	"php-vm/internal/vm"
@@ -47,9 +48,19 @@ func init() {
 					input, _ := io.ReadAll(file)
 					_ = file.Close()
 
-					ctx := vm.NewGlobalContext(r.Context(), nil, w)
+					out := &response{ResponseWriter: w}
+					ctx := vm.NewGlobalContext(r.Context(), nil, out)
+					path, _ := filepath.Abs(file.Name())
+					ctx.File = vm.String(path)
 					fn := comp.Compile(input, &ctx)
-					ctx.Run(fn)
+
+					if err := ctx.Run(fn); err != nil {
+						fmt.Fprintf(os.Stderr, "PHP Fatal error:  %s\n", err.Error())
+						// status can be sent only before the output, which is not buffered
+						if !out.written {
+							w.WriteHeader(http.StatusInternalServerError)
+						}
+					}
 				}),
 			}
 
@@ -59,3 +70,14 @@ func init() {
 	cmd.PersistentFlags().String("addr", ":80", "")
 	app.App().AddCommand(cmd)
 }
+
+// response records if the script has written its output, after which status of the response is already sent
+type response struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (r *response) Write(p []byte) (int, error) {
+	r.written = true
+	return r.ResponseWriter.Write(p)
+}
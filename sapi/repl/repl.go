@@ -21,13 +21,14 @@ func start(in io.Reader, out io.Writer, comp *compiler.Compiler) {
 	parent, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ctx := vm.NewGlobalContext(parent, in, out)
+	ctx.File = "php shell code"
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		fn := comp.Compile(line, &ctx)
-		ctx.Run(fn)
-
-		if ctx.TopIndex() == 0 {
+		if err := ctx.Run(fn); err != nil {
+			fmt.Fprintf(out, "PHP Fatal error:  %s\n", err.Error())
+		} else if ctx.TopIndex() == 0 {
 			fmt.Fprintln(out, ctx.Pop())
 		}
 
@@ -36,12 +37,22 @@ func start(in io.Reader, out io.Writer, comp *compiler.Compiler) {
 }
 
 func init() {
-	app.App().AddCommand(&cobra.Command{
+	var phpVersion string
+
+	shell := &cobra.Command{
 		Use:   "shell",
 		Short: "sh",
 		Run: func(cmd *cobra.Command, args []string) {
 			comp := app.App().Get((*compiler.Compiler)(nil)).(*compiler.Compiler)
+
+			if err := comp.SetVersion(phpVersion); err != nil {
+				panic(err)
+			}
+
 			start(os.Stdin, os.Stdout, comp)
 		},
-	})
+	}
+
+	shell.Flags().StringVar(&phpVersion, "php-version", compiler.LatestVersion, "version of PHP targeted by the shell")
+	app.App().AddCommand(shell)
 }
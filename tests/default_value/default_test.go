@@ -15,7 +15,7 @@ func TestDefaultValue(t *testing.T) {
 	require.NoError(t, err)
 
 	instructions := [...]uint64{
-		uint64(vm.OpConst), 3,
+		uint64(vm.OpSkipArg),
 		uint64(vm.OpCall), 0,
 		uint64(vm.OpReturnValue),
 	}
@@ -5,6 +5,311 @@ import "testing"
 func TestBasic(t *testing.T) {
 	tests := [...]PhpT{
 		{Test: "Trivial \"Hello World\" test", File: "<?php echo \"Hello World\"?>", Expect: "Hello World"},
+		{
+			Test: "Number literals",
+			File: `<?php
+class Limits {
+    const MASK = 0xFF;
+    public $mode = 0o755;
+}
+echo 0x1A, " ", 017, " ", 0o17, " ", 0b101, " ", 1_000_000, " ", 1_0.5, " ", 1e3, " ", Limits::MASK, " ", (new Limits)->mode, ";";
+echo 9223372036854775808 === 9223372036854775808.0 ? "float" : "int", " ", 0x7FFFFFFFFFFFFFFF === 9223372036854775807 ? "int" : "float", " ";
+echo 0x8000000000000000 === 9223372036854775808.0 ? "float" : "int";`,
+			Expect: "26 15 15 5 1000000 10.5 1000 255 493;float int float",
+		},
+		{
+			Test: "Short-circuit evaluation",
+			File: `<?php
+function t($x) {
+    echo $x;
+    return $x;
+}
+function b($v) {
+    if ($v === true) {
+        return "T";
+    }
+    return "F";
+}
+echo b(t(1) && t(0)), b(t(0) && t(1)), b(t(0) || t(2)), b(t(3) || t(0)), ";";
+$a = false or true;
+echo b($a), b(true xor true), b(1 xor 0), b(t(0) and t(4)), b(t(5) or t(6)), ";";
+if (t(1) && (t(0) || t(2))) {
+    echo "ok";
+}`,
+			Expect: "100023FFTT;05FFTFT;102ok",
+		},
+		{
+			Test: "Null coalescing",
+			File: `<?php
+$a = ['x' => ['y' => 1, 'n' => null], 'z' => 0];
+echo $a['x']['y'] ?? 'd', $a['x']['n'] ?? 'd', $a['q']['y'] ?? 'd', $undefined ?? 'u', $a['z'] ?? 'd', ";";
+echo (int)isset($a['x']['y']), (int)isset($a['x']['n']), (int)isset($a['x'], $a['z']), (int)isset($a['x'], $a['w']), ";";
+$b = null;
+$b ??= 5;
+$b ??= 6;
+$a['k'] ??= 'new';
+$a['k'] ??= 'newer';
+echo $b, $a['k'], ";";
+class P {
+    public $p = 1;
+    private $secret = 2;
+    public $child;
+}
+$o = new P;
+$o->child = new P;
+echo $o->p ?? 'd', $o->undefined ?? 'd', $o->secret ?? 'd', $o->child->p ?? 'd', $o->child->child->p ?? 'd', $none->p ?? 'd', ";";
+$o->child ??= 'set';
+$o->undefined ??= 'set';
+echo $o->undefined;`,
+			Expect: "1ddu0;1010;5new;1dd1dd;set",
+		},
+		{
+			Test: "Null coalescing assignment evaluates operands once",
+			File: `<?php
+$arr = ["x", null, null];
+$i = 0;
+$arr[$i++] ??= "v";
+$arr[$i++] ??= "w";
+echo $i, $arr[0], $arr[1], $arr[2] ?? "-", ";";
+$calls = 0;
+function key_() {
+    global $calls;
+    $calls++;
+    return "k";
+}
+$z = [];
+$z[key_()] ??= 1;
+$z[key_()] ??= 2;
+echo $calls, $z["k"], ";";
+class O { public $p; public static $s; }
+$objs = [new O, new O];
+$j = 0;
+function obj() {
+    global $objs, $j;
+    return $objs[$j++];
+}
+obj()->p ??= "a";
+echo $j, $objs[0]->p, ";";
+$m = [[null]];
+$a = 0;
+$b = 0;
+$m[$a++][$b++] ??= "deep";
+echo $a, $b, $m[0][0], ";";
+function cls() {
+    global $calls;
+    $calls++;
+    return "O";
+}
+(cls())::$s ??= "s";
+echo $calls, O::$s;`,
+			Expect: "2xw-;21;1a;11deep;3s",
+		},
+		{
+			Test: "Ternary",
+			File: `<?php
+function size($x) {
+    return $x > 3 ? ($x > 4 ? "big" : "mid") : "small";
+}
+echo 1 ? "a" : "b", 0 ? "a" : "b", 0 ?: "c", 7 ?: "c", ";";
+echo size(5), size(4), size(1), ";";
+echo (true ? 1 : 2) + 1;`,
+			Expect: "abc7;bigmidsmall;2",
+		},
+		{
+			Test: "Global variables",
+			File: `<?php
+$count = 5;
+function inc() {
+    global $count, $created;
+    $count++;
+    $created = "new";
+}
+function local() {
+    $count = 100;
+    return $count;
+}
+inc();
+inc();
+local();
+echo $count, $created;`,
+			Expect: "7new",
+		},
+		{
+			Test: "Static variables",
+			File: `<?php
+function counter() {
+    static $n = 0, $calls = [];
+    $calls[] = ++$n;
+    return $n . (isset($calls[1]) ? "y" : "n");
+}
+function other() {
+    static $n = 10;
+    return $n++;
+}
+echo counter(), " ", counter(), " ", other(), " ", counter(), " ", other(), ";";
+class Id {
+    const FIRST = 100;
+    public static function next() {
+        static $id = self::FIRST;
+        return $id++;
+    }
+}
+echo Id::next(), Id::next(), ";";
+$f = function () {
+    static $s = "";
+    return $s .= "x";
+};
+echo $f(), ",", $f();`,
+			Expect: "1n 2y 10 3y 11;100101;x,xx",
+		},
+		{
+			Test: "Unset",
+			File: `<?php
+function state($set) {
+    return $set ? "set" : "unset";
+}
+$a = 1;
+$b = &$a;
+unset($b);
+$b = 2;
+echo $a, $b, ";";
+unset($a);
+echo state(isset($a)), " ", $a ?? "default", ";";
+$a++;
+echo $a, ";";
+$arr = ['x' => ['y' => 1, 'z' => 2], 'w' => 3];
+unset($arr['x']['y'], $arr['w'], $arr['missing']['key']);
+echo state(isset($arr['x']['y'])), " ", state(isset($arr['x']['z'])), " ", state(isset($arr['w'])), ";";
+class Point {
+    public $x = 1;
+    public $y = 2;
+    private $z = 3;
+    public function clear() {
+        unset($this->z);
+        return state(isset($this->z));
+    }
+}
+$p = new Point;
+unset($p->x);
+echo state(isset($p->x)), " ", $p->y, " ", $p->clear(), ";";
+function local() {
+    $x = 1;
+    unset($x);
+    return $x ?? "gone";
+}
+echo local();`,
+			Expect: "12;unset default;1;unset set unset;unset 2 unset;gone",
+		},
+		{
+			Test: "String interpolation",
+			File: `<?php
+class User {
+    public $name = "Ann";
+    public $address;
+}
+$user = new User;
+$user->address = new User;
+$user->address->name = "Main St";
+$name = "Bob";
+$row = ['name' => 'Eve', 0 => 'zero', 5 => 'five'];
+$i = 5;
+echo "Hello $name! {$row['name']} ${name} ${row['name']} $row[name] $row[0] $row[$i] {$user->address->name} $user->name;";
+echo "\$name \\ \" \x41\101\u{e9} \q;";
+$text = <<<EOT
+    Dear $name,
+      {$row['name']} says hi
+    EOT;
+echo $text, ";";
+echo <<<'EOT'
+    $name\n
+    EOT;`,
+			Expect: "Hello Bob! Eve Bob Eve Eve zero five Main St Ann;$name \\ \" AAé \\q;Dear Bob,\n  Eve says hi;$name\\n",
+		},
+		{
+			Test: "Destructuring",
+			File: `<?php
+$arr = [1, [2, 3, 4], 'k' => 'v'];
+[$a, [$b, , $c]] = $arr;
+echo $a, $b, $c, ";";
+['k' => $k, 0 => $z] = $arr;
+echo $k, $z, ";";
+list($x, list(, $y)) = $arr;
+echo $x, $y, ";";
+$nums = [1, 2];
+[&$r] = $nums;
+$r = 10;
+echo $nums[0], ";";
+foreach ([[1, 'a'], [2, 'b']] as $i => [$id, $name]) {
+    echo $i, $id, $name, " ";
+}
+foreach ([['id' => 7, 'tags' => ['x' => 'q']]] as ['id' => $id, 'tags' => ['x' => $tag]]) {
+    echo $id, $tag, ";";
+}
+[$a, $b] = [$b, $a];
+echo $a, $b, ";";
+echo ([$p, $q] = [8, 9])[1];`,
+			Expect: "124;v1;13;10;01a 12b 7q;21;9",
+		},
+		{
+			Test: "Destructuring by reference",
+			File: `<?php
+$rows = [[1, 2], [3, 4]];
+foreach ($rows as [&$first, $second]) {
+    $first *= 10;
+}
+echo $rows[0][0], $rows[0][1], $rows[1][0], ";";
+class Box { public $item; public $items = []; }
+$box = new Box;
+$src = [1, [2, 3], 4];
+[&$box->item, [, &$box->items['k']], &$list[]] = $src;
+$box->item = "a";
+$box->items['k'] = "b";
+$list[0] = "c";
+echo $src[0], $src[1][0], $src[1][1], $src[2], ";";
+[&$u] = $missing;
+$u = 7;
+[$v] = [8];
+echo $missing[0], $v, $rows[1][0];`,
+			Expect: "10230;a2bc;7830",
+		},
+		{
+			Test: "Assignment by reference",
+			File: `<?php
+class Box {
+    public $item = 1;
+    public $items = [];
+    public static $shared = 10;
+}
+$box = new Box;
+$item = &$box->item;
+$item = 2;
+$shared = &Box::$shared;
+$shared++;
+$key = &$box->items['k'];
+$key = "v";
+echo $box->item, Box::$shared, $box->items['k'], ";";
+$x = 1;
+$y = 2;
+$r = &$x;
+$r = &$y;
+$r = 3;
+echo $x, $y, ";";
+$list = [1];
+$box->item = &$list[0];
+Box::$shared = &$list[];
+$box->items['k'] = &$y;
+$list[0] = 5;
+$list[1] = 6;
+$y = 7;
+echo $box->item, Box::$shared, $box->items['k'], ($z = &$box->item), ";";
+try {
+    $n = null;
+    $p = &$n->p;
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "211v;13;5675;Attempt to modify property \"p\" on null",
+		},
 	}
 
 	for _, test := range &tests {
@@ -0,0 +1,655 @@
+package phpt
+
+import "testing"
+
+func TestClasses(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Properties and methods",
+			File: `<?php
+class Counter {
+    public $count = 0;
+    public $step;
+
+    function __construct($step) { $this->step = $step; }
+    function inc() { $this->count += $this->step; return $this; }
+}
+
+$c = new Counter(2);
+$c->inc()->inc();
+echo $c->count;`,
+			Expect: "4",
+		},
+		{
+			Test: "Property increment and decrement",
+			File: `<?php
+$o = new stdClass;
+$o->x = 1;
+$o->x++;
+++$o->x;
+echo $o->x--, $o->x;`,
+			Expect: "32",
+		},
+		{
+			Test: "Nested array property",
+			File: `<?php
+class Bag {
+    private $items = [];
+    function add($k, $v) { $this->items[$k][] = $v; }
+    function get($k, $i) { return $this->items[$k][$i]; }
+}
+
+$a = new Bag;
+$b = new Bag;
+$a->add("x", 1);
+$a->add("x", 2);
+$b->add("x", 3);
+echo $a->get("x", 1), $b->get("x", 0);`,
+			Expect: "23",
+		},
+		{
+			Test: "Inheritance",
+			File: `<?php
+class A {
+    protected $name = "A";
+    function name() { return $this->name; }
+    function hello() { return "Hello from " . $this->name(); }
+}
+
+class B extends A {
+    function name() { return "B:" . $this->name; }
+}
+
+$b = new B;
+echo $b->hello();`,
+			Expect: "Hello from B:A",
+		},
+		{
+			Test: "Private method of calling scope takes precedence",
+			File: `<?php
+class A {
+    private function who() { return "A"; }
+    function test() { return $this->who(); }
+}
+
+class B extends A {
+    public function who() { return "B"; }
+}
+
+echo (new B)->test(), (new B)->who();`,
+			Expect: "AB",
+		},
+		{
+			Test: "Method without return",
+			File: `<?php
+class A {
+    function nothing() {}
+}
+
+$a = new A;
+if ($a->nothing() === null) {
+    echo "null";
+}`,
+			Expect: "null",
+		},
+		{
+			Test: "Nullsafe operator",
+			File: `<?php
+class Node {
+    public $value;
+    public $next;
+    public function __construct($value, $next = null) {
+        $this->value = $value;
+        $this->next = $next;
+    }
+    public function next() {
+        return $this->next;
+    }
+}
+function arg() {
+    echo "evaluated;";
+    return 1;
+}
+$list = new Node(1, new Node(2));
+echo $list?->next?->value, ";", $list->next()?->next()?->value ?? "none", ";";
+$empty = null;
+echo $empty?->next->next()->value[arg()] === null ? "null" : "set", ";";
+echo $empty?->next(arg()) ?? "skipped";`,
+			Expect: "2;none;null;skipped",
+		},
+		{
+			Test: "Private properties redeclared by subclass",
+			File: `<?php
+class Base {
+    private $x = "base";
+    public function getBase() {
+        return $this->x;
+    }
+    public function setBase($x) {
+        $this->x = $x;
+    }
+}
+class Child extends Base {
+    private $x = "child";
+    public function getChild() {
+        return $this->x;
+    }
+}
+class Leaf extends Child {
+    public $x = "leaf";
+}
+$child = new Child();
+echo $child->getBase(), " ", $child->getChild(), ";";
+$child->setBase("changed");
+$copy = clone $child;
+echo $copy->getBase(), " ", $copy->getChild(), ";";
+$leaf = new Leaf();
+echo $leaf->getBase(), " ", $leaf->getChild(), " ", $leaf->x, ";";
+try {
+    echo $child->x;
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "base child;changed child;base child leaf;Cannot access private property Child::$x",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestStaticMembers(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Static property counter",
+			File: `<?php
+class Counter {
+    public static $count = 0;
+    static function inc() { return ++self::$count; }
+}
+
+Counter::inc();
+Counter::inc();
+Counter::$count += 10;
+echo Counter::$count;`,
+			Expect: "12",
+		},
+		{
+			Test: "Static property shared with child class",
+			File: `<?php
+class A { protected static $items = []; static function add($x) { static::$items[] = $x; return static::$items; } }
+class B extends A {}
+
+A::add(1);
+$items = B::add(2);
+echo $items[0], $items[1];`,
+			Expect: "12",
+		},
+		{
+			Test: "Class constants",
+			File: `<?php
+class A {
+    const X = 2;
+    const Y = self::X * 10 + B::Z;
+    public $v = self::Y . "!";
+}
+
+class B extends A { const Z = 1; }
+
+$a = new A;
+echo A::Y, " ", B::X, " ", $a->v;`,
+			Expect: "21 2 21!",
+		},
+		{
+			Test: "Constant expression operators",
+			File: `<?php
+class A {
+    const D = 7 / 2, E = 8 / 2, M = 7 % 3, P = 2 ** 10, F = 2 ** -1, R = 256 >> 4, X = 6 ^ 3, N = ~5;
+    const B = !0, AND = 1 && 0, OR = 0 || 2, XOR = true xor true;
+    const C = [1 == 1.0, 1 === 1.0, "a" != "b", 1 < 2, 3 <= 2, 2 > 1, 2 >= 3, 1 <=> 2];
+    const T = self::B ? "yes" : "no", S = 0 ?: "short", Q = null ?? "coalesce", L = [1, ...[2, 3], "k" => 4];
+    public $p = A::P % 1000;
+}
+echo A::D, ",", A::E, ",", A::M, ",", A::P, ",", A::F, ",", A::R, ",", A::X, ",", A::N, ";";
+echo A::B ? 1 : 0, A::AND ? 1 : 0, A::OR ? 1 : 0, A::XOR ? 1 : 0, ";";
+foreach (A::C as $c) {
+    echo $c === true ? "t" : ($c === false ? "f" : $c);
+}
+echo ";", A::T, " ", A::S, " ", A::Q, ";";
+foreach (A::L as $k => $v) {
+    echo "$k=$v ";
+}
+echo (new A)->p;`,
+			Expect: "3.5,4,1,1024,0.5,16,5,-6;1010;tfttftf-1;yes short coalesce;0=1 1=2 2=3 k=4 24",
+		},
+		{
+			Test: "Late static binding",
+			File: `<?php
+class Model {
+    static function create() { return new static(); }
+    static function name() { return static::class; }
+    function describe() { return self::class . "/" . static::class; }
+}
+
+class User extends Model {
+    static function name() { return "user:" . parent::name(); }
+}
+
+echo User::name(), " ", User::create()->describe(), " ", Model::class;`,
+			Expect: "user:User Model/User Model",
+		},
+		{
+			Test: "Parent method call keeps object",
+			File: `<?php
+class A {
+    protected $name = "A";
+    function hello() { return "hello " . $this->name; }
+}
+
+class B extends A {
+    protected $name = "B";
+    function hello() { return parent::hello() . "!"; }
+}
+
+echo (new B)->hello();`,
+			Expect: "hello B!",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Interface implementation",
+			File: `<?php
+interface HasName { const PREFIX = "name:"; function name(); }
+interface Greets extends HasName { function greet(HasName $who); }
+
+abstract class Base implements Greets {
+    function greet(HasName $who) { return static::PREFIX . $this->name() . " greets " . $who->name(); }
+}
+
+class Person extends Base {
+    private $n;
+    function __construct($n) { $this->n = $n; }
+    function name() { return $this->n; }
+}
+
+echo (new Person("a"))->greet(new Person("b"));`,
+			Expect: "name:a greets b",
+		},
+		{
+			Test: "Instanceof",
+			File: `<?php
+interface I {}
+class A implements I {}
+class B extends A {}
+
+$b = new B;
+$name = "I";
+echo (int)($b instanceof A), (int)($b instanceof I), (int)($b instanceof $name), (int)(new A instanceof B), (int)($b instanceof Missing), (int)(1 instanceof A);`,
+			Expect: "111000",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestTraits(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Trait members",
+			File: `<?php
+trait Counts {
+    private static $count = 0;
+    public $items = [1];
+    static function inc() { return ++self::$count; }
+    abstract function name();
+    function describe() { return $this->name() . ":" . $this->items[0]; }
+}
+
+class A { use Counts; function name() { return "a"; } }
+class B { use Counts; function name() { return "b"; } }
+
+echo A::inc(), A::inc(), B::inc(), " ", (new A)->describe(), " ", (new B)->describe();`,
+			Expect: "121 a:1 b:1",
+		},
+		{
+			Test: "Conflict resolution",
+			File: `<?php
+trait Hello { function hello() { return "Hello"; } function world() { return "World"; } }
+trait Hi { function hello() { return "Hi"; } }
+
+class Base { function hello() { return "Base"; } }
+
+class A extends Base {
+    use Hello, Hi { Hi::hello insteadof Hello; Hello::hello as protected hey; world as private; }
+    function both() { return $this->hey() . " " . $this->world(); }
+}
+
+$a = new A;
+echo $a->hello(), " ", $a->both();`,
+			Expect: "Hi Hello World",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Backed enum",
+			File: `<?php
+interface HasLabel { function label(); }
+
+enum Suit: string implements HasLabel {
+    case Hearts = "H";
+    case Spades = "S";
+    const Wild = self::Spades;
+
+    function label() {
+        if ($this === self::Hearts) { return "Red " . $this->name; }
+        return "Black " . $this->name;
+    }
+}
+
+$h = Suit::from("H");
+$cases = Suit::cases();
+echo $h->label(), " ", Suit::Wild->label(), " ", $cases[1]->value, " ";
+echo (int)($h === Suit::Hearts), (int)(Suit::tryFrom("X") === null), (int)($h instanceof HasLabel), (int)($h instanceof BackedEnum);`,
+			Expect: "Red Hearts Black Spades S 1111",
+		},
+		{
+			Test: "Backing value validation",
+			File: `<?php
+enum Status: int { case Draft = 0; case Published = 1; }
+
+echo Status::tryFrom("1")->name, " ", Status::tryFrom(5) === null ? "null" : "case", " ";
+try {
+    Status::tryFrom("abc");
+} catch (TypeError $e) {
+    echo $e->getMessage(), " ";
+}
+try {
+    Status::from(5);
+} catch (ValueError $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "Published null Status::tryFrom(): Argument #1 ($value) must be of type int, string given 5 is not a valid backing value for enum Status",
+		},
+		{
+			Test: "Pure enum",
+			File: `<?php
+enum Status { case Active; case Inactive; }
+
+$s = Status::Inactive;
+echo $s->name, (int)($s === Status::Inactive), (int)($s === Status::Active), (int)($s instanceof UnitEnum), (int)($s instanceof BackedEnum);`,
+			Expect: "Inactive1010",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestMagicMethods(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Property overloading",
+			File: `<?php
+class Bag {
+    private $data = [];
+    private $secret = "hidden";
+    public $name = "bag";
+    public function __get($n) { echo "get $n;"; return $this->data[$n] ?? null; }
+    public function __set($n, $v) { echo "set $n;"; $this->data[$n] = $v; }
+    public function __isset($n) { return isset($this->data[$n]); }
+    public function __unset($n) { echo "unset $n;"; unset($this->data[$n]); }
+}
+$b = new Bag;
+$b->x = 5;
+echo $b->x, ";", $b->secret ?? "null", ";", $b->name, ";";
+echo isset($b->x) ? "set" : "unset", ";";
+echo $b->x ?? "none", ";";
+unset($b->x);
+echo isset($b->x) ? "set" : "unset", ";", $b->y ?? "default";`,
+			Expect: "set x;get x;5;null;bag;set;get x;5;unset x;unset;default",
+		},
+		{
+			Test: "Property overloading guard",
+			File: `<?php
+class Lazy {
+    public function __get($n) {
+        echo "load $n;";
+        $this->$n = $n . $n;
+        return $this->$n;
+    }
+    public function __set($n, $v) { $this->$n = $v . "!"; }
+}
+$l = new Lazy;
+echo $l->a, ";", $l->a;`,
+			Expect: "load a;aa!;aa!",
+		},
+		{
+			Test: "Method overloading",
+			File: `<?php
+class Proxy {
+    public function __call($name, $args) { return "$name(" . ($args[0] ?? "") . ")"; }
+    public static function __callStatic($name, $args) { return "static $name(" . $args[0] . ")"; }
+    private function hidden() { return "hidden"; }
+}
+$p = new Proxy;
+echo $p->foo(1, 2), ";", $p->hidden(), ";", Proxy::bar(3), ";", [$p, "baz"](), ";", "Proxy::qux"(4);`,
+			Expect: "foo(1);hidden();static bar(3);baz();static qux(4)",
+		},
+		{
+			Test: "String conversion",
+			File: `<?php
+class Money {
+    private $amount;
+    public function __construct($amount) { $this->amount = $amount; }
+    public function __toString() { return $this->amount . " EUR"; }
+}
+function label(string $s) { return "[$s]"; }
+$m = new Money(5);
+echo $m, ";", "total: " . $m, ";", label($m), ";";
+class Plain {}
+try {
+    echo new Plain;
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "5 EUR;total: 5 EUR;[5 EUR];Object of class Plain could not be converted to string",
+		},
+		{
+			Test: "String conversion in interpolation",
+			File: `<?php
+class Name {
+    public function __toString() { return "name"; }
+}
+$n = new Name;
+$s = "x";
+$s .= $n;
+echo "$n;{$n}!", ";", $s;`,
+			Expect: "name;name!;xname",
+		},
+		{
+			Test: "Invokable object",
+			File: `<?php
+class Multiplier {
+    private $factor;
+    public function __construct($factor) { $this->factor = $factor; }
+    public function __invoke($x) { return $x * $this->factor; }
+}
+function apply(callable $f, $x) { return $f($x); }
+$double = new Multiplier(2);
+echo $double(4), ";", apply($double, 5), ";", Closure::fromCallable($double)(6);`,
+			Expect: "8;10;12",
+		},
+		{
+			Test: "Clone",
+			File: `<?php
+class Point { public $x = 1; public $tags = ["a"]; }
+class Shape {
+    public $origin;
+    public function __construct() { $this->origin = new Point; }
+    public function __clone() { $this->origin = clone $this->origin; }
+}
+$s = new Shape;
+$c = clone $s;
+$c->origin->x = 2;
+$c->origin->tags[] = "b";
+echo $s->origin->x, $s->origin->tags[1] ?? "-", $c->origin->x, $c->origin->tags[1] ?? "-", ";", $s === $c ? "same" : "copy", ";";
+try {
+    clone 1;
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "1-2b;copy;__clone method called on non-object",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestTypedProperties(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Constructor property promotion",
+			File: `<?php
+class Point {
+    public function __construct(public int $x, protected $y = 2, private ?string $label = null) {
+        $this->x *= 10;
+    }
+    public function describe() { return $this->x . "," . $this->y . "," . ($this->label ?? "none"); }
+}
+$p = new Point("1");
+echo $p->x === 10 ? "int" : "other", ";", $p->describe(), ";", (new Point(3, 4, "c"))->describe();`,
+			Expect: "int;10,2,none;30,4,c",
+		},
+		{
+			Test: "Typed properties",
+			File: `<?php
+class User {
+    public int $id;
+    public ?string $name = null;
+    public float $score = 0;
+    public static float $max = 10;
+}
+$u = new User;
+echo $u->score === 0.0 && User::$max === 10.0 ? "float" : "other", ";";
+try {
+    echo $u->id;
+} catch (Error $e) {
+    echo $e->getMessage(), ";";
+}
+echo isset($u->id) ? "set" : "unset", ";";
+$u->id = "42";
+$u->score = 3;
+echo $u->id === 42 ? "int" : "other", ";", $u->score === 3.0 ? "float" : "other", ";";
+try {
+    $u->id = "abc";
+} catch (TypeError $e) {
+    echo $e->getMessage(), ";";
+}
+unset($u->id);
+try {
+    echo $u->id;
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "float;Typed property User::$id must not be accessed before initialization;unset;int;float;" +
+				"Cannot assign string to property User::$id of type int;Typed property User::$id must not be accessed before initialization",
+		},
+		{
+			Test: "Typed properties in strict mode",
+			File: `<?php
+declare(strict_types=1);
+class Counter { public int $count = 0; public float $ratio = 0.0; }
+$c = new Counter;
+$c->ratio = 1;
+echo $c->ratio === 1.0 ? "float" : "other", ";";
+try {
+    $c->count = "1";
+} catch (TypeError $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "float;Cannot assign string to property Counter::$count of type int",
+		},
+		{
+			Test: "Readonly properties",
+			File: `<?php
+class Order {
+    public readonly array $items;
+    public function __construct(public readonly int $id) {}
+    public function fill() { $this->items = [1]; }
+    public function add() { $this->items[] = 2; }
+}
+$o = new Order(7);
+$errors = [
+    fn() => $o->id = 8,
+    fn() => $o->items = [],
+    fn() => $o->fill(),
+    fn() => $o->add(),
+    function () use ($o) { unset($o->id); }
+];
+foreach ($errors as $f) {
+    try {
+        $f();
+        echo "ok;";
+    } catch (Error $e) {
+        echo $e->getMessage(), ";";
+    }
+}
+echo $o->id, $o->items[0];`,
+			Expect: "Cannot modify readonly property Order::$id;Cannot initialize readonly property Order::$items from global scope;ok;" +
+				"Cannot modify readonly property Order::$items;Cannot unset readonly property Order::$id;71",
+		},
+		{
+			Test: "Anonymous classes",
+			File: `<?php
+interface Named { public function name(); }
+class Base { public function __construct(public $v = 0) {} }
+function make($v) {
+    return new class($v) extends Base implements Named {
+        public function name() { return "anon" . $this->v; }
+    };
+}
+$a = make(1);
+$b = make(2);
+$c = new class {
+    public $v = 3;
+    public function inner() {
+        return new class { public $w = 4; };
+    }
+};
+echo $a->name(), $b->name(), $c->v, $c->inner()->w, " ";
+echo $a instanceof Named && $a instanceof Base ? "yes" : "no", " ";
+echo $a instanceof $b ? "same" : "other", " ", $a instanceof $c ? "same" : "other", " ";
+function f(int $x) {}
+try {
+    f($c);
+} catch (TypeError $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "anon1anon234 yes same other f(): Argument #1 ($x) must be of type int, class@anonymous given",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
@@ -0,0 +1,175 @@
+package phpt
+
+import "testing"
+
+func TestClosures(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Captured by value",
+			File: `<?php
+$y = 10;
+$add = function ($x) use ($y) { return $x + $y; };
+$y = 100;
+echo $add(5);`,
+			Expect: "15",
+		},
+		{
+			Test: "Captured by reference",
+			File: `<?php
+$n = 0;
+$inc = function () use (&$n) { $n++; };
+$inc();
+$inc();
+echo $n;`,
+			Expect: "2",
+		},
+		{
+			Test: "Reference outlives the frame",
+			File: `<?php
+function counter() {
+    $count = 0;
+    return function () use (&$count) { return ++$count; };
+}
+$next = counter();
+$next();
+$next();
+echo $next();`,
+			Expect: "3",
+		},
+		{
+			Test: "Arrow functions",
+			File: `<?php
+$factor = 3;
+$mul = fn($x) => $x * $factor;
+$compose = fn($f, $g) => fn($x) => $f($g($x));
+$h = $compose($mul, fn($x) => $x + 1);
+echo $mul(2), " ", $h(1);`,
+			Expect: "6 6",
+		},
+		{
+			Test: "Bound $this",
+			File: `<?php
+class Greeter {
+    private $name = "world";
+    function greeting() {
+        return function ($greeting) { return $greeting . ", " . $this->name; };
+    }
+}
+$g = (new Greeter)->greeting();
+echo $g("Hello");`,
+			Expect: "Hello, world",
+		},
+		{
+			Test: "Callbacks",
+			File: `<?php
+class Math {
+    static function square($x) { return $x * $x; }
+    function half($x) { return $x / 2; }
+}
+function apply($f, $v) { return $f($v); }
+echo apply(fn($v) => $v + 1, 1), apply("Math::square", 3), apply(["Math", "square"], 2), apply([new Math, "half"], 8), (function () { return "!"; })();`,
+			Expect: "2944!",
+		},
+		{
+			Test: "Closure is an object",
+			File: `<?php
+$f = function () {};
+echo (int)($f instanceof Closure);
+try {
+    $x = 1;
+    $x();
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "1Value not callable",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestClosureBinding(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Bind to object and scope",
+			File: `<?php
+class Counter {
+    private $count = 5;
+}
+$peek = function () { return $this->count; };
+$c = new Counter;
+$bound = Closure::bind($peek, $c, Counter::class);
+$boundTo = $peek->bindTo($c, $c);
+echo $bound(), $boundTo();
+try {
+    $unscoped = $peek->bindTo($c);
+    $unscoped();
+} catch (Error $e) {
+    echo " ", $e->getMessage();
+}`,
+			Expect: "55 Cannot access private property Counter::$count",
+		},
+		{
+			Test: "Call with new $this",
+			File: `<?php
+class Point {
+    private $x = 1;
+    private $y = 2;
+}
+$sum = function ($z) { return $this->x + $this->y + $z; };
+echo $sum->call(new Point, 3), $sum->call(new Point, 4);`,
+			Expect: "67",
+		},
+		{
+			Test: "From callable",
+			File: `<?php
+class Greeter {
+    private $greeting = "Hello";
+    function greet($name) { return $this->greeting . ", " . $name; }
+    static function shout($s) { return $s . "!"; }
+    private function secret() { return "secret"; }
+    function reveal() { return Closure::fromCallable([$this, "secret"]); }
+}
+$g = new Greeter;
+$greet = Closure::fromCallable([$g, "greet"]);
+$shout = Closure::fromCallable("Greeter::shout");
+function wrap($s) { return "[" . $s . "]"; }
+$wrap = Closure::fromCallable("wrap");
+$reveal = $g->reveal();
+echo $greet("world"), " ", $shout("hi"), " ", $wrap("abc"), " ", $reveal();`,
+			Expect: "Hello, world hi! [abc] secret",
+		},
+		{
+			Test: "First-class callable syntax",
+			File: `<?php
+class Math {
+    private $base = 10;
+    function add($x) { return $this->base + $x; }
+    static function double($x) { return $x * 2; }
+}
+$m = new Math;
+$add = $m->add(...);
+$double = Math::double(...);
+function upper($s) { return $s . $s; }
+$upper = upper(...);
+$f = fn($x) => $x . "!";
+$g = $f(...);
+echo $add(1), " ", $double(4), " ", $upper("abc"), " ", $g("hi");`,
+			Expect: "11 8 abcabc hi!",
+		},
+		{
+			Test: "Static closure can not be bound",
+			File: `<?php
+$f = static function () { return 1; };
+$bound = $f->bindTo(new stdClass);
+echo (int)($bound instanceof Closure);`,
+			Expect: "0",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
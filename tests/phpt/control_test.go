@@ -0,0 +1,203 @@
+package phpt
+
+import "testing"
+
+func TestControlFlow(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Switch",
+			File: `<?php
+function name($x) {
+    switch ($x) {
+        case 1:
+            return "one";
+        case 2:
+        case 3:
+            $r = "two-three";
+            break;
+        case "4":
+            $r = "four";
+            break;
+        default:
+            $r = "other";
+    }
+    return $r;
+}
+echo name(1), ";", name(3), ";", name(4), ";", name("1"), ";", name(9);`,
+			Expect: "one;two-three;four;one;other",
+		},
+		{
+			Test: "Switch with jump table",
+			File: `<?php
+function dense($x) {
+    switch ($x) {
+        case 0:
+            echo "zero";
+            break;
+        case 1:
+            echo "one";
+        case 2:
+            echo "two";
+            break;
+        case 3:
+            echo "three";
+            break;
+        default:
+            echo "default";
+            break;
+        case 4:
+            echo "four";
+            break;
+    }
+    echo ";";
+}
+function color($c) {
+    switch ($c) {
+        case "red": return 1;
+        case "green": return 2;
+        case "blue": return 3;
+        case "cyan": return 4;
+        case "magenta": return 5;
+        case "red": return 6;
+    }
+    return 0;
+}
+dense(0);
+dense(1);
+dense(4);
+dense(7);
+dense("2");
+dense(3.0);
+dense(true);
+echo color("red"), color("magenta"), color("black"), color(5), ";";
+function number($n) {
+    switch ($n) {
+        case "1": return "one";
+        case "01": return "zero one";
+        case "10": return "ten";
+        case "abc": return "abc";
+        case "x": return "x";
+    }
+    return "none";
+}
+echo number("01"), ",", number("1e1"), ",", number(" 10"), ",", number(10), ",", number("1.0"), ",", number("abc"), ",", number("10x");`,
+			Expect: "zero;onetwo;four;default;two;three;onetwo;1500;one,ten,ten,ten,one,abc,none",
+		},
+		{
+			Test: "Match",
+			File: `<?php
+function size($v) {
+    return match ($v) {
+        1, 2 => "small",
+        3 => "three",
+        default => "large",
+    };
+}
+echo size(1), size(3), size(10), size("3"), ";";
+$v = 3;
+echo match (true) {
+    $v < 2 => "a",
+    $v < 5 => "b",
+    default => "c",
+}, ";";
+try {
+    echo match ($v) {
+        "3" => "string",
+    };
+} catch (UnhandledMatchError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    echo match ("it's") {};
+} catch (Error $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    echo match (1.5) {};
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "smallthreelargelarge;b;Unhandled match case 3;Unhandled match case 'it\\'s';Unhandled match case of type float",
+		},
+		{
+			Test: "Break and continue",
+			File: `<?php
+for ($i = 0; $i < 10; $i++) {
+    if ($i % 2) {
+        continue;
+    }
+    if ($i > 6) {
+        break;
+    }
+    echo $i;
+}
+echo ";";
+$i = 0;
+while (true) {
+    if (++$i > 3) {
+        break;
+    }
+    echo $i;
+}
+echo ";";
+$i = 0;
+do {
+    $i++;
+    if ($i == 2) {
+        continue;
+    }
+    echo $i;
+} while ($i < 4);`,
+			Expect: "0246;123;134",
+		},
+		{
+			Test: "Break and continue with levels",
+			File: `<?php
+function find($rows, $needle) {
+    foreach ($rows as $i => $row) {
+        foreach ($row as $j => $v) {
+            if ($v === $needle) {
+                break 2;
+            }
+        }
+    }
+    return $i . $j;
+}
+echo find([[1, 2], [3, 4]], 3), ";";
+foreach ([1, 2, 3] as $a) {
+    foreach ([1, 2, 3] as $b) {
+        if ($b > $a) {
+            continue 2;
+        }
+        echo $a, $b, " ";
+    }
+}
+echo ";";
+for ($i = 0; $i < 3; $i++) {
+    switch ($i) {
+        case 1:
+            continue 2;
+        default:
+            echo $i;
+    }
+    echo "-";
+}
+echo ";";
+$i = 0;
+while (true) {
+    switch ($i++) {
+        case 2:
+            break 2;
+        default:
+            echo $i;
+            continue;
+    }
+}`,
+			Expect: "10;11 21 22 31 32 33 ;0-2-;12",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
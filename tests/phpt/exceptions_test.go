@@ -0,0 +1,260 @@
+package phpt
+
+import "testing"
+
+func TestExceptions(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Catch thrown exception",
+			File: `<?php
+try {
+    echo "a";
+    throw new Exception("boom", 3);
+    echo "b";
+} catch (Exception $e) {
+    echo $e->getMessage(), $e->getCode();
+}`,
+			Expect: "aboom3",
+		},
+		{
+			Test: "Multi-catch and clause order",
+			File: `<?php
+class NotFound extends Exception {}
+try {
+    throw new NotFound("x");
+} catch (RuntimeException | NotFound $e) {
+    echo "first";
+} catch (Exception $e) {
+    echo "second";
+}`,
+			Expect: "first",
+		},
+		{
+			Test: "Unwinding across functions",
+			File: `<?php
+function inner($x) { throw new Exception("deep " . $x); }
+function outer($x) { return 1 + inner($x); }
+try {
+    echo outer(1);
+} catch (Exception $e) {
+    echo $e->getMessage();
+}
+echo " done";`,
+			Expect: "deep 1 done",
+		},
+		{
+			Test: "Finally order",
+			File: `<?php
+function f() {
+    try {
+        echo "try ";
+        throw new Exception("e");
+    } catch (Exception $e) {
+        echo "catch ";
+    } finally {
+        echo "finally ";
+    }
+    echo "after";
+}
+f();`,
+			Expect: "try catch finally after",
+		},
+		{
+			Test: "Return through finally",
+			File: `<?php
+function f() {
+    try {
+        return "value";
+    } finally {
+        echo "finally ";
+    }
+}
+echo f();`,
+			Expect: "finally value",
+		},
+		{
+			Test: "Finally rethrows to outer handler",
+			File: `<?php
+try {
+    try {
+        throw new Exception("inner");
+    } finally {
+        echo "finally ";
+    }
+} catch (Exception $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "finally inner",
+		},
+		{
+			Test: "Continue through finally",
+			File: `<?php
+for ($i = 0; $i < 3; $i++) {
+    try {
+        if ($i == 1) { continue; }
+        echo $i;
+    } finally {
+        echo "f";
+    }
+}`,
+			Expect: "0ff2f",
+		},
+		{
+			Test: "Break through nested finally",
+			File: `<?php
+foreach ([1, 2] as $v) {
+    try {
+        try {
+            echo $v;
+            break;
+        } finally {
+            echo "F";
+        }
+    } finally {
+        echo "F";
+    }
+}`,
+			Expect: "1FF",
+		},
+		{
+			Test: "Continue out of switch through finally",
+			File: `<?php
+foreach ([1, 2] as $v) {
+    try {
+        switch ($v) {
+            case 1:
+                continue 2;
+            default:
+                echo "v$v";
+        }
+    } finally {
+        echo "F";
+    }
+}
+echo "!";`,
+			Expect: "Fv2F!",
+		},
+		{
+			Test: "Catch inside loop",
+			File: `<?php
+foreach ([1, 2, 3] as $i) {
+    try {
+        if ($i == 2) { throw new Exception("skip"); }
+        echo $i;
+    } catch (Exception $e) {
+        echo $e->getMessage();
+    }
+}`,
+			Expect: "1skip3",
+		},
+		{
+			Test: "Exception subclass",
+			File: `<?php
+class HttpException extends Exception {
+    public function __construct($status, $previous = null) {
+        parent::__construct("HTTP " . $status, $status, $previous);
+    }
+}
+$cause = new Exception("cause");
+try {
+    throw new HttpException(404, $cause);
+} catch (Exception $e) {
+    echo $e->getMessage(), " ", $e->getCode(), " ", $e->getPrevious()->getMessage();
+}`,
+			Expect: "HTTP 404 404 cause",
+		},
+		{
+			Test: "Exception location and trace",
+			File: `<?php
+function check($x) {
+    if ($x < 0) {
+        throw new InvalidArgumentException("negative");
+    }
+}
+class Account {
+    public function withdraw($amount) {
+        check(0 - $amount);
+    }
+    public static function open() {
+        (new Account)->withdraw(5);
+    }
+}
+try {
+    Account::open();
+} catch (LogicException $e) {
+    $trace = $e->getTrace();
+    echo $e instanceof RuntimeException ? "runtime" : "logic", " ", $e->getLine(), " ", isset($trace[2]) && !isset($trace[3]) ? 3 : "?", " ";
+    echo $trace[1]["class"], $trace[1]["type"], $trace[1]["function"], ";", $e->getTraceAsString(), ";";
+}
+try {
+    echo 1 % 0;
+} catch (DivisionByZeroError $e) {
+    echo $e->getLine(), " ", $e->getTrace() == [] ? "main" : "?", " ", $e->getTraceAsString();
+}`,
+			Expect: "logic 4 3 Account->withdraw;#0 (9): check()\n#1 (12): Account->withdraw()\n#2 (16): Account::open()\n#3 {main};23 main #0 {main}",
+		},
+		{
+			Test: "Uncaught exception",
+			File: `<?php
+echo "before ";
+throw new Exception("oops");
+echo "after";`,
+			Expect: "before PHP Fatal error:  Uncaught Exception: oops",
+		},
+		{
+			Test: "Throw non-throwable",
+			File: `<?php
+try {
+    throw new stdClass;
+} catch (Exception $e) {
+    echo "caught";
+}`,
+			Expect: "PHP Fatal error:  Uncaught Error: Cannot throw objects that do not implement Throwable",
+		},
+		{
+			Test: "Engine errors",
+			File: `<?php
+enum Suit: string { case Hearts = "H"; }
+function typed(int $x) { return $x; }
+try { echo 1 / 0; } catch (DivisionByZeroError $e) { echo "A:", $e->getMessage(), "\n"; }
+try { echo 5 % 0; } catch (ArithmeticError $e) { echo "B:", $e->getMessage(), "\n"; }
+try { typed([1]); } catch (TypeError $e) { echo "C:", $e->getMessage(), "\n"; }
+try { $o = new stdClass; $o->missing(); } catch (Error $e) { echo "D:", $e->getMessage(), "\n"; }
+try { echo new stdClass; } catch (Error $e) { echo "E:", $e->getMessage(), "\n"; }
+try { Suit::from("X"); } catch (ValueError $e) { echo "F:", $e->getMessage(), "\n"; }
+try { throw new TypeError("t"); } catch (Exception $e) { echo "no"; } catch (Throwable $e) { echo "G:", $e->getMessage(), "\n"; }
+$x = 10;
+try { $x /= 0; } catch (DivisionByZeroError $e) { echo "H:", $x, "\n"; }
+try { echo [] + 1; } catch (TypeError $e) { echo "I:", $e->getMessage(), "\n"; }
+try { $x -= new stdClass; } catch (TypeError $e) { echo "J:", $e->getMessage(), " ", $x, "\n"; }
+try { echo 1 << [2]; } catch (TypeError $e) { echo "K:", $e->getMessage(), "\n"; }
+try { new Exception([]); } catch (TypeError $e) { echo "L:", $e->getMessage(), "\n"; }
+echo count_([1] + [2, 3]), "\n";
+function count_($a) { return $a[0] . $a[1]; }`,
+			Expect: `A:Division by zero
+B:Modulo by zero
+C:typed(): Argument #1 ($x) must be of type int, array given
+D:Call to undefined method stdClass::missing()
+E:Object of class stdClass could not be converted to string
+F:"X" is not a valid backing value for enum Suit
+G:t
+H:10
+I:Unsupported operand types: array + int
+J:Unsupported operand types: int - stdClass 10
+K:Unsupported operand types: int << array
+L:Exception::__construct(): Argument #1 ($message) must be of type string, array given
+13
+`,
+		},
+		{
+			Test: "Uncaught engine error",
+			File: `<?php
+echo 1 % 0;`,
+			Expect: "PHP Fatal error:  Uncaught DivisionByZeroError: Modulo by zero",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
@@ -0,0 +1,253 @@
+package phpt
+
+import (
+	"bytes"
+	"context"
+	"php-vm/internal/compiler"
+	"php-vm/internal/vm"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFibers(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Start, suspend and resume",
+			File: `<?php
+$fiber = new Fiber(function ($a, $b) {
+    echo "start ", $a + $b, ";";
+    $x = Fiber::suspend("first");
+    echo "resumed ", $x, ";";
+    $y = Fiber::suspend("second");
+    echo "resumed ", $y, ";";
+    return $x . $y;
+});
+echo $fiber->start(1, 2), ";";
+echo $fiber->resume("a"), ";";
+$fiber->resume("b");
+echo $fiber->getReturn();`,
+			Expect: "start 3;first;resumed a;second;resumed b;ab",
+		},
+		{
+			Test: "Suspend from nested calls",
+			File: `<?php
+function fetch($id) {
+    return Fiber::suspend($id) * 10;
+}
+function load($ids) {
+    $sum = 0;
+    foreach ($ids as $id) {
+        $sum += fetch($id);
+    }
+    return $sum;
+}
+$fiber = new Fiber('load');
+$id = $fiber->start([1, 2, 3]);
+while (!$fiber->isTerminated()) {
+    echo $id, " ";
+    $id = $fiber->resume($id);
+}
+echo $fiber->getReturn();`,
+			Expect: "1 2 3 60",
+		},
+		{
+			Test: "State",
+			File: `<?php
+$fiber = new Fiber(function () {
+    Fiber::suspend();
+});
+echo (int)$fiber->isStarted();
+$fiber->start();
+echo (int)$fiber->isStarted(), (int)$fiber->isSuspended(), (int)$fiber->isRunning(), (int)$fiber->isTerminated();
+$fiber->resume();
+echo (int)$fiber->isSuspended(), (int)$fiber->isTerminated();
+echo (int)(Fiber::getCurrent() === null);`,
+			Expect: "01100011",
+		},
+		{
+			Test: "Nested fibers",
+			File: `<?php
+$outer = new Fiber(function () {
+    $inner = new Fiber(function () {
+        $v = Fiber::suspend("inner");
+        echo "inner got ", $v, ";";
+    });
+    echo "outer got ", $inner->start(), ";";
+    $v = Fiber::suspend("outer");
+    $inner->resume($v);
+    return $inner->isTerminated();
+});
+echo "main got ", $outer->start(), ";";
+$outer->resume("x");
+echo (int)$outer->getReturn();`,
+			Expect: "outer got inner;main got outer;inner got x;1",
+		},
+		{
+			Test: "Exceptions",
+			File: `<?php
+$fiber = new Fiber(function () {
+    try {
+        Fiber::suspend();
+    } catch (Exception $e) {
+        echo "caught ", $e->getMessage(), ";";
+    }
+    throw new LogicException("out");
+});
+class LogicException extends Exception {}
+$fiber->start();
+try {
+    $fiber->throw(new Exception("in"));
+} catch (LogicException $e) {
+    echo "rethrown ", $e->getMessage(), ";";
+}
+try {
+    $fiber->getReturn();
+} catch (FiberError $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "caught in;rethrown out;Cannot get fiber return value: The fiber threw an exception",
+		},
+		{
+			Test: "Errors",
+			File: `<?php
+$fiber = new Fiber(fn() => 1);
+try {
+    $fiber->getReturn();
+} catch (FiberError $e) {
+    echo $e->getMessage(), ";";
+}
+$fiber->start();
+try {
+    $fiber->start();
+} catch (FiberError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    $fiber->resume();
+} catch (FiberError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    Fiber::suspend();
+} catch (FiberError $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "Cannot get fiber return value: The fiber has not been started;" +
+				"Cannot start a fiber that has already been started;" +
+				"Cannot resume a fiber that is not suspended;" +
+				"Cannot suspend outside of fiber",
+		},
+		{
+			Test: "Generator inside fiber",
+			File: `<?php
+function numbers() {
+    $x = yield 1;
+    Fiber::suspend($x);
+    yield 2;
+}
+$fiber = new Fiber(function () {
+    $gen = numbers();
+    echo $gen->current(), ";";
+    $gen->send("sent");
+    echo $gen->current(), ";";
+});
+echo $fiber->start(), ";";
+$fiber->resume();`,
+			Expect: "1;sent;2;",
+		},
+		{
+			Test: "Stack overflow",
+			File: `<?php
+function depth($n) {
+    return $n == 0 ? 0 : 1 + depth($n - 1);
+}
+function spread(...$args) {
+    return 1;
+}
+$args = [];
+for ($i = 0; $i < 1000; $i++) {
+    $args[] = $i;
+}
+$fiber = new Fiber(function () use ($args) {
+    try {
+        depth(100000);
+    } catch (Error $e) {
+        echo $e->getMessage(), ";";
+    }
+    try {
+        spread(...$args);
+    } catch (Error $e) {
+        echo $e->getMessage(), ";";
+    }
+    echo depth(100), ";";
+    Fiber::suspend();
+});
+$fiber->start();
+try {
+    depth(100000);
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "Maximum call stack size reached. Infinite recursion?;" +
+				"Maximum call stack size reached. Infinite recursion?;100;" +
+				"Maximum call stack size reached. Infinite recursion?",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
+
+func TestAbandonedFibers(t *testing.T) {
+	goroutines := func(want int) int {
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			runtime.GC()
+
+			if n := runtime.NumGoroutine(); n <= want || time.Now().After(deadline) {
+				return n
+			}
+		}
+	}
+	base := runtime.NumGoroutine()
+	parent, cancel := context.WithCancel(context.Background())
+	output := bytes.NewBuffer(nil)
+	ctx := vm.NewGlobalContext(parent, nil, output)
+	fn := compiler.NewCompiler(nil).Compile([]byte(`<?php
+for ($i = 0; $i < 100; $i++) {
+    $fiber = new Fiber(function () {
+        Fiber::suspend();
+    });
+    $fiber->start();
+}
+function cycle() {
+    $fiber = new Fiber(function () use (&$fiber) {
+        Fiber::suspend($fiber);
+    });
+    $fiber->start();
+}
+cycle();
+echo "done";`), &ctx)
+
+	if err := ctx.Run(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	if output.String() != "done" {
+		t.Fatalf("unexpected output %q", output.String())
+	}
+
+	// the last fiber is referenced by the script, the fiber of cycle() is referenced from its own stack
+	if n := goroutines(base + 2); n > base+2 {
+		t.Errorf("goroutines of collected fibers are running: %d, expected %d", n, base+2)
+	}
+
+	cancel()
+
+	if n := goroutines(base); n > base {
+		t.Errorf("goroutines of fibers are running after the script has ended: %d, expected %d", n, base)
+	}
+
+	runtime.KeepAlive(&ctx)
+}
@@ -0,0 +1,341 @@
+package phpt
+
+import "testing"
+
+func TestFunctions(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Variadic parameters",
+			File: `<?php
+function join_($glue, ...$parts) {
+    $s = "";
+    foreach ($parts as $k => $v) {
+        $s .= ($s === "" ? "" : $glue) . $k . "=" . $v;
+    }
+    return $s;
+}
+echo join_(","), ";", join_(",", "a"), ";", join_(",", "a", "b", "c"), ";";
+$f = fn(...$xs) => join_("+", ...$xs);
+echo $f(1, 2), ";";
+class Box {
+    public function items(...$items) {
+        return join_(" ", ...$items);
+    }
+}
+echo (new Box)->items("x", "y");`,
+			Expect: ";0=a;0=a,1=b,2=c;0=1+1=2;0=x 1=y",
+		},
+		{
+			Test: "Variadic parameters by reference",
+			File: `<?php
+function inc(&...$refs) {
+    foreach ($refs as &$r) {
+        $r++;
+    }
+}
+function fill($value, &...$targets) {
+    foreach ($targets as $i => $t) {
+        $targets[$i] = $value . $i;
+    }
+}
+$a = 1;
+$b = 10;
+inc($a, $b);
+inc($a);
+inc();
+echo $a, " ", $b, ";";
+fill("v", $x, $y);
+echo $x, $y;`,
+			Expect: "3 11;v0v1",
+		},
+		{
+			Test: "By-reference parameters of methods and dynamic calls",
+			File: `<?php
+class Counter {
+    public function inc(&$n) {
+        $n++;
+    }
+    public static function add(&$n, $m) {
+        $n += $m;
+        $m = 0;
+    }
+    public function __invoke(&$n) {
+        $n *= 2;
+    }
+}
+function dec(&$n, ...$rest) {
+    $n--;
+}
+function tag($value, &...$targets) {
+    foreach ($targets as $i => $t) {
+        $targets[$i] = $value;
+    }
+}
+$counter = new Counter();
+$n = 1;
+$m = 5;
+$counter->inc($n);
+Counter::add($n, $m);
+$counter($n);
+$name = "dec";
+$name($n, $m);
+$closure = function (&$n) {
+    $n += 100;
+};
+$closure($n);
+[$counter, "inc"]($n);
+"Counter::add"($n, $m);
+$counter->inc(n: $n);
+$tag = "tag";
+$tag("t", $x, $y);
+echo $n, " ", $m, " ", $x, $y;`,
+			Expect: "120 5 tt",
+		},
+		{
+			Test: "Argument unpacking",
+			File: `<?php
+function sum3($a, $b, $c) {
+    return $a + $b + $c;
+}
+$args = [1, 2, 3];
+echo sum3(...$args), ";", sum3(10, ...[20, 30]), ";", sum3(...[1], ...[2, 3]), ";";
+function gen() {
+    yield 4;
+    yield 5;
+}
+echo sum3(1, ...gen()), ";";
+$a = [1, 2];
+$b = [...$a, ...[3], 4];
+foreach ($b as $v) {
+    echo $v;
+}
+echo ";", sum3(...['c' => 3, 'a' => 1, 'b' => 2]);`,
+			Expect: "6;60;6;10;1234;6",
+		},
+		{
+			Test: "Named arguments",
+			File: `<?php
+function point($x, $y, $z) {
+    return "$x,$y,$z";
+}
+echo point(1, z: 3, y: 2), ";", point(z: 'c', x: 'a', y: 'b'), ";";
+class Rect {
+    public $w;
+    public $h;
+    public function __construct($w, $h) {
+        $this->w = $w;
+        $this->h = $h;
+    }
+    public static function square($side, $unit) {
+        return $side . $unit;
+    }
+    public function area($scale) {
+        return $this->w * $this->h * $scale;
+    }
+}
+$r = new Rect(h: 2, w: 5);
+echo $r->w, "x", $r->h, ";", Rect::square(unit: "cm", side: 4), ";", $r->area(scale: 10), ";";
+function opts($id, ...$options) {
+    $s = $id;
+    foreach ($options as $k => $v) {
+        $s .= " $k:$v";
+    }
+    return $s;
+}
+echo opts(7, color: "red", size: 2), ";";
+try {
+    point(1, 2, w: 3);
+} catch (Error $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    point(1, 2, 3, x: 4);
+} catch (Error $e) {
+    echo $e->getMessage();
+}`,
+			Expect: "1,2,3;a,b,c;5x2;4cm;100;7 color:red size:2;Unknown named parameter $w;Named parameter $x overwrites previous argument",
+		},
+		{
+			Test: "Default parameters",
+			File: `<?php
+echo later(), ";", later(2), ";", later(2, 3), ";";
+function later($a = 1, $b = Box::SIDE * 2) {
+    return $a + $b;
+}
+class Box {
+    const SIDE = 3;
+    public $side = self::SIDE;
+    public function scale($by = self::SIDE, $box = new Box) {
+        return $this->side * $by + $box->side;
+    }
+}
+$f = "later";
+echo $f(10), ";", later(b: 0), ";", (new Box)->scale(), ";", (new Box)->scale(1), ";";
+function countdown($from = 3) {
+    for ($i = $from; $i > 0; $i--) {
+        yield $i;
+    }
+}
+foreach (countdown() as $i) {
+    echo $i;
+}
+$c = function ($a, $b = [1, 2]) {
+    return $a . ":" . $b[1];
+};
+echo ";", $c(0), ";", (fn($z = 7) => $z)();`,
+			Expect: "7;8;5;16;1;12;6;321;0:2;7",
+		},
+		{
+			Test: "Argument count validation",
+			File: `<?php
+function pair($a, $b) {
+    return $a . $b;
+}
+function skip($a, $b = 2, $c = 3) {
+    return $a . $b . $c;
+}
+class Dto {
+    public function __construct($id, ...$tags) {}
+}
+try {
+    pair(1);
+} catch (ArgumentCountError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    skip();
+} catch (ArgumentCountError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    skip(c: 1);
+} catch (ArgumentCountError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    new Dto();
+} catch (ArgumentCountError $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    $e->getMessage(1);
+} catch (ArgumentCountError $e) {
+    echo $e->getMessage(), ";";
+}
+echo pair(1, 2, 3), skip(1, c: 0);`,
+			Expect: "Too few arguments to function pair(), 1 passed and exactly 2 expected;Too few arguments to function skip(), 0 passed and at least 1 expected;skip(): Argument #1 ($a) not passed;Too few arguments to function Dto::__construct(), 0 passed and at least 1 expected;Error::getMessage() expects exactly 0 arguments, 1 given;12120",
+		},
+		{
+			Test: "Parameter types",
+			File: `<?php
+function t($v) {
+    return match (true) {
+        $v === null => "null",
+        $v === (bool) $v => "bool",
+        $v === (int) $v => "int",
+        $v === (float) $v => "float",
+        default => "string",
+    } . "($v) ";
+}
+function i(int $x) { return t($x); }
+function f(float $x) { return t($x); }
+function u(int|string $x) { return t($x); }
+function n(?int $x, self|array|null $y = null) { return t($x); }
+function sum(int ...$xs) { return t($xs[0] + $xs[1]); }
+echo i("5"), f(3), u("8"), u(1.5), n(null), sum(1, "2"), "\n";
+echo i(" 5"), i("5 "), i("1e2"), f(" 1.5 "), f("7"), "\n";
+function checked($f) {
+    try {
+        $f();
+    } catch (TypeError $e) {
+        echo $e->getMessage(), "\n";
+    }
+}
+checked(fn() => i("abc"));
+checked(fn() => i("5.5"));
+checked(fn() => f("inf"));
+checked(fn() => i("0x1A"));
+checked(fn() => n([]));
+checked(fn() => sum(1, "x"));
+interface I {}
+interface J {}
+class IJ implements I, J {}
+class OnlyI implements I {}
+function both(I&J $v) { return "ok"; }
+function maybe(false|iterable $v, callable $c = null) { return $v === false ? "false" : "iterable"; }
+echo both(new IJ), " ", maybe(false), "\n";
+checked(fn() => both(new OnlyI));
+checked(fn() => maybe(true));
+checked(fn() => maybe([], "undefined"));
+function inc(int &$r) { $r++; }
+$q = "5";
+inc($q);
+echo t($q);`,
+			Expect: `int(5) float(3) string(8) string(1.5) null() int(3) 
+int(5) int(5) int(100) float(1.5) float(7) 
+i(): Argument #1 ($x) must be of type int, string given
+i(): Argument #1 ($x) must be of type int, string given
+f(): Argument #1 ($x) must be of type float, string given
+i(): Argument #1 ($x) must be of type int, string given
+n(): Argument #1 ($x) must be of type ?int, array given
+sum(): Argument #2 must be of type int, string given
+ok false
+both(): Argument #1 ($v) must be of type I&J, OnlyI given
+maybe(): Argument #1 ($v) must be of type iterable|false, bool given
+maybe(): Argument #2 ($c) must be of type ?callable, string given
+int(6) `,
+		},
+		{
+			Test: "Return types",
+			File: `<?php
+function r($x): int { return $x; }
+function v(): void {}
+function nv(): never { throw new Exception("never"); }
+function nr(): ?string { if (false) return "a"; }
+class A {
+    public function me(): static { return $this; }
+}
+class B extends A {}
+echo r("3") === 3 && v() === null && (new B)->me() instanceof B ? "ok" : "fail", "\n";
+foreach ([fn() => r("z"), fn() => nr(), fn() => nv()] as $f) {
+    try {
+        $f();
+    } catch (Throwable $e) {
+        echo $e->getMessage(), "\n";
+    }
+}`,
+			Expect: `ok
+r(): Return value must be of type int, string returned
+nr(): Return value must be of type ?string, none returned
+never
+`,
+		},
+		{
+			Test: "Strict types",
+			File: `<?php
+declare(strict_types=1);
+function i(int $x) { return $x; }
+function f(float $x): float { return $x; }
+function s($x): string { return $x; }
+echo i(5), f(2) === 2.0 ? " float" : " int", "\n";
+try {
+    i("5");
+} catch (TypeError $e) {
+    echo $e->getMessage(), "\n";
+}
+try {
+    s(1);
+} catch (TypeError $e) {
+    echo $e->getMessage(), "\n";
+}`,
+			Expect: `5 float
+i(): Argument #1 ($x) must be of type int, string given
+s(): Return value must be of type string, int returned
+`,
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
@@ -0,0 +1,177 @@
+package phpt
+
+import "testing"
+
+func TestGenerators(t *testing.T) {
+	tests := [...]PhpT{
+		{
+			Test: "Iteration",
+			File: `<?php
+function counter($start, $end) {
+    for ($i = $start; $i <= $end; $i++) {
+        yield $i;
+    }
+}
+$sum = 0;
+foreach (counter(1, 10) as $k => $n) {
+    $sum += $k * $n;
+}
+echo $sum;`,
+			Expect: "330",
+		},
+		{
+			Test: "Keys",
+			File: `<?php
+function kv() {
+    yield "a" => 1;
+    yield 10 => 2;
+    yield 3;
+}
+foreach (kv() as $k => $v) {
+    echo $k, "=", $v, " ";
+}`,
+			Expect: "a=1 10=2 11=3 ",
+		},
+		{
+			Test: "Send and return",
+			File: `<?php
+function logger() {
+    $first = yield 1;
+    echo "got ", $first, ";";
+    $second = yield 2;
+    echo "got ", $second, ";";
+    return $first . $second;
+}
+$g = logger();
+echo $g->current(), ";";
+echo $g->send("A"), ";";
+$g->send("B");
+echo (int)$g->valid(), $g->getReturn();`,
+			Expect: "1;got A;2;got B;0AB",
+		},
+		{
+			Test: "Yield from",
+			File: `<?php
+function inner() {
+    yield 1;
+    yield 2;
+    return "done";
+}
+function outer() {
+    $r = yield from inner();
+    echo "(", $r, ") ";
+    yield from [10 => "x", 11 => "y"];
+    yield 3;
+}
+foreach (outer() as $k => $v) {
+    echo $k, ":", $v, " ";
+}`,
+			Expect: "0:1 1:2 (done) 10:x 11:y 0:3 ",
+		},
+		{
+			Test: "Send through yield from",
+			File: `<?php
+function inner() {
+    $x = yield "first";
+    echo "inner got ", $x, ";";
+    return $x;
+}
+function outer() {
+    $r = yield from inner();
+    yield "outer " . $r;
+}
+$g = outer();
+echo $g->current(), ";";
+echo $g->send("v");`,
+			Expect: "first;inner got v;outer v",
+		},
+		{
+			Test: "Throw into generator",
+			File: `<?php
+function worker() {
+    while (true) {
+        try {
+            $v = yield;
+            echo "recv ", $v, ";";
+        } catch (Exception $e) {
+            echo "caught ", $e->getMessage(), ";";
+            yield "recovered";
+        }
+    }
+}
+$w = worker();
+$w->current();
+$w->send("job");
+echo $w->throw(new Exception("boom"));`,
+			Expect: "recv job;caught boom;recovered",
+		},
+		{
+			Test: "Exception escapes generator",
+			File: `<?php
+function failing() {
+    try {
+        yield 1;
+        throw new Exception("inside");
+    } finally {
+        echo "finally;";
+    }
+}
+try {
+    foreach (failing() as $v) {
+        echo $v, ";";
+    }
+} catch (Exception $e) {
+    echo "caught ", $e->getMessage();
+}`,
+			Expect: "1;finally;caught inside",
+		},
+		{
+			Test: "Misuse",
+			File: `<?php
+function gen() {
+    yield 1;
+    yield 2;
+}
+$g = gen();
+foreach ($g as $v) {}
+try {
+    foreach ($g as $v) {}
+} catch (Exception $e) {
+    echo $e->getMessage(), ";";
+}
+try {
+    gen()->getReturn();
+} catch (Exception $e) {
+    echo $e->getMessage(), ";";
+}
+echo (int)($g instanceof Traversable), (int)($g instanceof Generator);`,
+			Expect: "Cannot rewind a generator that was already run;Cannot get return value of a generator that hasn't returned;11",
+		},
+		{
+			Test: "Methods and closures",
+			File: `<?php
+class Collection {
+    private $items = [1, 2, 3];
+    function map($fn) {
+        foreach ($this->items as $k => $item) {
+            yield $k => $fn($item);
+        }
+    }
+}
+$gen = function ($n) {
+    yield $n;
+};
+foreach ((new Collection)->map(fn($x) => $x * 10) as $v) {
+    echo $v, " ";
+}
+foreach ($gen(5) as $v) {
+    echo $v;
+}`,
+			Expect: "10 20 30 5",
+		},
+	}
+
+	for _, test := range &tests {
+		test.RunTest(t)
+	}
+}
@@ -3,6 +3,7 @@ package phpt
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"php-vm/internal/compiler"
@@ -77,7 +78,9 @@ func (phpt *PhpT) RunTest(t *testing.T) {
 		ctx := vm.NewGlobalContext(context.Background(), nil, output)
 		comp := compiler.NewCompiler(nil)
 		fn := comp.Compile([]byte(phpt.File), &ctx)
-		ctx.Run(fn)
+		if err := ctx.Run(fn); err != nil {
+			fmt.Fprintf(output, "PHP Fatal error:  %s", err.Error())
+		}
 		if len(phpt.Expect) > 0 {
 			assert.Equal(t, phpt.Expect, output.String())
 		}